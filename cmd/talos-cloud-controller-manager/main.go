@@ -83,6 +83,7 @@ func main() {
 	controllerAliases["node-csr-approval"] = kcmnames.CertificateSigningRequestApprovingController
 
 	command := app.NewCloudControllerManagerCommand(ccmOptions, cloudInitializer, controllerInitializers, controllerAliases, fss, wait.NeverStop)
+	command.AddCommand(newTransformCommand())
 	command.Flags().VisitAll(func(flag *pflag.Flag) {
 		if flag.Name == "cloud-provider" {
 			if err := flag.Value.Set(talos.ProviderName); err != nil {
@@ -111,6 +112,10 @@ func cloudInitializer(config *config.CompletedConfig) cloudprovider.Interface {
 		klog.FlushAndExit(klog.ExitFlushTimeout, 1)
 	}
 
+	if talosCloud, ok := cloud.(*talos.Cloud); ok && cloudConfig.CloudConfigFile != "" {
+		talosCloud.WatchConfigFile(cloudConfig.CloudConfigFile)
+	}
+
 	if !cloud.HasClusterID() {
 		if config.ComponentConfig.KubeCloudShared.AllowUntaggedCloud {
 			klog.InfoS("detected a cluster without a ClusterID. A ClusterID will be required in the future. Please tag your cluster to avoid any future issues")
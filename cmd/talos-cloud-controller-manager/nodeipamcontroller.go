@@ -82,11 +82,13 @@ func startNodeIpamController(
 		return nil, false, nil
 	}
 
-	talos, err := talosclient.New(ctx)
-	if err != nil {
-		return nil, false, err
+	provider, ok := cloud.(talosclient.Provider)
+	if !ok {
+		return nil, false, fmt.Errorf("cloud provider %s does not share the Talos client", cloud.ProviderName())
 	}
 
+	talos := provider.TalosClient()
+
 	if ccmConfig.ComponentConfig.KubeCloudShared.ClusterCIDR == "" {
 		clusterCIDRs, err := talos.GetPodCIDRs(ctx)
 		if err != nil {
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talos"
+
+	v1 "k8s.io/api/core/v1"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+type transformOptions struct {
+	cloudConfig string
+	resources   []string
+	nodeName    string
+	nodeIPs     []string
+	node        string
+}
+
+// newTransformCommand returns the command to preview the transformation rules offline.
+func newTransformCommand() *cobra.Command {
+	opts := transformOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "transform",
+		Short: "Preview the transformation rules of the cloud config for the node",
+		Long: `Preview the labels, annotations, taints, platform metadata and addresses of the node,
+computed by the transformation rules of the cloud config from the Talos resources of the node.
+
+The resources are the output of the talosctl command, for example:
+
+  talosctl -n 10.0.0.2 get platformmetadata -o yaml > node.yaml
+  talosctl -n 10.0.0.2 get systeminformation -o yaml >> node.yaml
+  talosctl -n 10.0.0.2 get addresses -o yaml >> node.yaml
+
+With the --node flag, the changes of the existing node manifest are printed instead.
+The trace of the matched transformation rules is printed in both modes.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runTransform(cmd.OutOrStdout(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.cloudConfig, "cloud-config", "", "Path of the cloud config file")
+	cmd.Flags().StringSliceVarP(&opts.resources, "resources", "f", nil, "Paths of the Talos resources in the talosctl YAML or JSON output format")
+	cmd.Flags().StringVar(&opts.nodeName, "name", "", "Name of the node, the node manifest name or the hostname by default")
+	cmd.Flags().StringSliceVar(&opts.nodeIPs, "node-ip", nil, "IPs of the node, the node manifest provided IPs by default")
+	cmd.Flags().StringVar(&opts.node, "node", "", "Path of the node manifest to print the changes of the node")
+
+	cobra.CheckErr(cmd.MarkFlagRequired("resources"))
+
+	return cmd
+}
+
+func runTransform(w io.Writer, opts transformOptions) error {
+	res := talos.NodeResources{}
+
+	for _, path := range opts.resources {
+		if err := readNodeResources(path, &res); err != nil {
+			return err
+		}
+	}
+
+	var node *v1.Node
+
+	if opts.node != "" {
+		data, err := os.ReadFile(opts.node)
+		if err != nil {
+			return err
+		}
+
+		node = &v1.Node{}
+		if err := sigsyaml.Unmarshal(data, node); err != nil {
+			return fmt.Errorf("failed to decode the node manifest %s: %w", opts.node, err)
+		}
+
+		if opts.nodeName == "" {
+			opts.nodeName = node.Name
+		}
+
+		if providedIP, ok := node.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr]; ok && len(opts.nodeIPs) == 0 {
+			opts.nodeIPs = strings.Split(providedIP, ",")
+		}
+	}
+
+	if opts.nodeName == "" && res.PlatformMetadata != nil {
+		opts.nodeName = res.PlatformMetadata.Hostname
+	}
+
+	var config io.Reader
+
+	if opts.cloudConfig != "" {
+		f, err := os.Open(opts.cloudConfig)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close() //nolint:errcheck
+
+		config = f
+	}
+
+	preview, err := talos.PreviewNode(config, opts.nodeName, opts.nodeIPs, &res)
+	if err != nil {
+		return err
+	}
+
+	if node == nil {
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+
+		return encoder.Encode(preview)
+	}
+
+	updated, err := preview.Apply(node)
+	if err != nil {
+		return err
+	}
+
+	printMapDiff(w, "labels", node.Labels, updated.Labels)
+	printMapDiff(w, "annotations", node.Annotations, updated.Annotations)
+	printListDiff(w, "taints", taintStrings(node.Spec.Taints), taintStrings(updated.Spec.Taints))
+	printListDiff(w, "addresses", addressStrings(node.Status.Addresses), addressStrings(updated.Status.Addresses))
+
+	fmt.Fprintf(w, "trace: %s\n", preview.Trace) //nolint:errcheck
+
+	return nil
+}
+
+func readNodeResources(path string, res *talos.NodeResources) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	if err := talos.ReadNodeResources(f, res); err != nil {
+		return fmt.Errorf("failed to read the talos resources %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// printMapDiff prints the added (+), removed (-) and changed (~) keys.
+func printMapDiff(w io.Writer, title string, before, after map[string]string) {
+	var lines []string
+
+	for _, k := range slices.Sorted(maps.Keys(after)) {
+		v, ok := before[k]
+
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("  + %s: %q", k, after[k]))
+		case v != after[k]:
+			lines = append(lines, fmt.Sprintf("  ~ %s: %q -> %q", k, v, after[k]))
+		}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(before)) {
+		if _, ok := after[k]; !ok {
+			lines = append(lines, fmt.Sprintf("  - %s: %q", k, before[k]))
+		}
+	}
+
+	printDiff(w, title, lines)
+}
+
+// printListDiff prints the added (+) and removed (-) items.
+func printListDiff(w io.Writer, title string, before, after []string) {
+	var lines []string
+
+	for _, item := range after {
+		if !slices.Contains(before, item) {
+			lines = append(lines, "  + "+item)
+		}
+	}
+
+	for _, item := range before {
+		if !slices.Contains(after, item) {
+			lines = append(lines, "  - "+item)
+		}
+	}
+
+	printDiff(w, title, lines)
+}
+
+func printDiff(w io.Writer, title string, lines []string) {
+	if len(lines) == 0 {
+		fmt.Fprintf(w, "%s: no changes\n", title) //nolint:errcheck
+
+		return
+	}
+
+	fmt.Fprintf(w, "%s:\n%s\n", title, strings.Join(lines, "\n")) //nolint:errcheck
+}
+
+func taintStrings(taints []v1.Taint) []string {
+	res := make([]string, 0, len(taints))
+	for _, t := range taints {
+		res = append(res, t.ToString())
+	}
+
+	return res
+}
+
+func addressStrings(addresses []v1.NodeAddress) []string {
+	res := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		res = append(res, fmt.Sprintf("%s %s", addr.Type, addr.Address))
+	}
+
+	return res
+}
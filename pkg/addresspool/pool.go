@@ -0,0 +1,298 @@
+// Package addresspool provides the allocation of single IP addresses from the address pools.
+package addresspool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
+)
+
+// maxRangeSize is the maximum number of addresses in the range, due to the uncompressed bitmap.
+const maxRangeSize = 1 << 16
+
+var (
+	// ErrNoAddressesRemaining occurs when there are no free addresses of the IP family in the pool.
+	ErrNoAddressesRemaining = errors.New("address allocation failed; there are no remaining addresses left to allocate in the pool")
+	// ErrAddressOutOfRange occurs when the address does not belong to the pool.
+	ErrAddressOutOfRange = errors.New("address is out of the pool ranges")
+)
+
+// Range is the inclusive range of IP addresses.
+type Range struct {
+	First netip.Addr
+	Last  netip.Addr
+}
+
+// ParseRange parses the IP address, range `first-last` or CIDR.
+// The network and broadcast addresses of the IPv4 CIDR are excluded.
+func ParseRange(s string) (Range, error) {
+	var r Range
+
+	switch {
+	case strings.Contains(s, "-"):
+		first, last, _ := strings.Cut(s, "-")
+
+		firstAddr, err := netip.ParseAddr(strings.TrimSpace(first))
+		if err != nil {
+			return Range{}, err
+		}
+
+		lastAddr, err := netip.ParseAddr(strings.TrimSpace(last))
+		if err != nil {
+			return Range{}, err
+		}
+
+		if firstAddr.Is4() != lastAddr.Is4() || lastAddr.Less(firstAddr) {
+			return Range{}, fmt.Errorf("invalid address range")
+		}
+
+		r = Range{First: firstAddr, Last: lastAddr}
+	case strings.Contains(s, "/"):
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return Range{}, err
+		}
+
+		prefix = prefix.Masked()
+		r = Range{First: prefix.Addr(), Last: lastAddress(prefix)}
+
+		if r.First.Is4() && prefix.Bits() < 31 {
+			r = Range{First: r.First.Next(), Last: r.Last.Prev()}
+		}
+	default:
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return Range{}, err
+		}
+
+		r = Range{First: addr, Last: addr}
+	}
+
+	if r.size() > maxRangeSize {
+		return Range{}, fmt.Errorf("address range is too big, maximum %d addresses", maxRangeSize)
+	}
+
+	return r, nil
+}
+
+// Contains returns true if the address is in the range.
+func (r Range) Contains(addr netip.Addr) bool {
+	return r.First.Is4() == addr.Is4() && r.First.Compare(addr) <= 0 && addr.Compare(r.Last) <= 0
+}
+
+func (r Range) size() int {
+	n := new(big.Int).Sub(addrToInt(r.Last), addrToInt(r.First))
+	if !n.IsInt64() || n.Int64() >= maxRangeSize {
+		return maxRangeSize + 1
+	}
+
+	return int(n.Int64()) + 1
+}
+
+// Pool manages a set of address ranges from which single addresses can be allocated.
+type Pool struct {
+	sync.Mutex
+	// name is used to identify the pool and its metrics
+	name string
+	// ranges of the pool
+	ranges []Range
+	// offsets is the index of the first address of each range
+	offsets []int
+	// maxAddresses is the number of addresses in the pool
+	maxAddresses int
+	// allocatedAddresses counts the number of allocated addresses
+	allocatedAddresses int
+	// nextCandidate points to the next address that should be free
+	nextCandidate int
+	// used is a bitmap used to track the allocated addresses
+	used big.Int
+}
+
+// NewPool creates a new Pool.
+func NewPool(name string, ranges []Range) (*Pool, error) {
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("address pool %s has no ranges", name)
+	}
+
+	p := &Pool{
+		name:    name,
+		ranges:  ranges,
+		offsets: make([]int, len(ranges)),
+	}
+
+	for idx, r := range ranges {
+		if !r.First.IsValid() || !r.Last.IsValid() || r.First.Is4() != r.Last.Is4() || r.Last.Less(r.First) {
+			return nil, fmt.Errorf("address pool %s has invalid range %s-%s", name, r.First, r.Last)
+		}
+
+		p.offsets[idx] = p.maxAddresses
+		p.maxAddresses += r.size()
+	}
+
+	metrics.AddressPoolSize(p.name, p.maxAddresses)
+	metrics.AddressPoolAllocated(p.name, 0)
+
+	return p, nil
+}
+
+// Name returns the name of the pool.
+func (p *Pool) Name() string {
+	return p.name
+}
+
+func (p *Pool) String() string {
+	return fmt.Sprintf("Pool{name: %s, used: %d}", p.name, p.allocatedAddresses)
+}
+
+// Contains returns true if the address belongs to the pool.
+func (p *Pool) Contains(addr netip.Addr) bool {
+	_, ok := p.index(addr)
+
+	return ok
+}
+
+// AllocateNext allocates the next free address of the IP family.
+// This will set the address as occupied and return it.
+func (p *Pool) AllocateNext(ipv6 bool) (netip.Addr, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	candidate := p.nextCandidate
+
+	for range p.maxAddresses {
+		if p.used.Bit(candidate) == 0 {
+			if addr := p.address(candidate); addr.Is6() == ipv6 {
+				p.nextCandidate = (candidate + 1) % p.maxAddresses
+				p.occupy(candidate)
+
+				metrics.AddressPoolAllocation(p.name)
+
+				return addr, nil
+			}
+		}
+
+		candidate = (candidate + 1) % p.maxAddresses
+	}
+
+	return netip.Addr{}, ErrNoAddressesRemaining
+}
+
+// Occupy marks the address as used. Occupy succeeds even if the address was previously used.
+func (p *Pool) Occupy(addr netip.Addr) error {
+	idx, ok := p.index(addr)
+	if !ok {
+		return fmt.Errorf("failed to occupy %s in pool %s: %w", addr, p.name, ErrAddressOutOfRange)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.used.Bit(idx) == 0 {
+		p.occupy(idx)
+
+		metrics.AddressPoolAllocation(p.name)
+	}
+
+	return nil
+}
+
+// Release releases the address.
+func (p *Pool) Release(addr netip.Addr) error {
+	idx, ok := p.index(addr)
+	if !ok {
+		return fmt.Errorf("failed to release %s in pool %s: %w", addr, p.name, ErrAddressOutOfRange)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.used.Bit(idx) != 0 {
+		p.used.SetBit(&p.used, idx, 0)
+		p.allocatedAddresses--
+
+		metrics.AddressPoolRelease(p.name)
+		metrics.AddressPoolAllocated(p.name, p.allocatedAddresses)
+	}
+
+	return nil
+}
+
+// Used returns true if the address is allocated.
+func (p *Pool) Used(addr netip.Addr) bool {
+	idx, ok := p.index(addr)
+	if !ok {
+		return false
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	return p.used.Bit(idx) != 0
+}
+
+func (p *Pool) occupy(idx int) {
+	p.used.SetBit(&p.used, idx, 1)
+	p.allocatedAddresses++
+
+	metrics.AddressPoolAllocated(p.name, p.allocatedAddresses)
+}
+
+func (p *Pool) index(addr netip.Addr) (int, bool) {
+	addr = addr.Unmap()
+
+	for idx, r := range p.ranges {
+		if r.Contains(addr) {
+			return p.offsets[idx] + Range{First: r.First, Last: addr}.size() - 1, true
+		}
+	}
+
+	return 0, false
+}
+
+func (p *Pool) address(idx int) netip.Addr {
+	rangeIdx := len(p.offsets) - 1
+	for rangeIdx > 0 && p.offsets[rangeIdx] > idx {
+		rangeIdx--
+	}
+
+	return intToAddr(
+		new(big.Int).Add(addrToInt(p.ranges[rangeIdx].First), big.NewInt(int64(idx-p.offsets[rangeIdx]))),
+		p.ranges[rangeIdx].First.Is4(),
+	)
+}
+
+func addrToInt(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+func intToAddr(n *big.Int, ipv4 bool) netip.Addr {
+	if ipv4 {
+		var b [4]byte
+
+		binary.BigEndian.PutUint32(b[:], uint32(n.Uint64()))
+
+		return netip.AddrFrom4(b)
+	}
+
+	var b [16]byte
+
+	return netip.AddrFrom16([16]byte(n.FillBytes(b[:])))
+}
+
+func lastAddress(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+
+	addr, _ := netip.AddrFromSlice(b)
+
+	return addr
+}
@@ -0,0 +1,132 @@
+package addresspool_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/addresspool"
+)
+
+func TestParseRange(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		addr          string
+		expected      addresspool.Range
+		expectedError string
+	}{
+		{
+			name:     "single address",
+			addr:     "192.168.0.10",
+			expected: addresspool.Range{First: netip.MustParseAddr("192.168.0.10"), Last: netip.MustParseAddr("192.168.0.10")},
+		},
+		{
+			name:     "address range",
+			addr:     "192.168.0.10 - 192.168.0.20",
+			expected: addresspool.Range{First: netip.MustParseAddr("192.168.0.10"), Last: netip.MustParseAddr("192.168.0.20")},
+		},
+		{
+			name:     "IPv4 CIDR",
+			addr:     "192.168.0.0/28",
+			expected: addresspool.Range{First: netip.MustParseAddr("192.168.0.1"), Last: netip.MustParseAddr("192.168.0.14")},
+		},
+		{
+			name:     "IPv4 CIDR /32",
+			addr:     "192.168.0.1/32",
+			expected: addresspool.Range{First: netip.MustParseAddr("192.168.0.1"), Last: netip.MustParseAddr("192.168.0.1")},
+		},
+		{
+			name:     "IPv6 CIDR",
+			addr:     "fd00::/120",
+			expected: addresspool.Range{First: netip.MustParseAddr("fd00::"), Last: netip.MustParseAddr("fd00::ff")},
+		},
+		{
+			name:          "IPv6 CIDR is too big",
+			addr:          "fd00::/64",
+			expectedError: "address range is too big, maximum 65536 addresses",
+		},
+		{
+			name:          "reversed range",
+			addr:          "192.168.0.20-192.168.0.10",
+			expectedError: "invalid address range",
+		},
+		{
+			name:          "mixed families",
+			addr:          "192.168.0.10-fd00::1",
+			expectedError: "invalid address range",
+		},
+		{
+			name:          "invalid address",
+			addr:          "192.168.0",
+			expectedError: `ParseAddr("192.168.0"): IPv4 address too short`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := addresspool.ParseRange(tt.addr)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, r)
+		})
+	}
+}
+
+func TestPoolAllocateNext(t *testing.T) {
+	p, err := addresspool.NewPool("test", []addresspool.Range{
+		{First: netip.MustParseAddr("192.168.0.10"), Last: netip.MustParseAddr("192.168.0.11")},
+		{First: netip.MustParseAddr("fd00::ffff"), Last: netip.MustParseAddr("fd00::1:0")},
+		{First: netip.MustParseAddr("192.168.1.10"), Last: netip.MustParseAddr("192.168.1.10")},
+	})
+	require.NoError(t, err)
+
+	for _, expected := range []string{"192.168.0.10", "192.168.0.11", "192.168.1.10"} {
+		addr, err := p.AllocateNext(false)
+		assert.NoError(t, err)
+		assert.Equal(t, netip.MustParseAddr(expected), addr)
+	}
+
+	_, err = p.AllocateNext(false)
+	assert.ErrorIs(t, err, addresspool.ErrNoAddressesRemaining)
+
+	for _, expected := range []string{"fd00::ffff", "fd00::1:0"} {
+		addr, err := p.AllocateNext(true)
+		assert.NoError(t, err)
+		assert.Equal(t, netip.MustParseAddr(expected), addr)
+	}
+
+	_, err = p.AllocateNext(true)
+	assert.ErrorIs(t, err, addresspool.ErrNoAddressesRemaining)
+
+	assert.NoError(t, p.Release(netip.MustParseAddr("192.168.0.11")))
+	assert.False(t, p.Used(netip.MustParseAddr("192.168.0.11")))
+
+	addr, err := p.AllocateNext(false)
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.168.0.11"), addr)
+	assert.True(t, p.Used(addr))
+}
+
+func TestPoolOccupy(t *testing.T) {
+	p, err := addresspool.NewPool("test", []addresspool.Range{
+		{First: netip.MustParseAddr("192.168.0.10"), Last: netip.MustParseAddr("192.168.0.12")},
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, p.Occupy(netip.MustParseAddr("192.168.0.10")))
+	assert.NoError(t, p.Occupy(netip.MustParseAddr("192.168.0.10")))
+	assert.ErrorIs(t, p.Occupy(netip.MustParseAddr("192.168.0.20")), addresspool.ErrAddressOutOfRange)
+	assert.ErrorIs(t, p.Release(netip.MustParseAddr("fd00::1")), addresspool.ErrAddressOutOfRange)
+
+	assert.True(t, p.Contains(netip.MustParseAddr("192.168.0.12")))
+	assert.False(t, p.Contains(netip.MustParseAddr("192.168.0.13")))
+
+	addr, err := p.AllocateNext(false)
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.168.0.11"), addr)
+}
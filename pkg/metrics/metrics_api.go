@@ -1,12 +1,28 @@
 package metrics
 
 import (
+	"context"
+	"errors"
 	"time"
 
+	"github.com/cosi-project/runtime/pkg/state"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"k8s.io/component-base/metrics"
 	"k8s.io/component-base/metrics/legacyregistry"
 )
 
+// The reasons of the Talos API call errors.
+const (
+	ErrorReasonTimeout          = "timeout"
+	ErrorReasonUnavailable      = "unavailable"
+	ErrorReasonPermissionDenied = "permission_denied"
+	ErrorReasonNotFound         = "not_found"
+	ErrorReasonCanceled         = "canceled"
+	ErrorReasonOther            = "other"
+)
+
 // TalosMetrics contains the metrics for Talos API calls.
 type TalosMetrics struct {
 	Duration *metrics.HistogramVec
@@ -21,12 +37,42 @@ func (mc *MetricContext) ObserveRequest(err error) error {
 		time.Since(mc.start).Seconds())
 
 	if err != nil {
-		apiMetrics.Errors.WithLabelValues(mc.attributes...).Inc()
+		apiMetrics.Errors.WithLabelValues(append(mc.attributes, ErrorReason(err))...).Inc()
 	}
 
 	return err
 }
 
+// ErrorReason returns the reason of the Talos API call error.
+func ErrorReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorReasonTimeout
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorReasonCanceled
+	}
+
+	if state.IsNotFoundError(err) {
+		return ErrorReasonNotFound
+	}
+
+	switch status.Code(err) { //nolint:exhaustive
+	case codes.DeadlineExceeded:
+		return ErrorReasonTimeout
+	case codes.Canceled:
+		return ErrorReasonCanceled
+	case codes.Unavailable:
+		return ErrorReasonUnavailable
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return ErrorReasonPermissionDenied
+	case codes.NotFound:
+		return ErrorReasonNotFound
+	default:
+		return ErrorReasonOther
+	}
+}
+
 func registerAPIMetrics() *TalosMetrics {
 	metrics := &TalosMetrics{
 		Duration: metrics.NewHistogramVec(
@@ -38,8 +84,8 @@ func registerAPIMetrics() *TalosMetrics {
 		Errors: metrics.NewCounterVec(
 			&metrics.CounterOpts{
 				Name: "talosccm_api_request_errors_total",
-				Help: "Total number of errors for an Talos API call",
-			}, []string{"request"}),
+				Help: "Total number of errors for an Talos API call by the error reason",
+			}, []string{"request", "reason"}),
 	}
 
 	legacyregistry.MustRegister(
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// BreakerState is the state of the circuit breaker of the node.
+type BreakerState string
+
+const (
+	// BreakerStateOpen is the state when the requests to the unreachable node fail fast.
+	BreakerStateOpen BreakerState = "open"
+	// BreakerStateHalfOpen is the state when one request probes the unreachable node.
+	BreakerStateHalfOpen BreakerState = "half_open"
+)
+
+// BreakerMetrics contains the metrics for the circuit breaker of the unreachable nodes.
+type BreakerMetrics struct {
+	Nodes    *metrics.GaugeVec
+	Rejected *metrics.Counter
+}
+
+var breakerMetrics = registerBreakerMetrics()
+
+// BreakerNodes records the number of the nodes in the breaker state.
+func BreakerNodes(state BreakerState, nodes int) {
+	breakerMetrics.Nodes.WithLabelValues(string(state)).Set(float64(nodes))
+}
+
+// BreakerRejectedRequest counts the requests failed fast by the breaker.
+func BreakerRejectedRequest() {
+	breakerMetrics.Rejected.Inc()
+}
+
+func registerBreakerMetrics() *BreakerMetrics {
+	metrics := &BreakerMetrics{
+		Nodes: metrics.NewGaugeVec(
+			&metrics.GaugeOpts{
+				Name: "talosccm_breaker_nodes",
+				Help: "Number of the unreachable Talos nodes by the circuit breaker state",
+			}, []string{"state"}),
+		Rejected: metrics.NewCounter(
+			&metrics.CounterOpts{
+				Name: "talosccm_breaker_rejected_requests_total",
+				Help: "Total number of the Talos API requests to the unreachable nodes failed fast",
+			}),
+	}
+
+	legacyregistry.MustRegister(
+		metrics.Nodes,
+		metrics.Rejected,
+	)
+
+	return metrics
+}
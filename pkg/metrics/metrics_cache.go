@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// CacheResult is the result of the Talos cache lookup.
+type CacheResult string
+
+const (
+	// CacheResultHit is the result when the resource was served from the cache.
+	CacheResultHit CacheResult = "hit"
+	// CacheResultMiss is the result when the resource was requested from the Talos API.
+	CacheResultMiss CacheResult = "miss"
+)
+
+// CacheMetrics contains the metrics for the Talos resource cache.
+type CacheMetrics struct {
+	Requests *metrics.CounterVec
+	Watches  *metrics.Gauge
+}
+
+var cacheMetrics = registerCacheMetrics()
+
+// CacheRequest counts the cache lookups of the resource.
+func CacheRequest(resource string, result CacheResult) {
+	cacheMetrics.Requests.WithLabelValues(resource, string(result)).Inc()
+}
+
+// CacheWatches records the number of the watched nodes.
+func CacheWatches(nodes int) {
+	cacheMetrics.Watches.Set(float64(nodes))
+}
+
+func registerCacheMetrics() *CacheMetrics {
+	metrics := &CacheMetrics{
+		Requests: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Name: "talosccm_cache_requests_total",
+				Help: "Total number of the Talos resource cache lookups",
+			}, []string{"resource", "result"}),
+		Watches: metrics.NewGauge(
+			&metrics.GaugeOpts{
+				Name: "talosccm_cache_watched_nodes",
+				Help: "Number of the nodes watched by the Talos resource cache",
+			}),
+	}
+
+	legacyregistry.MustRegister(
+		metrics.Requests,
+		metrics.Watches,
+	)
+
+	return metrics
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// ConfigReloadResult is the result of the cloud config reload.
+type ConfigReloadResult string
+
+const (
+	// ConfigReloadResultSuccess is the result when the new cloud config is active.
+	ConfigReloadResultSuccess ConfigReloadResult = "success"
+	// ConfigReloadResultError is the result when the new cloud config is invalid, the previous config stays active.
+	ConfigReloadResultError ConfigReloadResult = "error"
+)
+
+// ConfigMetrics contains the metrics for the cloud config.
+type ConfigMetrics struct {
+	Info    *metrics.GaugeVec
+	Reloads *metrics.CounterVec
+}
+
+var configMetrics = registerConfigMetrics()
+
+// ConfigActive records the hash of the active cloud config.
+func ConfigActive(hash string) {
+	configMetrics.Info.Reset()
+	configMetrics.Info.WithLabelValues(hash).Set(1)
+}
+
+// ConfigReload counts the cloud config reloads.
+func ConfigReload(result ConfigReloadResult) {
+	configMetrics.Reloads.WithLabelValues(string(result)).Inc()
+}
+
+func registerConfigMetrics() *ConfigMetrics {
+	metrics := &ConfigMetrics{
+		Info: metrics.NewGaugeVec(
+			&metrics.GaugeOpts{
+				Name: "talosccm_config_info",
+				Help: "Hash of the active cloud config, the value is always 1",
+			}, []string{"hash"}),
+		Reloads: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Name: "talosccm_config_reloads_total",
+				Help: "Total number of the cloud config reloads by the result",
+			}, []string{"result"}),
+	}
+
+	legacyregistry.MustRegister(
+		metrics.Info,
+		metrics.Reloads,
+	)
+
+	return metrics
+}
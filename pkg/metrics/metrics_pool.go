@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// AddressPoolMetrics contains the metrics for load balancer address pools.
+type AddressPoolMetrics struct {
+	Size        *metrics.GaugeVec
+	Allocated   *metrics.GaugeVec
+	Allocations *metrics.CounterVec
+	Releases    *metrics.CounterVec
+}
+
+var addressPoolMetrics = registerAddressPoolMetrics()
+
+// AddressPoolSize records the number of addresses in the pool.
+func AddressPoolSize(pool string, size int) {
+	addressPoolMetrics.Size.WithLabelValues(pool).Set(float64(size))
+}
+
+// AddressPoolAllocated records the number of allocated addresses in the pool.
+func AddressPoolAllocated(pool string, allocated int) {
+	addressPoolMetrics.Allocated.WithLabelValues(pool).Set(float64(allocated))
+}
+
+// AddressPoolAllocation counts the address allocations in the pool.
+func AddressPoolAllocation(pool string) {
+	addressPoolMetrics.Allocations.WithLabelValues(pool).Inc()
+}
+
+// AddressPoolRelease counts the address releases in the pool.
+func AddressPoolRelease(pool string) {
+	addressPoolMetrics.Releases.WithLabelValues(pool).Inc()
+}
+
+func registerAddressPoolMetrics() *AddressPoolMetrics {
+	metrics := &AddressPoolMetrics{
+		Size: metrics.NewGaugeVec(
+			&metrics.GaugeOpts{
+				Name: "talosccm_address_pool_size",
+				Help: "Number of addresses in the load balancer address pool",
+			}, []string{"pool"}),
+		Allocated: metrics.NewGaugeVec(
+			&metrics.GaugeOpts{
+				Name: "talosccm_address_pool_allocated",
+				Help: "Number of allocated addresses in the load balancer address pool",
+			}, []string{"pool"}),
+		Allocations: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Name: "talosccm_address_pool_allocations_total",
+				Help: "Total number of address allocations in the load balancer address pool",
+			}, []string{"pool"}),
+		Releases: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Name: "talosccm_address_pool_releases_total",
+				Help: "Total number of address releases in the load balancer address pool",
+			}, []string{"pool"}),
+	}
+
+	legacyregistry.MustRegister(
+		metrics.Size,
+		metrics.Allocated,
+		metrics.Allocations,
+		metrics.Releases,
+	)
+
+	return metrics
+}
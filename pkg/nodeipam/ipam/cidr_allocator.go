@@ -22,6 +22,8 @@ import (
 	"net"
 	"time"
 
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient"
+
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -90,7 +92,7 @@ type CIDRAllocatorParams struct {
 func New(
 	ctx context.Context,
 	kubeClient clientset.Interface,
-	_ cloudprovider.Interface,
+	cloud cloudprovider.Interface,
 	nodeInformer informers.NodeInformer,
 	allocatorType CIDRAllocatorType,
 	allocatorParams CIDRAllocatorParams,
@@ -104,7 +106,12 @@ func New(
 	case RangeAllocatorType:
 		return NewCIDRRangeAllocator(ctx, kubeClient, nodeInformer, allocatorParams, nodeList)
 	case CloudAllocatorType:
-		return NewCIDRCloudAllocator(ctx, kubeClient, nodeInformer, allocatorParams, nodeList)
+		provider, ok := cloud.(talosclient.Provider)
+		if !ok {
+			return nil, fmt.Errorf("cloud provider does not share the Talos client, required by %v", allocatorType)
+		}
+
+		return NewCIDRCloudAllocator(ctx, kubeClient, provider.TalosClient(), nodeInformer, allocatorParams, nodeList)
 	default:
 		return nil, fmt.Errorf("invalid CIDR allocator type: %v", allocatorType)
 	}
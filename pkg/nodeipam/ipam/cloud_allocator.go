@@ -50,6 +50,8 @@ import (
 
 type cloudAllocator struct {
 	client clientset.Interface
+	// talos is the shared Talos client to discover the node CIDRs
+	talos talosclient.Interface
 
 	// cluster cidrs as passed in during controller creation
 	clusterCIDRs []*net.IPNet
@@ -90,6 +92,7 @@ var (
 func NewCIDRCloudAllocator(
 	ctx context.Context,
 	client clientset.Interface,
+	talos talosclient.Interface,
 	nodeInformer informers.NodeInformer,
 	allocatorParams CIDRAllocatorParams,
 	nodeList *v1.NodeList,
@@ -99,6 +102,9 @@ func NewCIDRCloudAllocator(
 		logger.Error(nil, "kubeClient is nil when starting CIDRRangeAllocator")
 		klog.FlushAndExit(klog.ExitFlushTimeout, 1)
 	}
+	if talos == nil {
+		return nil, fmt.Errorf("talos client is nil when starting CIDRCloudAllocator")
+	}
 
 	eventBroadcaster := record.NewBroadcaster(record.WithContext(ctx))
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "cidrAllocator"})
@@ -117,6 +123,7 @@ func NewCIDRCloudAllocator(
 
 	ra := &cloudAllocator{
 		client:       client,
+		talos:        talos,
 		clusterCIDRs: allocatorParams.ClusterCIDRs,
 		cidrSets:     cidrSets,
 		nodeLister:   nodeInformer.Lister(),
@@ -545,14 +552,9 @@ func (r *cloudAllocator) defineNodeGlobalCIDRs(ctx context.Context, node *v1.Nod
 	logger := klog.FromContext(ctx)
 	logger.V(5).Info("Node has addresses", "node", klog.KObj(node), "addresses", nodeIPs)
 
-	talos, err := talosclient.New(ctx)
-	if err != nil {
-		return "", err
-	}
-
 	var ifaces []network.AddressStatusSpec
 	for _, ip := range nodeIPs {
-		ifaces, err = talos.GetNodeIfaces(ctx, ip.String())
+		ifaces, err = r.talos.GetNodeIfaces(ctx, ip.String())
 
 		if err == nil {
 			break
@@ -24,6 +24,11 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/siderolabs/talos-cloud-controller-manager/pkg/nodeipam/ipam/cidrset"
+	talosfake "github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestAddCIDRSet(t *testing.T) {
@@ -131,3 +136,65 @@ func TestAddCIDRSet(t *testing.T) {
 		})
 	}
 }
+
+func TestDefineNodeGlobalCIDRs(t *testing.T) {
+	talos := talosfake.NewClient("", nil, nil, map[string]*talosfake.Node{
+		"192.168.0.1": {
+			Ifaces: []network.AddressStatusSpec{
+				{LinkName: "eth0", Address: netip.MustParsePrefix("192.168.0.1/24")},
+				{LinkName: "eth0", Address: netip.MustParsePrefix("2001:db8::10/64")},
+			},
+		},
+		"192.168.0.2": {
+			Ifaces: []network.AddressStatusSpec{
+				{LinkName: "eth0", Address: netip.MustParsePrefix("2001:db8::20/64")},
+			},
+		},
+	})
+
+	allocator := cloudAllocator{
+		talos:    talos,
+		cidrSets: make(map[netip.Prefix]*cidrset.CidrSet),
+	}
+
+	node := func(name string, addresses ...string) *v1.Node {
+		n := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		for idx, addr := range addresses {
+			addrType := v1.NodeInternalIP
+			if idx > 0 {
+				addrType = v1.NodeExternalIP
+			}
+
+			n.Status.Addresses = append(n.Status.Addresses, v1.NodeAddress{Type: addrType, Address: addr})
+		}
+
+		return n
+	}
+
+	for _, tt := range []struct {
+		name     string
+		node     *v1.Node
+		expected string
+	}{
+		{
+			name:     "node with public IPv6",
+			node:     node("node-1", "192.168.0.1", "2001:db8::10"),
+			expected: "2001:db8::10/64",
+		},
+		{
+			name:     "node in the same IPv6 subnet",
+			node:     node("node-2", "192.168.0.2", "2001:db8::20"),
+			expected: "2001:db8::/64",
+		},
+		{
+			name: "node is not reachable",
+			node: node("node-3", "192.168.0.3"),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cidr, err := allocator.defineNodeGlobalCIDRs(t.Context(), tt.node)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, cidr)
+		})
+	}
+}
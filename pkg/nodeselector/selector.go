@@ -7,29 +7,42 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// regexps holds the compiled regular expressions of the node selector requirements,
+// they are compiled once, when the node selector terms are validated.
+var regexps sync.Map
+
 // Match returns true if the node metadata matches the node selector rules.
 func Match(rules []NodeSelectorTerm, fields map[string]string) (bool, error) {
 	if len(rules) == 0 {
 		return true, nil
 	}
 
-	for _, rule := range rules {
+	idx, err := MatchTerm(rules, fields)
+
+	return idx >= 0, err
+}
+
+// MatchTerm returns the index of the first node selector term the node metadata matches, -1 if no term matches.
+func MatchTerm(rules []NodeSelectorTerm, fields map[string]string) (int, error) {
+	for idx, rule := range rules {
 		match, err := MatchExpressions(rule.MatchExpressions, fields)
 		if err != nil {
-			return false, err
+			return -1, err
 		}
 
 		if match {
-			return true, nil
+			return idx, nil
 		}
 	}
 
-	return false, nil
+	return -1, nil
 }
 
 // MatchExpressions returns true if the node metadata matches the node selector expressions.
+// The expressions are expected to be validated by Validate.
 //
 //nolint:cyclop,gocyclo
 func MatchExpressions(rules []NodeSelectorRequirement, fields map[string]string) (bool, error) {
@@ -40,58 +53,33 @@ func MatchExpressions(rules []NodeSelectorRequirement, fields map[string]string)
 	matchs := make([]bool, len(rules))
 
 	for idx, rule := range rules {
+		value, ok := fields[strings.ToLower(rule.Key)]
+
 		switch rule.Operator {
 		case NodeSelectorOpIn:
-			if len(rule.Values) == 0 {
-				return false, fmt.Errorf("values must be non-empty for operator '%s'", rule.Operator)
-			}
-
-			if value, ok := fields[strings.ToLower(rule.Key)]; ok {
-				matchs[idx] = slices.Contains(rule.Values, value)
-			}
+			matchs[idx] = ok && slices.Contains(rule.Values, value)
 
 		case NodeSelectorOpNotIn:
-			if len(rule.Values) == 0 {
-				return false, fmt.Errorf("values must be non-empty for operator '%s'", rule.Operator)
-			}
-
-			if value, ok := fields[strings.ToLower(rule.Key)]; ok {
-				matchs[idx] = !slices.Contains(rule.Values, value)
-			}
+			matchs[idx] = ok && !slices.Contains(rule.Values, value)
 
 		case NodeSelectorOpExists:
-			if len(rule.Values) > 0 {
-				return false, fmt.Errorf("values must be empty for operator %s", rule.Operator)
-			}
-
-			if _, ok := fields[strings.ToLower(rule.Key)]; ok {
-				matchs[idx] = true
-			}
+			matchs[idx] = ok
 
 		case NodeSelectorOpDoesNotExist:
-			if len(rule.Values) > 0 {
-				return false, fmt.Errorf("values must be empty for operator %s", rule.Operator)
-			}
-
-			if _, ok := fields[strings.ToLower(rule.Key)]; !ok {
-				matchs[idx] = true
-			}
+			matchs[idx] = !ok
 
 		case NodeSelectorOpGt, NodeSelectorOpLt:
 			if len(rule.Values) != 1 {
 				return false, fmt.Errorf("values must have a single element for operator %s", rule.Operator)
 			}
 
-			if value, ok := fields[strings.ToLower(rule.Key)]; ok {
+			if ok {
 				lsValue, err := strconv.ParseInt(value, 10, 64)
 				if err != nil {
 					return false, fmt.Errorf("failed to parse value %s as int", value)
 				}
 
-				rValue, err := strconv.ParseInt(rule.Values[0], 10, 64)
-				if err != nil {
-					return false, fmt.Errorf("failed to parse value %s as int", rule.Values[0])
-				}
+				rValue, _ := strconv.ParseInt(rule.Values[0], 10, 64) //nolint:errcheck
 
 				matchs[idx] = (rule.Operator == NodeSelectorOpGt && lsValue > rValue) || (rule.Operator == NodeSelectorOpLt && lsValue < rValue)
 			}
@@ -101,8 +89,11 @@ func MatchExpressions(rules []NodeSelectorRequirement, fields map[string]string)
 				return false, fmt.Errorf("values must have a single element for operator %s", rule.Operator)
 			}
 
-			if value, ok := fields[strings.ToLower(rule.Key)]; ok {
-				r := regexp.MustCompile(rule.Values[0])
+			if ok {
+				r, err := compileRegexp(rule.Values[0])
+				if err != nil {
+					return false, err
+				}
 
 				matchs[idx] = r.MatchString(value)
 			}
@@ -121,3 +112,72 @@ func MatchExpressions(rules []NodeSelectorRequirement, fields map[string]string)
 
 	return true, nil
 }
+
+// Validate returns the errors of the node selector terms, the errors are prefixed with the field path.
+func Validate(terms []NodeSelectorTerm, path string) []error {
+	var errs []error
+
+	for i, term := range terms {
+		for j, rule := range term.MatchExpressions {
+			if err := ValidateRequirement(rule); err != nil {
+				errs = append(errs, fmt.Errorf("%s[%d].matchExpressions[%d]: %w", path, i, j, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// ValidateRequirement returns an error if the operator or the values of the node selector requirement are not valid.
+func ValidateRequirement(rule NodeSelectorRequirement) error {
+	switch rule.Operator {
+	case NodeSelectorOpIn, NodeSelectorOpNotIn:
+		if len(rule.Values) == 0 {
+			return fmt.Errorf("values must be non-empty for operator '%s'", rule.Operator)
+		}
+
+	case NodeSelectorOpExists, NodeSelectorOpDoesNotExist:
+		if len(rule.Values) > 0 {
+			return fmt.Errorf("values must be empty for operator %s", rule.Operator)
+		}
+
+	case NodeSelectorOpGt, NodeSelectorOpLt:
+		if len(rule.Values) != 1 {
+			return fmt.Errorf("values must have a single element for operator %s", rule.Operator)
+		}
+
+		if _, err := strconv.ParseInt(rule.Values[0], 10, 64); err != nil {
+			return fmt.Errorf("failed to parse value %s as int", rule.Values[0])
+		}
+
+	case NodeSelectorOpRegexp:
+		if len(rule.Values) != 1 {
+			return fmt.Errorf("values must have a single element for operator %s", rule.Operator)
+		}
+
+		if _, err := compileRegexp(rule.Values[0]); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("%s not a valid selector operator", rule.Operator)
+	}
+
+	return nil
+}
+
+// compileRegexp returns the compiled regular expression, the expression is compiled only once.
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	if r, ok := regexps.Load(expr); ok {
+		return r.(*regexp.Regexp), nil
+	}
+
+	r, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp %q: %w", expr, err)
+	}
+
+	regexps.Store(expr, r)
+
+	return r, nil
+}
@@ -1,7 +1,9 @@
 package nodeselector_test
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -71,6 +73,59 @@ func TestMatch(t *testing.T) {
 	}
 }
 
+func TestMatchTerm(t *testing.T) {
+	fields := map[string]string{
+		"platform": "test-platform",
+		"hostname": "test-hostname",
+	}
+
+	term := func(key, value string) nodeselector.NodeSelectorTerm {
+		return nodeselector.NodeSelectorTerm{
+			MatchExpressions: []nodeselector.NodeSelectorRequirement{
+				{
+					Key:      key,
+					Operator: nodeselector.NodeSelectorOpIn,
+					Values:   []string{value},
+				},
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name     string
+		rules    []nodeselector.NodeSelectorTerm
+		expected int
+	}{
+		{
+			name:     "empty rules",
+			rules:    []nodeselector.NodeSelectorTerm{},
+			expected: -1,
+		},
+		{
+			name:     "first term",
+			rules:    []nodeselector.NodeSelectorTerm{term("platform", "test-platform"), term("hostname", "test-hostname")},
+			expected: 0,
+		},
+		{
+			name:     "second term",
+			rules:    []nodeselector.NodeSelectorTerm{term("platform", "bad-platform"), term("hostname", "test-hostname")},
+			expected: 1,
+		},
+		{
+			name:     "no term",
+			rules:    []nodeselector.NodeSelectorTerm{term("platform", "bad-platform")},
+			expected: -1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			idx, err := nodeselector.MatchTerm(tt.rules, fields)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, idx)
+		})
+	}
+}
+
 func TestMatchExpressions(t *testing.T) {
 	fields := map[string]string{
 		"platform": "test-platform",
@@ -87,17 +142,17 @@ func TestMatchExpressions(t *testing.T) {
 		expectedError error
 	}{
 		{
-			name: "MatchExpressions with In operator error",
+			name: "MatchExpressions with Gt operator error",
 			rules: []nodeselector.NodeSelectorRequirement{
 				{
-					Key:      "platform",
-					Operator: nodeselector.NodeSelectorOpIn,
+					Key:      "int",
+					Operator: nodeselector.NodeSelectorOpGt,
 					Values:   []string{},
 				},
 			},
 			fields:        fields,
 			expected:      false,
-			expectedError: fmt.Errorf("values must be non-empty for operator 'In'"),
+			expectedError: fmt.Errorf("values must have a single element for operator Gt"),
 		},
 		{
 			name: "MatchExpressions with In and Exists operator",
@@ -153,6 +208,19 @@ func TestMatchExpressions(t *testing.T) {
 			fields:   fields,
 			expected: true,
 		},
+		{
+			name: "MatchExpressions with invalid regexp",
+			rules: []nodeselector.NodeSelectorRequirement{
+				{
+					Key:      "hostname",
+					Operator: nodeselector.NodeSelectorOpRegexp,
+					Values:   []string{"^test-(.+$"},
+				},
+			},
+			fields:        fields,
+			expected:      false,
+			expectedError: fmt.Errorf("invalid regexp \"^test-(.+$\": error parsing regexp: missing closing ): `^test-(.+$`"),
+		},
 		{
 			name: "MatchExpressions with regexp operator did not match",
 			rules: []nodeselector.NodeSelectorRequirement{
@@ -184,3 +252,26 @@ func TestMatchExpressions(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate(t *testing.T) {
+	errs := nodeselector.Validate([]nodeselector.NodeSelectorTerm{
+		{
+			MatchExpressions: []nodeselector.NodeSelectorRequirement{
+				{Key: "platform", Operator: nodeselector.NodeSelectorOpIn, Values: []string{"metal"}},
+				{Key: "int", Operator: nodeselector.NodeSelectorOpLt, Values: []string{"ten"}},
+				{Key: "region", Operator: nodeselector.NodeSelectorOpIn, Values: []string{}},
+			},
+		},
+		{
+			MatchExpressions: []nodeselector.NodeSelectorRequirement{
+				{Key: "hostname", Operator: "Like", Values: []string{"web"}},
+			},
+		},
+	}, "nodeSelector")
+
+	assert.EqualError(t, errors.Join(errs...), strings.Join([]string{
+		"nodeSelector[0].matchExpressions[1]: failed to parse value ten as int",
+		"nodeSelector[0].matchExpressions[2]: values must be non-empty for operator 'In'",
+		"nodeSelector[1].matchExpressions[0]: Like not a valid selector operator",
+	}, "\n"))
+}
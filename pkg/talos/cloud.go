@@ -6,12 +6,21 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 
 	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
 
+	v1 "k8s.io/api/core/v1"
 	clientkubernetes "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/keymutex"
 )
 
 const (
@@ -31,22 +40,78 @@ const (
 	ClusterNodeLifeCycleLabel = "node.cloudprovider.kubernetes.io/lifecycle"
 	// ClusterNodeLifeCycleLabelSpot is a lifecycle type of compute node for spot instances.
 	ClusterNodeLifeCycleLabelSpot = "spot"
+
+	// ClusterNodeMachineTypeLabel is the node label of Talos machine type, controlplane or worker.
+	ClusterNodeMachineTypeLabel = "node.cloudprovider.kubernetes.io/machine-type"
+	// ClusterNodeTalosVersionLabel is the node label of Talos version.
+	ClusterNodeTalosVersionLabel = "node.cloudprovider.kubernetes.io/talos-version"
+	// ClusterNodeKernelVersionLabel is the node label of kernel version.
+	ClusterNodeKernelVersionLabel = "node.cloudprovider.kubernetes.io/kernel-version"
+	// ClusterNodeArchLabel is the node label of CPU architecture reported by Talos.
+	ClusterNodeArchLabel = "node.cloudprovider.kubernetes.io/arch"
+	// ClusterNodeSecureBootLabel is the node label of secure boot state.
+	ClusterNodeSecureBootLabel = "node.cloudprovider.kubernetes.io/secure-boot"
+	// ClusterNodeExtensionsAnnotation is the node annotation of the installed system extensions.
+	ClusterNodeExtensionsAnnotation = "node.cloudprovider.kubernetes.io/extensions"
+	// ClusterNodeNetworkLinkAnnotation is the node annotation of the network link with the node IP.
+	ClusterNodeNetworkLinkAnnotation = "node.cloudprovider.kubernetes.io/network-link"
+	// ClusterNodeNetworkSpeedAnnotation is the node annotation of the network link speed in Mbit/s.
+	ClusterNodeNetworkSpeedAnnotation = "node.cloudprovider.kubernetes.io/network-speed"
+	// ClusterNodeNetworkDriverAnnotation is the node annotation of the network link driver.
+	ClusterNodeNetworkDriverAnnotation = "node.cloudprovider.kubernetes.io/network-driver"
+	// ClusterNodeNetworkBondAnnotation is the node annotation of the bond name and mode of the network link.
+	ClusterNodeNetworkBondAnnotation = "node.cloudprovider.kubernetes.io/network-bond"
+	// ClusterNodeNetworkVLANAnnotation is the node annotation of the VLAN ID of the network link.
+	ClusterNodeNetworkVLANAnnotation = "node.cloudprovider.kubernetes.io/network-vlan"
+
+	// ClusterNodeTransformationsAnnotation is the node annotation of the keys of the labels, annotations and taints,
+	// which were applied by the transformation rules. It is managed by the transformation reconciler.
+	ClusterNodeTransformationsAnnotation = "node.cloudprovider.kubernetes.io/transformations"
+	// ClusterNodeTransformationsTraceAnnotation is the node annotation of the transformation rules,
+	// which matched the node, and the keys they set.
+	ClusterNodeTransformationsTraceAnnotation = "node.cloudprovider.kubernetes.io/transformations-trace"
+
+	// ClusterNodeMachineUUIDAnnotation is the node annotation of machine UUID, recorded at node registration.
+	ClusterNodeMachineUUIDAnnotation = "node.cloudprovider.kubernetes.io/machine-uuid"
+	// ClusterNodeMachineSerialAnnotation is the node annotation of machine serial number, recorded at node registration.
+	ClusterNodeMachineSerialAnnotation = "node.cloudprovider.kubernetes.io/machine-serial"
+
+	// ServiceAddressPoolAnnotation is the service annotation of the address pool name to allocate the load balancer address from.
+	ServiceAddressPoolAnnotation = "service.cloudprovider.kubernetes.io/address-pool"
+	// ServiceLoadBalancerIPAnnotation is the service annotation of the requested load balancer address.
+	ServiceLoadBalancerIPAnnotation = "service.cloudprovider.kubernetes.io/load-balancer-ip"
 )
 
 // Cloud is an implementation of cloudprovider interface for Talos CCM.
 type Cloud struct {
 	client *client
 
-	instancesV2 cloudprovider.InstancesV2
+	instancesV2  cloudprovider.InstancesV2
+	zones        cloudprovider.Zones
+	routes       cloudprovider.Routes
+	loadBalancer cloudprovider.LoadBalancer
+	nodeSyncer   *nodeSyncer
+	reconciler   *nodeReconciler
+	reloader     *configReloader
 
 	ctx  context.Context //nolint:containedctx
 	stop func()
 }
 
 type client struct {
-	config  *cloudConfig
-	talos   *talosclient.Client
-	kclient clientkubernetes.Interface
+	// mu guards the config, it is swapped on the cloud config reload.
+	mu  sync.RWMutex
+	cfg *cloudConfig
+
+	talos    talosclient.Interface
+	kclient  clientkubernetes.Interface
+	recorder record.EventRecorder
+
+	// nodeInformer is the node cache indexed by the Talos node IPs and the provider ID, it is started in Initialize.
+	nodeInformer cache.SharedIndexInformer
+
+	// nodeConfigLock serializes the machine config updates of the node, keyed by the node name.
+	nodeConfigLock keymutex.KeyMutex
 }
 
 func init() {
@@ -63,35 +128,168 @@ func init() {
 }
 
 func newCloud(config *cloudConfig) (cloudprovider.Interface, error) {
-	client, err := newClient(context.Background(), config)
+	if config == nil {
+		return nil, fmt.Errorf("talos cloudConfig is nil")
+	}
+
+	talos, err := newTalosClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(config, talos)
 	if err != nil {
 		return nil, err
 	}
 
 	instancesInterface := newInstances(client)
+	zonesInterface := newZones(client)
+
+	var routesInterface cloudprovider.Routes
+	if config.Global.Routes.Enabled {
+		routesInterface = newRoutes(client)
+	}
+
+	var loadBalancerInterface cloudprovider.LoadBalancer
+	if config.Global.LoadBalancer.Enabled {
+		if loadBalancerInterface, err = newLoadBalancer(client); err != nil {
+			return nil, err
+		}
+	}
+
+	var syncer *nodeSyncer
+	if watcher, ok := talos.(talosclient.NodeWatcher); ok && config.Global.Cache.SyncNodes {
+		syncer = newNodeSyncer(client)
+		watcher.OnNodeChange(syncer.enqueue)
+	}
+
+	var reconciler *nodeReconciler
+	if config.Global.Reconcile.Enabled {
+		reconciler = newNodeReconciler(client)
+	}
 
 	return &Cloud{
-		client:      client,
-		instancesV2: instancesInterface,
+		client:       client,
+		instancesV2:  instancesInterface,
+		zones:        zonesInterface,
+		routes:       routesInterface,
+		loadBalancer: loadBalancerInterface,
+		nodeSyncer:   syncer,
+		reconciler:   reconciler,
 	}, nil
 }
 
-func newClient(ctx context.Context, config *cloudConfig) (*client, error) {
-	if config == nil {
-		return nil, fmt.Errorf("talos cloudConfig is nil")
+// newTalosClient creates the Talos client, cached if it is enabled in the cloud config.
+// The multi-cluster client is created if the additional clusters are configured.
+func newTalosClient(config *cloudConfig) (talosclient.Interface, error) {
+	opts := config.Global.Talos.options()
+	opts.ClusterName = config.Global.ClusterName
+
+	talos, err := newClusterTalosClient(config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.Global.Clusters) == 0 {
+		return talos, nil
+	}
+
+	clusters := make([]talosclient.Cluster, 0, len(config.Global.Clusters))
+
+	for _, cluster := range config.Global.Clusters {
+		opts := cluster.Talos.options()
+		opts.ClusterName = cluster.Name
+
+		client, err := newClusterTalosClient(config, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create talos client of the cluster %s: %w", cluster.Name, err)
+		}
+
+		prefixes, err := cluster.prefixes()
+		if err != nil {
+			return nil, err
+		}
+
+		var selector func(*runtime.PlatformMetadataSpec) (bool, error)
+
+		if len(cluster.NodeSelector) > 0 {
+			terms := cluster.NodeSelector
+			selector = func(meta *runtime.PlatformMetadataSpec) (bool, error) {
+				return transformer.MatchPlatformMetadata(terms, meta)
+			}
+		}
+
+		clusters = append(clusters, talosclient.Cluster{
+			Name:     cluster.Name,
+			Client:   client,
+			Prefixes: prefixes,
+			Selector: selector,
+		})
 	}
 
-	talos, err := talosclient.New(ctx)
+	return talosclient.NewMultiClient(talosclient.Cluster{Name: "default", Client: talos}, clusters...)
+}
+
+func newClusterTalosClient(config *cloudConfig, opts talosclient.Options) (talosclient.Interface, error) {
+	talos, err := talosclient.NewWithOptions(context.Background(), opts)
 	if err != nil {
 		return nil, err
 	}
 
+	if config.Global.Cache.Enabled {
+		return talosclient.NewCachedClient(talos, talosclient.CacheOptions{
+			MaxStaleness: config.Global.Cache.maxStaleness(),
+			IdleTimeout:  config.Global.Cache.idleTimeout(),
+		}), nil
+	}
+
+	return talos, nil
+}
+
+// newClient creates the client with the given Talos client, it allows to inject a fake Talos client in tests.
+func newClient(config *cloudConfig, talos talosclient.Interface) (*client, error) {
+	if config == nil {
+		return nil, fmt.Errorf("talos cloudConfig is nil")
+	}
+
+	if talos == nil {
+		return nil, fmt.Errorf("talos client is nil")
+	}
+
 	return &client{
-		config: config,
-		talos:  talos,
+		cfg:            config,
+		talos:          talos,
+		nodeConfigLock: keymutex.NewHashed(0),
 	}, nil
 }
 
+// config returns the active cloud config.
+func (c *client) config() *cloudConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cfg
+}
+
+// setConfig replaces the active cloud config.
+func (c *client) setConfig(config *cloudConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cfg = config
+}
+
+// WatchConfigFile reloads the cloud config, when the config file changes.
+// The sections of the config used only on start, like the Talos API connection, require the restart.
+// It has to be called before Initialize.
+func (c *Cloud) WatchConfigFile(path string) {
+	c.reloader = newConfigReloader(c.client, path)
+
+	if c.reconciler != nil {
+		c.reloader.onReload = c.reconciler.enqueueAll
+	}
+}
+
 // Initialize provides the cloud with a kubernetes client builder and may spawn goroutines
 // to perform housekeeping or run custom controllers specific to the cloud provider.
 // Any tasks started here should be cleaned up when the stop channel closes.
@@ -109,21 +307,51 @@ func (c *Cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 	c.ctx = ctx
 	c.stop = cancel
 
+	eventBroadcaster := record.NewBroadcaster(record.WithContext(ctx))
+	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: c.client.kclient.CoreV1().Events("")})
+	c.client.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: ServiceAccountName})
+
+	informer, err := newNodeInformer(c.client)
+	if err != nil {
+		klog.ErrorS(err, "failed to create the node informer")
+	} else {
+		c.client.nodeInformer = informer
+
+		go informer.RunWithContext(ctx)
+	}
+
+	if c.nodeSyncer != nil {
+		go c.nodeSyncer.Run(ctx)
+	}
+
+	if c.reconciler != nil {
+		go c.reconciler.Run(ctx)
+	}
+
+	if c.reloader != nil {
+		go c.reloader.Run(ctx)
+	}
+
 	// Broadcast the upstream stop signal to all provider-level goroutines
 	// watching the provider's context for cancellation.
 	go func(provider *Cloud) {
 		<-stop
 		klog.V(3).InfoS("received cloud provider termination signal")
 		provider.stop()
+		eventBroadcaster.Shutdown()
+
+		if err := provider.client.talos.Close(); err != nil {
+			klog.ErrorS(err, "failed to close talos client")
+		}
 	}(c)
 
 	klog.InfoS("talos initialized")
 }
 
-// LoadBalancer returns a balancer interface.
+// LoadBalancer returns a balancer interface, if it is enabled in the cloud config.
 // Also returns true if the interface is supported, false otherwise.
 func (c *Cloud) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
-	return nil, false
+	return c.loadBalancer, c.loadBalancer != nil
 }
 
 // Instances returns an instances interface.
@@ -143,7 +371,7 @@ func (c *Cloud) InstancesV2() (cloudprovider.InstancesV2, bool) {
 // Zones returns a zones interface.
 // Also returns true if the interface is supported, false otherwise.
 func (c *Cloud) Zones() (cloudprovider.Zones, bool) {
-	return nil, false
+	return c.zones, c.zones != nil
 }
 
 // Clusters is not implemented.
@@ -151,9 +379,15 @@ func (c *Cloud) Clusters() (cloudprovider.Clusters, bool) {
 	return nil, false
 }
 
-// Routes is not implemented.
+// Routes returns a routes interface, if it is enabled in the cloud config.
+// Also returns true if the interface is supported, false otherwise.
 func (c *Cloud) Routes() (cloudprovider.Routes, bool) {
-	return nil, false
+	return c.routes, c.routes != nil
+}
+
+// TalosClient returns the Talos client shared with the controllers.
+func (c *Cloud) TalosClient() talosclient.Interface {
+	return c.client.talos
 }
 
 // ProviderName returns the cloud provider ID.
@@ -1,11 +1,21 @@
 package talos
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"maps"
+	"net/netip"
+	"slices"
+	"time"
 
 	yaml "gopkg.in/yaml.v3"
 
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/addresspool"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/nodeselector"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient"
 	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
 
 	"k8s.io/klog/v2"
 )
@@ -20,20 +30,562 @@ type cloudConfig struct {
 type cloudConfigGlobal struct {
 	// Talos cluster name.
 	ClusterName string `yaml:"clusterName,omitempty"`
+	// Talos API connection configuration.
+	Talos cloudConfigTalos `yaml:"talos,omitempty"`
+	// Additional Talos clusters, the nodes are routed to the clusters by the node IP and platform metadata.
+	Clusters []cloudConfigCluster `yaml:"clusters,omitempty"`
 	// Prefer IPv6.
 	PreferIPv6 bool `yaml:"preferIPv6,omitempty"`
+	// Instance existence check configuration.
+	InstanceExists cloudConfigInstanceExists `yaml:"instanceExists,omitempty"`
+	// Additional well-known node labels, populated from the Talos API.
+	Labels cloudConfigLabels `yaml:"labels,omitempty"`
+	// Action when the node is backed by a different machine than at registration.
+	MachineReplacementAction string `yaml:"machineReplacementAction,omitempty"`
+	// Pod CIDR routes configuration.
+	Routes cloudConfigRoutes `yaml:"routes,omitempty"`
+	// Load balancer configuration.
+	LoadBalancer cloudConfigLoadBalancer `yaml:"loadBalancer,omitempty"`
+	// Talos resource cache configuration.
+	Cache cloudConfigCache `yaml:"cache,omitempty"`
+	// Continuous reconciliation of the transformation rules.
+	Reconcile cloudConfigReconcile `yaml:"reconcile,omitempty"`
+	// Trace of the transformation rules, which rules matched the node and which keys they set.
+	TransformationsTrace cloudConfigTransformationsTrace `yaml:"transformationsTrace,omitempty"`
 }
 
+type cloudConfigTalos struct {
+	// Talos API endpoints, override the endpoints of the talosconfig context.
+	Endpoints []string `yaml:"endpoints,omitempty"`
+	// Path of the talosconfig file.
+	Talosconfig string `yaml:"talosconfig,omitempty"`
+	// Name of the talosconfig context.
+	Context string `yaml:"context,omitempty"`
+	// TLS credentials, override the credentials of the talosconfig context.
+	TLS cloudConfigTalosTLS `yaml:"tls,omitempty"`
+	// Interval of checking the talosconfig and TLS files for changes.
+	ReloadInterval time.Duration `yaml:"reloadInterval,omitempty"`
+	// Circuit breaker of the unreachable nodes.
+	CircuitBreaker cloudConfigCircuitBreaker `yaml:"circuitBreaker,omitempty"`
+	// Timeouts and retry budgets of the Talos API requests.
+	Requests cloudConfigTalosRequests `yaml:"requests,omitempty"`
+}
+
+type cloudConfigTalosRequests struct {
+	// Default options of the requests.
+	cloudConfigTalosRequest `yaml:",inline"`
+	// Options of the operations, they override the default options.
+	Operations map[string]cloudConfigTalosRequest `yaml:"operations,omitempty"`
+}
+
+type cloudConfigTalosRequest struct {
+	// Deadline of one request attempt.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Number of the request attempts, when the node or the Talos API endpoint is not reachable.
+	Attempts int `yaml:"attempts,omitempty"`
+}
+
+type cloudConfigCircuitBreaker struct {
+	// Number of consecutive failed requests, before the node is considered unreachable, negative disables the breaker.
+	FailureThreshold int `yaml:"failureThreshold,omitempty"`
+	// Initial time the requests to the unreachable node fail fast.
+	Backoff time.Duration `yaml:"backoff,omitempty"`
+	// Maximum time the requests to the unreachable node fail fast.
+	MaxBackoff time.Duration `yaml:"maxBackoff,omitempty"`
+}
+
+type cloudConfigTalosTLS struct {
+	// Path of the Talos CA certificate.
+	CAFile string `yaml:"caFile,omitempty"`
+	// Path of the client certificate.
+	CertFile string `yaml:"certFile,omitempty"`
+	// Path of the client certificate key.
+	KeyFile string `yaml:"keyFile,omitempty"`
+}
+
+type cloudConfigCluster struct {
+	// Cluster name, it is used as the cluster name label of the nodes.
+	Name string `yaml:"name"`
+	// Talos API connection configuration of the cluster.
+	Talos cloudConfigTalos `yaml:"talos,omitempty"`
+	// Node IP addresses or CIDRs of the cluster nodes, any node IP if empty.
+	Addresses []string `yaml:"addresses,omitempty"`
+	// Node selector by the platform metadata of the cluster nodes.
+	NodeSelector []nodeselector.NodeSelectorTerm `yaml:"nodeSelector,omitempty"`
+}
+
+type cloudConfigInstanceExists struct {
+	// Platforms where the instance existence check is enabled, `*` means all platforms.
+	Platforms []string `yaml:"platforms,omitempty"`
+	// Grace period after the first failed check, before the instance is considered deleted.
+	GracePeriod time.Duration `yaml:"gracePeriod,omitempty"`
+	// Number of consecutive failed checks, before the instance is considered deleted.
+	FailureThreshold int `yaml:"failureThreshold,omitempty"`
+}
+
+type cloudConfigLabels struct {
+	// Machine type label, controlplane or worker.
+	MachineType bool `yaml:"machineType,omitempty"`
+	// Talos version and kernel version labels.
+	Version bool `yaml:"version,omitempty"`
+	// CPU architecture label.
+	Arch bool `yaml:"arch,omitempty"`
+	// Secure boot state label.
+	SecureBoot bool `yaml:"secureBoot,omitempty"`
+	// Installed system extensions annotation.
+	Extensions bool `yaml:"extensions,omitempty"`
+	// Network topology annotations of the link with the node IP.
+	Network bool `yaml:"network,omitempty"`
+}
+
+type cloudConfigRoutes struct {
+	// Enable the routes controller.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Route metric, used to distinguish the managed routes from the others.
+	Metric uint32 `yaml:"metric,omitempty"`
+}
+
+type cloudConfigLoadBalancer struct {
+	// Enable the load balancer controller.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Address pool of the virtual IPs, list of IP addresses, ranges or CIDRs.
+	// It is the shortcut for the pool with the name `default`.
+	Addresses []string `yaml:"addresses,omitempty"`
+	// Named address pools of the virtual IPs.
+	Pools []cloudConfigAddressPool `yaml:"pools,omitempty"`
+	// Node selector of the nodes to assign the virtual IPs, by node labels.
+	NodeSelector []nodeselector.NodeSelectorTerm `yaml:"nodeSelector,omitempty"`
+}
+
+type cloudConfigAddressPool struct {
+	// Name of the pool, used in the service annotation.
+	Name string `yaml:"name"`
+	// List of IP addresses, ranges or CIDRs.
+	Addresses []string `yaml:"addresses"`
+	// Namespaces allowed to use the pool, all namespaces if empty.
+	Namespaces []string `yaml:"namespaces,omitempty"`
+}
+
+type cloudConfigCache struct {
+	// Enable the watch-based cache of the node resources.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// How long the cached resources are served after the watch stream is broken.
+	MaxStaleness time.Duration `yaml:"maxStaleness,omitempty"`
+	// Stop watching the node, which resources were not requested for this duration.
+	IdleTimeout time.Duration `yaml:"idleTimeout,omitempty"`
+	// Re-sync the node when the watched platform metadata or addresses change.
+	SyncNodes bool `yaml:"syncNodes,omitempty"`
+}
+
+type cloudConfigReconcile struct {
+	// Enable the reconciliation of the labels, annotations and taints of the transformation rules on the initialized nodes.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Interval of the periodic reconciliation of all nodes.
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+type cloudConfigTransformationsTrace struct {
+	// Publish the trace as the node annotation.
+	Annotation bool `yaml:"annotation,omitempty"`
+	// Publish the trace as the node event, when the transformation rules are applied to the node.
+	Events bool `yaml:"events,omitempty"`
+}
+
+const (
+	// MachineReplacementActionNotExists reports the instance as not existing, so the node lifecycle controller deletes the node.
+	MachineReplacementActionNotExists = "NotExists"
+	// MachineReplacementActionDelete deletes the node, kubelet registers the node again.
+	MachineReplacementActionDelete = "Delete"
+	// MachineReplacementActionIgnore records the new machine identity and keeps the node.
+	MachineReplacementActionIgnore = "Ignore"
+)
+
+const (
+	defaultInstanceExistsGracePeriod      = 5 * time.Minute
+	defaultInstanceExistsFailureThreshold = 3
+
+	defaultRoutesMetric = 4096
+
+	defaultAddressPoolName = "default"
+
+	defaultTalosReloadInterval = time.Minute
+
+	defaultCacheMaxStaleness = time.Minute
+	defaultCacheIdleTimeout  = 30 * time.Minute
+
+	defaultReconcileInterval = 10 * time.Minute
+)
+
 func readCloudConfig(config io.Reader) (cloudConfig, error) {
 	cfg := cloudConfig{}
 
 	if config != nil {
-		if err := yaml.NewDecoder(config).Decode(&cfg); err != nil {
+		decoder := yaml.NewDecoder(config)
+		decoder.KnownFields(true)
+
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
 			return cloudConfig{}, err
 		}
 	}
 
+	if err := cfg.validate(); err != nil {
+		return cloudConfig{}, err
+	}
+
 	klog.V(4).InfoS("cloudConfig", "cfg", cfg)
 
 	return cfg, nil
 }
+
+// validate returns all errors of the cloud config, the errors have the path of the field.
+func (c cloudConfig) validate() error {
+	var errs []error
+
+	switch c.Global.MachineReplacementAction {
+	case "", MachineReplacementActionNotExists, MachineReplacementActionDelete, MachineReplacementActionIgnore:
+	default:
+		errs = append(errs, fmt.Errorf("global.machineReplacementAction: unknown action %q", c.Global.MachineReplacementAction))
+	}
+
+	errs = append(errs, c.Global.Talos.validate("global.talos")...)
+
+	// Only the cached Talos client watches the node resources.
+	if c.Global.Cache.SyncNodes && !c.Global.Cache.Enabled {
+		errs = append(errs, fmt.Errorf("global.cache.syncNodes: the cache must be enabled"))
+	}
+
+	errs = append(errs, validateClusters(c.Global.Clusters, "global.clusters")...)
+
+	if c.Global.LoadBalancer.Enabled {
+		errs = append(errs, c.Global.LoadBalancer.validate("global.loadBalancer")...)
+	}
+
+	errs = append(errs, nodeselector.Validate(c.Global.LoadBalancer.NodeSelector, "global.loadBalancer.nodeSelector")...)
+
+	if err := transformer.ValidateNodeTerms(c.Transformations); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateClusters(clusters []cloudConfigCluster, path string) []error {
+	var errs []error
+
+	names := map[string]bool{}
+
+	for idx, cluster := range clusters {
+		path := fmt.Sprintf("%s[%d]", path, idx)
+
+		switch {
+		case cluster.Name == "":
+			errs = append(errs, fmt.Errorf("%s.name: talos cluster name must be specified", path))
+		case names[cluster.Name]:
+			errs = append(errs, fmt.Errorf("%s.name: talos cluster %q is duplicated", path, cluster.Name))
+		}
+
+		names[cluster.Name] = true
+
+		errs = append(errs, cluster.Talos.validate(path+".talos")...)
+
+		for i, addr := range cluster.Addresses {
+			if _, err := parseClusterPrefix(addr); err != nil {
+				errs = append(errs, fmt.Errorf("%s.addresses[%d]: invalid address %q: %w", path, i, addr, err))
+			}
+		}
+
+		errs = append(errs, nodeselector.Validate(cluster.NodeSelector, path+".nodeSelector")...)
+	}
+
+	return errs
+}
+
+func (c cloudConfigTalos) validate(path string) []error {
+	var errs []error
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("%s.tls: certFile and keyFile must be specified together", path))
+	}
+
+	return append(errs, c.Requests.validate(path+".requests")...)
+}
+
+// prefixes returns the node IP ranges of the cluster.
+func (c cloudConfigCluster) prefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.Addresses))
+
+	for _, addr := range c.Addresses {
+		prefix, err := parseClusterPrefix(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid talos cluster %q address %q: %w", c.Name, addr, err)
+		}
+
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+// parseClusterPrefix parses the node IP address or CIDR of the cluster.
+func parseClusterPrefix(addr string) (netip.Prefix, error) {
+	if ip, err := netip.ParseAddr(addr); err == nil {
+		return netip.PrefixFrom(ip, ip.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(addr)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return prefix.Masked(), nil
+}
+
+func (c cloudConfigTalos) options() talosclient.Options {
+	reloadInterval := c.ReloadInterval
+	if reloadInterval <= 0 {
+		reloadInterval = defaultTalosReloadInterval
+	}
+
+	var operations map[string]talosclient.RequestOptions
+
+	for op, req := range c.Requests.Operations {
+		if operations == nil {
+			operations = map[string]talosclient.RequestOptions{}
+		}
+
+		operations[op] = req.options()
+	}
+
+	return talosclient.Options{
+		Endpoints:      c.Endpoints,
+		ConfigPath:     c.Talosconfig,
+		Context:        c.Context,
+		CAFile:         c.TLS.CAFile,
+		CertFile:       c.TLS.CertFile,
+		KeyFile:        c.TLS.KeyFile,
+		ReloadInterval: reloadInterval,
+		Breaker: talosclient.BreakerOptions{
+			FailureThreshold: c.CircuitBreaker.FailureThreshold,
+			Backoff:          c.CircuitBreaker.Backoff,
+			MaxBackoff:       c.CircuitBreaker.MaxBackoff,
+		},
+		Request:    c.Requests.options(),
+		Operations: operations,
+	}
+}
+
+func (c cloudConfigTalosRequests) validate(path string) []error {
+	errs := c.cloudConfigTalosRequest.validate(path)
+
+	for _, op := range slices.Sorted(maps.Keys(c.Operations)) {
+		path := fmt.Sprintf("%s.operations[%q]", path, op)
+
+		if err := talosclient.ValidateOperations([]string{op}); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+
+		errs = append(errs, c.Operations[op].validate(path)...)
+	}
+
+	return errs
+}
+
+func (c cloudConfigTalosRequest) validate(path string) []error {
+	var errs []error
+
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout: must not be negative", path))
+	}
+
+	if c.Attempts < 0 {
+		errs = append(errs, fmt.Errorf("%s.attempts: must not be negative", path))
+	}
+
+	return errs
+}
+
+func (c cloudConfigTalosRequest) options() talosclient.RequestOptions {
+	return talosclient.RequestOptions{
+		Timeout:  c.Timeout,
+		Attempts: c.Attempts,
+	}
+}
+
+func (c cloudConfigCache) maxStaleness() time.Duration {
+	if c.MaxStaleness > 0 {
+		return c.MaxStaleness
+	}
+
+	return defaultCacheMaxStaleness
+}
+
+func (c cloudConfigCache) idleTimeout() time.Duration {
+	if c.IdleTimeout > 0 {
+		return c.IdleTimeout
+	}
+
+	return defaultCacheIdleTimeout
+}
+
+func (c cloudConfigReconcile) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+
+	return defaultReconcileInterval
+}
+
+func (c cloudConfigInstanceExists) enabled(platform string) bool {
+	return slices.Contains(c.Platforms, "*") || (platform != "" && slices.Contains(c.Platforms, platform))
+}
+
+func (c cloudConfigInstanceExists) gracePeriod() time.Duration {
+	if c.GracePeriod > 0 {
+		return c.GracePeriod
+	}
+
+	return defaultInstanceExistsGracePeriod
+}
+
+func (c cloudConfigInstanceExists) failureThreshold() int {
+	if c.FailureThreshold > 0 {
+		return c.FailureThreshold
+	}
+
+	return defaultInstanceExistsFailureThreshold
+}
+
+func (c cloudConfigGlobal) machineReplacementAction() string {
+	if c.MachineReplacementAction == "" {
+		return MachineReplacementActionNotExists
+	}
+
+	return c.MachineReplacementAction
+}
+
+func (c cloudConfigRoutes) metric() uint32 {
+	if c.Metric > 0 {
+		return c.Metric
+	}
+
+	return defaultRoutesMetric
+}
+
+// addressPools returns the address pools with parsed ranges, the `addresses` pool is the first one.
+func (c cloudConfigLoadBalancer) addressPools() ([]cloudConfigAddressPool, error) {
+	pools := c.Pools
+	if len(c.Addresses) > 0 {
+		pools = append([]cloudConfigAddressPool{{Name: defaultAddressPoolName, Addresses: c.Addresses}}, pools...)
+	}
+
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("loadBalancer addresses or pools must be specified")
+	}
+
+	names := map[string]bool{}
+
+	for _, pool := range pools {
+		if pool.Name == "" {
+			return nil, fmt.Errorf("loadBalancer pool name must be specified")
+		}
+
+		if names[pool.Name] {
+			return nil, fmt.Errorf("loadBalancer pool %q is duplicated", pool.Name)
+		}
+
+		names[pool.Name] = true
+
+		if _, err := pool.addressRanges(); err != nil {
+			return nil, err
+		}
+	}
+
+	return pools, nil
+}
+
+// validate returns all errors of the address pools.
+func (c cloudConfigLoadBalancer) validate(path string) []error {
+	if len(c.Addresses) == 0 && len(c.Pools) == 0 {
+		return []error{fmt.Errorf("%s: addresses or pools must be specified", path)}
+	}
+
+	var errs []error
+
+	names := map[string]bool{}
+
+	if len(c.Addresses) > 0 {
+		names[defaultAddressPoolName] = true
+
+		errs = append(errs, validateAddressRanges(c.Addresses, path+".addresses")...)
+	}
+
+	for idx, pool := range c.Pools {
+		path := fmt.Sprintf("%s.pools[%d]", path, idx)
+
+		switch {
+		case pool.Name == "":
+			errs = append(errs, fmt.Errorf("%s.name: pool name must be specified", path))
+		case names[pool.Name]:
+			errs = append(errs, fmt.Errorf("%s.name: pool %q is duplicated", path, pool.Name))
+		}
+
+		names[pool.Name] = true
+
+		if len(pool.Addresses) == 0 {
+			errs = append(errs, fmt.Errorf("%s.addresses: pool addresses must be specified", path))
+		}
+
+		errs = append(errs, validateAddressRanges(pool.Addresses, path+".addresses")...)
+	}
+
+	return errs
+}
+
+func validateAddressRanges(addresses []string, path string) []error {
+	var errs []error
+
+	for idx, addr := range addresses {
+		if _, err := addresspool.ParseRange(addr); err != nil {
+			errs = append(errs, fmt.Errorf("%s[%d]: invalid address %q: %w", path, idx, addr, err))
+		}
+	}
+
+	return errs
+}
+
+func (c cloudConfigAddressPool) addressRanges() ([]addresspool.Range, error) {
+	if len(c.Addresses) == 0 {
+		return nil, fmt.Errorf("loadBalancer pool %q addresses must be specified", c.Name)
+	}
+
+	res := make([]addresspool.Range, 0, len(c.Addresses))
+
+	for _, addr := range c.Addresses {
+		r, err := addresspool.ParseRange(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid loadBalancer pool %q address %q: %w", c.Name, addr, err)
+		}
+
+		res = append(res, r)
+	}
+
+	return res, nil
+}
+
+func (c cloudConfigAddressPool) allowed(namespace string) bool {
+	return len(c.Namespaces) == 0 || slices.Contains(c.Namespaces, namespace)
+}
+
+func (c cloudConfigLoadBalancer) nodeSelector() []nodeselector.NodeSelectorTerm {
+	if len(c.NodeSelector) > 0 {
+		return c.NodeSelector
+	}
+
+	return []nodeselector.NodeSelectorTerm{
+		{
+			MatchExpressions: []nodeselector.NodeSelectorRequirement{
+				{Key: constants.LabelNodeRoleControlPlane, Operator: nodeselector.NodeSelectorOpExists},
+			},
+		},
+	}
+}
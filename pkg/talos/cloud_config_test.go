@@ -1,8 +1,15 @@
 package talos
 
 import (
+	"fmt"
+	"net/netip"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient"
 )
 
 func TestReadCloudConfigEmpty(t *testing.T) {
@@ -25,16 +32,15 @@ global:
 transformations:
 - name: cluster
   nodeSelector:
-  - name: cluter-1
-    matchExpressions:
+  - matchExpressions:
     - key: platform
       operator: In
       values:
       - cluter
-    annotations:
-      cluster-platform: "{{ .Platform }}"
-    labels:
-      node-role.kubernetes.io/web: ""
+  annotations:
+    cluster-platform: "{{ .Platform }}"
+  labels:
+    node-role.kubernetes.io/web: ""
 `))
 	if err != nil {
 		t.Fatalf("Should succeed when a valid config is provided: %s", err)
@@ -44,3 +50,392 @@ transformations:
 		t.Errorf("incorrect preferIPv6: %v", cfg.Global.PreferIPv6)
 	}
 }
+
+func TestReadCloudConfigValidation(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		config      string
+		expectedErr string
+	}{
+		{
+			name: "unknown fields",
+			config: `
+global:
+  PreferIPv6: true
+transformations:
+  - name: web
+    nodeSelectors:
+      - matchExpressions:
+          - key: hostname
+            operator: Exists
+`,
+			expectedErr: "yaml: unmarshal errors:\n" +
+				"  line 3: field PreferIPv6 not found in type talos.cloudConfigGlobal\n" +
+				"  line 6: field nodeSelectors not found in type transformer.NodeTerm",
+		},
+		{
+			name: "all errors are reported",
+			config: `
+global:
+  machineReplacementAction: Remove
+  loadBalancer:
+    nodeSelector:
+      - matchExpressions:
+          - key: hostname
+            operator: In
+transformations:
+  - name: web
+    taints:
+      example.com/web: NoScheduled
+`,
+			expectedErr: `global.machineReplacementAction: unknown action "Remove"` + "\n" +
+				`global.loadBalancer.nodeSelector[0].matchExpressions[0]: values must be non-empty for operator 'In'` + "\n" +
+				`transformations[0] (web): taints["example.com/web"]: invalid value "NoScheduled": [taint effect "NoScheduled" is not valid]`,
+		},
+		{
+			name: "errors of all sections are reported with the field path",
+			config: `
+global:
+  talos:
+    tls:
+      keyFile: /etc/talos/tls.key
+    requests:
+      timeout: -1s
+      operations:
+        metadata:
+          attempts: -1
+  clusters:
+    - talos:
+        endpoints:
+          - 10.5.0.2
+      addresses:
+        - 10.5.0.0/33
+        - 10.6.0.0/16
+    - name: edge
+      talos:
+        requests:
+          attempts: -1
+  loadBalancer:
+    enabled: true
+    addresses:
+      - 192.168.0.0/33
+    pools:
+      - name: default
+      - addresses:
+          - 1.2.3.4-1.2.3
+`,
+			expectedErr: `global.talos.tls: certFile and keyFile must be specified together` + "\n" +
+				`global.talos.requests.timeout: must not be negative` + "\n" +
+				`global.talos.requests.operations["metadata"]: unknown talos operation "metadata", known operations ` + fmt.Sprint(talosclient.Operations()) + "\n" +
+				`global.talos.requests.operations["metadata"].attempts: must not be negative` + "\n" +
+				`global.clusters[0].name: talos cluster name must be specified` + "\n" +
+				`global.clusters[0].addresses[0]: invalid address "10.5.0.0/33": netip.ParsePrefix("10.5.0.0/33"): prefix length out of range` + "\n" +
+				`global.clusters[1].talos.requests.attempts: must not be negative` + "\n" +
+				`global.loadBalancer.addresses[0]: invalid address "192.168.0.0/33": netip.ParsePrefix("192.168.0.0/33"): prefix length out of range` + "\n" +
+				`global.loadBalancer.pools[0].name: pool "default" is duplicated` + "\n" +
+				`global.loadBalancer.pools[0].addresses: pool addresses must be specified` + "\n" +
+				`global.loadBalancer.pools[1].name: pool name must be specified` + "\n" +
+				`global.loadBalancer.pools[1].addresses[0]: invalid address "1.2.3.4-1.2.3": ParseAddr("1.2.3"): IPv4 address too short`,
+		},
+		{
+			name: "node sync without the cache",
+			config: `
+global:
+  cache:
+    syncNodes: true
+`,
+			expectedErr: "global.cache.syncNodes: the cache must be enabled",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readCloudConfig(strings.NewReader(tt.config))
+			assert.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestReadCloudConfigTalos(t *testing.T) {
+	cfg, err := readCloudConfig(strings.NewReader(`
+global:
+  talos:
+    endpoints:
+      - 192.168.0.1
+    talosconfig: /var/run/secrets/talos.dev/config
+    context: cluster-1
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, talosclient.Options{
+		Endpoints:      []string{"192.168.0.1"},
+		ConfigPath:     "/var/run/secrets/talos.dev/config",
+		Context:        "cluster-1",
+		ReloadInterval: defaultTalosReloadInterval,
+	}, cfg.Global.Talos.options())
+
+	cfg, err = readCloudConfig(strings.NewReader(`
+global:
+  talos:
+    tls:
+      caFile: /etc/talos/ca.crt
+      certFile: /etc/talos/tls.crt
+      keyFile: /etc/talos/tls.key
+    reloadInterval: 10s
+    circuitBreaker:
+      failureThreshold: 5
+      backoff: 10s
+      maxBackoff: 1m
+    requests:
+      timeout: 5s
+      attempts: 3
+      operations:
+        machineconfig:
+          timeout: 30s
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, talosclient.Options{
+		CAFile:         "/etc/talos/ca.crt",
+		CertFile:       "/etc/talos/tls.crt",
+		KeyFile:        "/etc/talos/tls.key",
+		ReloadInterval: 10 * time.Second,
+		Breaker: talosclient.BreakerOptions{
+			FailureThreshold: 5,
+			Backoff:          10 * time.Second,
+			MaxBackoff:       time.Minute,
+		},
+		Request: talosclient.RequestOptions{Timeout: 5 * time.Second, Attempts: 3},
+		Operations: map[string]talosclient.RequestOptions{
+			talosclient.OperationMachineConfig: {Timeout: 30 * time.Second},
+		},
+	}, cfg.Global.Talos.options())
+
+	_, err = readCloudConfig(strings.NewReader(`
+global:
+  talos:
+    tls:
+      certFile: /etc/talos/tls.crt
+`))
+	assert.EqualError(t, err, "global.talos.tls: certFile and keyFile must be specified together")
+
+	_, err = readCloudConfig(strings.NewReader(`
+global:
+  talos:
+    requests:
+      operations:
+        metadata:
+          timeout: 5s
+`))
+	assert.ErrorContains(t, err, `global.talos.requests.operations["metadata"]: unknown talos operation "metadata"`)
+}
+
+func TestReadCloudConfigClusters(t *testing.T) {
+	cfg, err := readCloudConfig(strings.NewReader(`
+global:
+  clusters:
+    - name: edge
+      talos:
+        context: edge
+      addresses:
+        - 10.5.0.0/24
+        - 192.168.0.1
+        - fd00::1
+    - name: cloud
+      nodeSelector:
+        - matchExpressions:
+            - key: platform
+              operator: In
+              values:
+                - hcloud
+`))
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Global.Clusters, 2)
+
+	prefixes, err := cfg.Global.Clusters[0].prefixes()
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.5.0.0/24"),
+		netip.MustParsePrefix("192.168.0.1/32"),
+		netip.MustParsePrefix("fd00::1/128"),
+	}, prefixes)
+	assert.Equal(t, "edge", cfg.Global.Clusters[0].Talos.Context)
+	assert.Len(t, cfg.Global.Clusters[1].NodeSelector, 1)
+
+	for _, tt := range []struct {
+		name        string
+		config      string
+		expectedErr string
+	}{
+		{
+			name: "cluster without name",
+			config: `
+global:
+  clusters:
+    - addresses: [10.5.0.0/24]
+`,
+			expectedErr: "global.clusters[0].name: talos cluster name must be specified",
+		},
+		{
+			name: "duplicated cluster",
+			config: `
+global:
+  clusters:
+    - name: edge
+    - name: edge
+`,
+			expectedErr: `global.clusters[1].name: talos cluster "edge" is duplicated`,
+		},
+		{
+			name: "invalid address",
+			config: `
+global:
+  clusters:
+    - name: edge
+      addresses: [10.5.0.0/33]
+`,
+			expectedErr: `global.clusters[0].addresses[0]: invalid address "10.5.0.0/33": netip.ParsePrefix("10.5.0.0/33"): prefix length out of range`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readCloudConfig(strings.NewReader(tt.config))
+			assert.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestReadCloudConfigInstanceExists(t *testing.T) {
+	cfg, err := readCloudConfig(strings.NewReader(`
+global:
+  instanceExists:
+    platforms:
+      - metal
+      - nocloud
+    gracePeriod: 10m
+    failureThreshold: 5
+`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 10*time.Minute, cfg.Global.InstanceExists.gracePeriod())
+	assert.Equal(t, 5, cfg.Global.InstanceExists.failureThreshold())
+	assert.True(t, cfg.Global.InstanceExists.enabled("metal"))
+	assert.False(t, cfg.Global.InstanceExists.enabled("aws"))
+	assert.False(t, cfg.Global.InstanceExists.enabled(""))
+
+	cfg, err = readCloudConfig(strings.NewReader(`
+global:
+  instanceExists:
+    platforms: ["*"]
+`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, defaultInstanceExistsGracePeriod, cfg.Global.InstanceExists.gracePeriod())
+	assert.Equal(t, defaultInstanceExistsFailureThreshold, cfg.Global.InstanceExists.failureThreshold())
+	assert.True(t, cfg.Global.InstanceExists.enabled("aws"))
+}
+
+func TestReadCloudConfigMachineReplacementAction(t *testing.T) {
+	cfg, err := readCloudConfig(strings.NewReader(`
+global:
+  machineReplacementAction: Delete
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, MachineReplacementActionDelete, cfg.Global.machineReplacementAction())
+
+	cfg, err = readCloudConfig(strings.NewReader(`
+global:
+  clusterName: test
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, MachineReplacementActionNotExists, cfg.Global.machineReplacementAction())
+
+	_, err = readCloudConfig(strings.NewReader(`
+global:
+  machineReplacementAction: Remove
+`))
+	assert.EqualError(t, err, `global.machineReplacementAction: unknown action "Remove"`)
+}
+
+func TestReadCloudConfigRoutes(t *testing.T) {
+	cfg, err := readCloudConfig(strings.NewReader(`
+global:
+  routes:
+    enabled: true
+    metric: 2048
+`))
+	assert.NoError(t, err)
+	assert.True(t, cfg.Global.Routes.Enabled)
+	assert.Equal(t, uint32(2048), cfg.Global.Routes.metric())
+
+	cfg, err = readCloudConfig(strings.NewReader(`
+global:
+  routes:
+    enabled: false
+`))
+	assert.NoError(t, err)
+	assert.False(t, cfg.Global.Routes.Enabled)
+	assert.Equal(t, uint32(defaultRoutesMetric), cfg.Global.Routes.metric())
+}
+
+func TestReadCloudConfigLoadBalancer(t *testing.T) {
+	cfg, err := readCloudConfig(strings.NewReader(`
+global:
+  loadBalancer:
+    enabled: true
+    addresses:
+      - 192.168.0.10-192.168.0.20
+      - fd00::/120
+`))
+	assert.NoError(t, err)
+	assert.True(t, cfg.Global.LoadBalancer.Enabled)
+	assert.Len(t, cfg.Global.LoadBalancer.nodeSelector(), 1)
+
+	_, err = readCloudConfig(strings.NewReader(`
+global:
+  loadBalancer:
+    enabled: true
+`))
+	assert.EqualError(t, err, "global.loadBalancer: addresses or pools must be specified")
+
+	_, err = readCloudConfig(strings.NewReader(`
+global:
+  loadBalancer:
+    enabled: true
+    addresses:
+      - 192.168.0.0/33
+`))
+	assert.ErrorContains(t, err, `global.loadBalancer.addresses[0]: invalid address "192.168.0.0/33"`)
+
+	cfg, err = readCloudConfig(strings.NewReader(`
+global:
+  loadBalancer:
+    enabled: true
+    addresses:
+      - 192.168.0.10-192.168.0.20
+    pools:
+      - name: public
+        addresses:
+          - 1.2.3.4/32
+        namespaces:
+          - ingress
+`))
+	assert.NoError(t, err)
+
+	pools, err := cfg.Global.LoadBalancer.addressPools()
+	assert.NoError(t, err)
+	assert.Len(t, pools, 2)
+	assert.Equal(t, "default", pools[0].Name)
+	assert.True(t, pools[0].allowed("kube-system"))
+	assert.Equal(t, "public", pools[1].Name)
+	assert.True(t, pools[1].allowed("ingress"))
+	assert.False(t, pools[1].allowed("default"))
+
+	_, err = readCloudConfig(strings.NewReader(`
+global:
+  loadBalancer:
+    enabled: true
+    addresses:
+      - 192.168.0.10
+    pools:
+      - name: default
+        addresses:
+          - 1.2.3.4
+`))
+	assert.EqualError(t, err, `global.loadBalancer.pools[0].name: pool "default" is duplicated`)
+}
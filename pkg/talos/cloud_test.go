@@ -1,9 +1,21 @@
 package talos
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	talosfake "github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	cloudprovider "k8s.io/cloud-provider"
+	cloudproviderapi "k8s.io/cloud-provider/api"
 )
 
 func config() cloudConfig {
@@ -45,8 +57,8 @@ func TestNewCloud(t *testing.T) {
 	assert.Equal(t, res, true)
 
 	zone, res := ccm.Zones()
-	assert.Nil(t, zone)
-	assert.Equal(t, res, false)
+	assert.NotNil(t, zone)
+	assert.Equal(t, res, true)
 
 	cl, res := ccm.Clusters()
 	assert.Nil(t, cl)
@@ -62,3 +74,77 @@ func TestNewCloud(t *testing.T) {
 	clID := ccm.HasClusterID()
 	assert.Equal(t, clID, true)
 }
+
+func TestCloudWithFakeTalos(t *testing.T) {
+	srv, err := talosfake.NewServer("192.168.0.1")
+	require.NoError(t, err)
+
+	t.Cleanup(srv.Stop)
+
+	talosconfig := filepath.Join(t.TempDir(), "talosconfig")
+	require.NoError(t, srv.Talosconfig("test-cluster").Save(talosconfig))
+
+	t.Setenv("TALOSCONFIG", talosconfig)
+	t.Setenv("TALOS_ENDPOINTS", srv.Endpoint())
+
+	for nodeIP, platform := range map[string]string{"192.168.0.1": "metal", "192.168.0.2": "nocloud"} {
+		meta := runtime.NewPlatformMetadataSpec(runtime.NamespaceName, runtime.PlatformMetadataID)
+		meta.TypedSpec().Platform = platform
+		require.NoError(t, srv.State(nodeIP).Create(t.Context(), meta))
+		require.NoError(t, srv.State(nodeIP).Create(t.Context(), hardware.NewSystemInformation(hardware.SystemInformationID)))
+	}
+
+	cfg := config()
+
+	ccm, err := newCloud(&cfg)
+	require.NoError(t, err)
+
+	node := func(name, nodeIP string) *v1.Node {
+		return &v1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: nodeIP},
+		}}
+	}
+
+	kclient := fake.NewClientset(node("node-1", "192.168.0.1"), node("node-2", "192.168.0.2"))
+	ccm.(*Cloud).client.kclient = kclient
+
+	instances, ok := ccm.InstancesV2()
+	require.True(t, ok)
+
+	for _, tt := range []struct {
+		node     *v1.Node
+		expected *cloudprovider.InstanceMetadata
+	}{
+		{
+			node: node("node-1", "192.168.0.1"),
+			expected: &cloudprovider.InstanceMetadata{
+				ProviderID: "talos://metal/192.168.0.1",
+				NodeAddresses: []v1.NodeAddress{
+					{Type: v1.NodeInternalIP, Address: "192.168.0.1"},
+					{Type: v1.NodeHostName, Address: "node-1"},
+				},
+			},
+		},
+		{
+			node: node("node-2", "192.168.0.2"),
+			expected: &cloudprovider.InstanceMetadata{
+				ProviderID: "talos://nocloud/192.168.0.2",
+				NodeAddresses: []v1.NodeAddress{
+					{Type: v1.NodeInternalIP, Address: "192.168.0.2"},
+					{Type: v1.NodeHostName, Address: "node-2"},
+				},
+			},
+		},
+	} {
+		t.Run(tt.node.Name, func(t *testing.T) {
+			meta, err := instances.InstanceMetadata(t.Context(), tt.node)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, meta)
+
+			res, err := kclient.CoreV1().Nodes().Get(t.Context(), tt.node.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "test-cluster", res.Labels[ClusterNameNodeLabel])
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package talos
+
+import (
+	"context"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+	"github.com/siderolabs/talos/pkg/machinery/resources/block"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+)
+
+// nodeHardware requests the hardware resources of the node for the transformation rules.
+type nodeHardware struct {
+	ctx    context.Context //nolint:containedctx
+	c      *client
+	nodeIP string
+}
+
+var _ transformer.NodeHardware = &nodeHardware{}
+
+func (h *nodeHardware) Processors() ([]hardware.ProcessorSpec, error) {
+	mc := metrics.NewMetricContext("processors")
+
+	res, err := h.c.talos.GetNodeProcessors(h.ctx, h.nodeIP)
+
+	return res, mc.ObserveRequest(err)
+}
+
+func (h *nodeHardware) MemoryModules() ([]hardware.MemoryModuleSpec, error) {
+	mc := metrics.NewMetricContext("memorymodules")
+
+	res, err := h.c.talos.GetNodeMemoryModules(h.ctx, h.nodeIP)
+
+	return res, mc.ObserveRequest(err)
+}
+
+func (h *nodeHardware) PCIDevices() ([]hardware.PCIDeviceSpec, error) {
+	mc := metrics.NewMetricContext("pcidevices")
+
+	res, err := h.c.talos.GetNodePCIDevices(h.ctx, h.nodeIP)
+
+	return res, mc.ObserveRequest(err)
+}
+
+func (h *nodeHardware) Disks() ([]block.DiskSpec, error) {
+	mc := metrics.NewMetricContext("disks")
+
+	res, err := h.c.talos.GetNodeDisks(h.ctx, h.nodeIP)
+
+	return res, mc.ObserveRequest(err)
+}
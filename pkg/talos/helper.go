@@ -6,14 +6,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"maps"
+	"net/netip"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
 	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
 	utilsnet "github.com/siderolabs/talos-cloud-controller-manager/pkg/utils/net"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/utils/platform"
+	talosconfig "github.com/siderolabs/talos/pkg/machinery/config/config"
 	"github.com/siderolabs/talos/pkg/machinery/constants"
 	"github.com/siderolabs/talos/pkg/machinery/nethelpers"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
 	"github.com/siderolabs/talos/pkg/machinery/resources/network"
 	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
 
@@ -21,9 +26,11 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clientkubernetes "k8s.io/client-go/kubernetes"
 	cloudproviderapi "k8s.io/cloud-provider/api"
 	cloudnodeutil "k8s.io/cloud-provider/node/helpers"
+	"k8s.io/klog/v2"
 )
 
 func ipDiscovery(nodeIPs []string, ifaces []network.AddressStatusSpec) (publicIPv4s, publicIPv6s []string) {
@@ -58,6 +65,121 @@ func ipDiscovery(nodeIPs []string, ifaces []network.AddressStatusSpec) (publicIP
 	return publicIPv4s, publicIPv6s
 }
 
+// nodeMetadata is the Talos metadata of the node with applied transformation rules.
+type nodeMetadata struct {
+	nodeIP   string
+	meta     *runtime.PlatformMetadataSpec
+	sysInfo  *hardware.SystemInformationSpec
+	nodeSpec *transformer.NodeSpec
+	trace    *transformer.Trace
+}
+
+// getNodeMetadata returns the Talos metadata of the node, the first reachable node IP is used.
+func getNodeMetadata(ctx context.Context, c *client, node *v1.Node, nodeIPs []string) (*nodeMetadata, error) {
+	var (
+		meta   *runtime.PlatformMetadataSpec
+		err    error
+		nodeIP string
+	)
+
+	mc := metrics.NewMetricContext(runtime.PlatformMetadataID)
+
+	for _, ip := range nodeIPs {
+		meta, err = c.talos.GetNodeMetadata(ctx, ip)
+		if mc.ObserveRequest(err) == nil {
+			nodeIP = ip
+
+			break
+		}
+
+		klog.ErrorS(err, "error getting metadata from the node", "node", klog.KRef("", node.Name))
+	}
+
+	if meta == nil {
+		return nil, fmt.Errorf("error getting metadata from the node %s", node.Name)
+	}
+
+	klog.V(5).InfoS("getNodeMetadata()", "node", klog.KRef("", node.Name), "resource", meta)
+
+	if err := setPlatformMetadataDefaults(meta, node.Name, nodeIP); err != nil {
+		return nil, err
+	}
+
+	msys := metrics.NewMetricContext(hardware.SystemInformationID)
+
+	// The system information is not available on all platforms, for example in a container or without SMBIOS,
+	// the transformation rules get the empty values and the machine identity is not recorded.
+	sysInfo, err := c.talos.GetNodeSystemInfo(ctx, nodeIP)
+	if msys.ObserveRequest(err) != nil {
+		klog.V(4).InfoS("getNodeMetadata() error getting system info from the node", "node", klog.KRef("", node.Name), "err", err)
+
+		sysInfo = nil
+	}
+
+	mct := metrics.NewMetricContext("transformer")
+	trace := &transformer.Trace{}
+
+	nodeSpec, err := transformer.TransformNode(c.config().Transformations, meta, sysInfo,
+		transformer.WithHardware(&nodeHardware{ctx: ctx, c: c, nodeIP: nodeIP}),
+		transformer.WithSystem(&nodeSystem{ctx: ctx, c: c, nodeIP: nodeIP}),
+		transformer.WithNetwork(&nodeNetwork{ctx: ctx, c: c, nodeIP: nodeIP}),
+		transformer.WithTrace(trace))
+	if mct.ObserveTransformer(err) != nil {
+		return nil, fmt.Errorf("error transforming node: %w", err)
+	}
+
+	klog.V(5).InfoS("node transformation trace", "node", klog.KRef("", node.Name), "trace", trace.String())
+
+	if nodeSpec == nil {
+		nodeSpec = &transformer.NodeSpec{}
+	}
+
+	return &nodeMetadata{
+		nodeIP:   nodeIP,
+		meta:     meta,
+		sysInfo:  sysInfo,
+		nodeSpec: nodeSpec,
+		trace:    trace,
+	}, nil
+}
+
+// setPlatformMetadataDefaults sets the provider ID and the hostname of the node, if the platform does not provide them.
+func setPlatformMetadataDefaults(meta *runtime.PlatformMetadataSpec, nodeName, nodeIP string) error {
+	if meta.ProviderID == "" {
+		meta.ProviderID = fmt.Sprintf("%s://%s/%s", ProviderName, meta.Platform, nodeIP)
+	}
+
+	// Fix for Azure, resource group name must be lower case.
+	// Since Talos 1.8 fixed it, we can remove this code in the future.
+	if meta.Platform == "azure" {
+		providerID, err := platform.AzureConvertResourceGroupNameToLower(meta.ProviderID)
+		if err != nil {
+			return fmt.Errorf("error converting resource group name to lower case: %w", err)
+		}
+
+		meta.ProviderID = providerID
+	}
+
+	if meta.Hostname == "" {
+		meta.Hostname = nodeName
+	}
+
+	return nil
+}
+
+// getNodeAllAddresses returns the IP addresses of the node with the hostname and the internal DNS name.
+func getNodeAllAddresses(config *cloudConfig, nodeName string, meta *runtime.PlatformMetadataSpec, features *transformer.NodeFeaturesFlagSpec, nodeIPs []string, ifaces []network.AddressStatusSpec) []v1.NodeAddress {
+	addresses := getNodeAddresses(config, meta.Platform, features, nodeIPs, ifaces)
+
+	addresses = append(addresses, v1.NodeAddress{Type: v1.NodeHostName, Address: nodeName})
+
+	if meta.Hostname != "" && strings.IndexByte(meta.Hostname, '.') > 0 {
+		addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalDNS, Address: meta.Hostname})
+	}
+
+	return addresses
+}
+
 func getNodeAddresses(config *cloudConfig, platform string, features *transformer.NodeFeaturesFlagSpec, nodeIPs []string, ifaces []network.AddressStatusSpec) []v1.NodeAddress {
 	var publicIPv4s, publicIPv6s, publicIPs []string
 
@@ -102,6 +224,158 @@ func getNodeAddresses(config *cloudConfig, platform string, features *transforme
 	return addresses
 }
 
+// getTalosNodeIPs returns the node IPs to reach the Talos API, the IPs provided by kubelet are preferred.
+func getTalosNodeIPs(config *cloudConfig, node *v1.Node) []string {
+	nodeIPs := []string{}
+
+	if providedIP, ok := node.ObjectMeta.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr]; ok {
+		nodeIPs = append(nodeIPs, strings.Split(providedIP, ",")...)
+	}
+
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == v1.NodeInternalIP && !slices.Contains(nodeIPs, addr.Address) {
+			nodeIPs = append(nodeIPs, addr.Address)
+		}
+	}
+
+	return utilsnet.PreferredDualStackNodeIPs(config.Global.PreferIPv6, nodeIPs)
+}
+
+// providerIDNodeIP returns the node IP from the provider ID, defined by Talos CCM.
+func providerIDNodeIP(providerID string) string {
+	if !strings.HasPrefix(providerID, ProviderName+"://") {
+		return ""
+	}
+
+	ip, err := netip.ParseAddr(providerID[strings.LastIndex(providerID, "/")+1:])
+	if err != nil {
+		return ""
+	}
+
+	return ip.String()
+}
+
+// talosNode is the Talos node of the cluster with the node IPs to reach the Talos API.
+type talosNode struct {
+	name    string
+	labels  map[string]string
+	nodeIPs []netip.Addr
+}
+
+func newTalosNode(config *cloudConfig, node *v1.Node) talosNode {
+	nodeIPs := []netip.Addr{}
+
+	for _, ip := range getTalosNodeIPs(config, node) {
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			nodeIPs = append(nodeIPs, addr)
+		}
+	}
+
+	return talosNode{
+		name:    node.Name,
+		labels:  node.Labels,
+		nodeIPs: nodeIPs,
+	}
+}
+
+// getTalosNodes returns the Talos nodes of the cluster.
+func getTalosNodes(ctx context.Context, c *client) ([]talosNode, error) {
+	nodes, err := c.kclient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing nodes: %w", err)
+	}
+
+	res := []talosNode{}
+
+	for idx := range nodes.Items {
+		node := &nodes.Items[idx]
+		if !strings.HasPrefix(node.Spec.ProviderID, ProviderName+"://") {
+			continue
+		}
+
+		res = append(res, newTalosNode(c.config(), node))
+	}
+
+	return res, nil
+}
+
+// getTalosNodeRoutes returns the kernel routes of the Talos node.
+func getTalosNodeRoutes(ctx context.Context, c *client, node talosNode) ([]network.RouteStatusSpec, error) {
+	if len(node.nodeIPs) == 0 {
+		return nil, fmt.Errorf("node %s has no addresses", node.name)
+	}
+
+	mc := metrics.NewMetricContext("routes")
+
+	statuses, err := c.talos.GetNodeRoutes(ctx, node.nodeIPs[0].String())
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// updateTalosNodeConfig updates the machine config documents of the Talos node,
+// the config is applied only if the update function reports changes.
+func updateTalosNodeConfig(ctx context.Context, c *client, node talosNode, update func([]talosconfig.Document) ([]talosconfig.Document, bool)) error {
+	if len(node.nodeIPs) == 0 {
+		return fmt.Errorf("node %s has no addresses", node.name)
+	}
+
+	// The routes and load balancer controllers update the same nodes concurrently,
+	// the whole machine config is applied, so the concurrent updates would overwrite each other.
+	c.nodeConfigLock.LockKey(node.name)
+	defer c.nodeConfigLock.UnlockKey(node.name) //nolint:errcheck
+
+	nodeIP := node.nodeIPs[0].String()
+	mc := metrics.NewMetricContext("machineconfig")
+
+	docs, err := c.talos.GetNodeConfigDocuments(ctx, nodeIP)
+	if mc.ObserveRequest(err) != nil {
+		return err
+	}
+
+	docs, changed := update(docs)
+	if !changed {
+		return nil
+	}
+
+	klog.V(4).InfoS("apply machine config", "node", klog.KRef("", node.name))
+
+	mc = metrics.NewMetricContext("machineconfig")
+
+	return mc.ObserveRequest(c.talos.ApplyNodeConfigDocuments(ctx, nodeIP, docs))
+}
+
+// directLinkName returns the link name of the directly connected network with the address.
+func directLinkName(statuses []network.RouteStatusSpec, addr netip.Addr) string {
+	for _, status := range statuses {
+		if status.Table == nethelpers.TableMain &&
+			status.Scope == nethelpers.ScopeLink &&
+			!status.Gateway.IsValid() &&
+			status.Destination.Contains(addr) {
+			return status.OutLinkName
+		}
+	}
+
+	return ""
+}
+
+func recordNodeEvent(c *client, node *v1.Node, eventType, reason, messageFmt string, args ...any) {
+	if c.recorder == nil {
+		return
+	}
+
+	ref := &v1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Node",
+		Name:       node.Name,
+		UID:        node.UID,
+	}
+
+	c.recorder.Eventf(ref, eventType, reason, messageFmt, args...)
+}
+
 func syncNodeAnnotations(ctx context.Context, c *client, node *v1.Node, nodeAnnotations map[string]string) error {
 	nodeAnnotationsOrig := node.ObjectMeta.Annotations
 	annotationsToUpdate := map[string]string{}
@@ -147,18 +421,7 @@ func syncNodeTaints(_ context.Context, c *client, node *v1.Node, nodeTaints map[
 	taints := make([]*v1.Taint, 0, len(nodeTaints))
 
 	for k, v := range nodeTaints {
-		taint := v1.Taint{
-			Key: k,
-		}
-
-		value := strings.Split(v, ":")
-		if len(value) == 2 {
-			taint.Value = value[0]
-			taint.Effect = v1.TaintEffect(value[1])
-		} else {
-			taint.Effect = v1.TaintEffect(value[0])
-		}
-
+		taint := parseTaint(k, v)
 		taints = append(taints, &taint)
 	}
 
@@ -169,6 +432,23 @@ func syncNodeTaints(_ context.Context, c *client, node *v1.Node, nodeTaints map[
 	return nil
 }
 
+// parseTaint returns the taint of the transformation rule, the value has the format "value:effect" or "effect".
+func parseTaint(key, value string) v1.Taint {
+	taint := v1.Taint{
+		Key: key,
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) == 2 {
+		taint.Value = parts[0]
+		taint.Effect = v1.TaintEffect(parts[1])
+	} else {
+		taint.Effect = v1.TaintEffect(parts[0])
+	}
+
+	return taint
+}
+
 func taintExists(taints []v1.Taint, taintToFind *v1.Taint) bool {
 	for _, taint := range taints {
 		if taint.MatchTaint(taintToFind) {
@@ -179,11 +459,25 @@ func taintExists(taints []v1.Taint, taintToFind *v1.Taint) bool {
 	return false
 }
 
-func setTalosNodeLabels(c *client, meta *runtime.PlatformMetadataSpec) map[string]string {
+func setTalosNodeLabels(ctx context.Context, c *client, nodeIP string, meta *runtime.PlatformMetadataSpec) map[string]string {
 	if meta == nil {
 		return make(map[string]string)
 	}
 
+	clusterName, err := c.talos.GetNodeClusterName(ctx, nodeIP)
+	if err != nil {
+		klog.V(4).InfoS("failed to get the talos cluster name of the node", "nodeIP", nodeIP, "err", err)
+	}
+
+	if clusterName == "" {
+		clusterName = c.config().Global.ClusterName
+	}
+
+	return talosNodeLabels(meta, clusterName)
+}
+
+// talosNodeLabels returns the platform, life cycle and cluster name labels of the node.
+func talosNodeLabels(meta *runtime.PlatformMetadataSpec, clusterName string) map[string]string {
 	labels := make(map[string]string, 3)
 
 	if meta.Platform != "" {
@@ -194,11 +488,6 @@ func setTalosNodeLabels(c *client, meta *runtime.PlatformMetadataSpec) map[strin
 		labels[ClusterNodeLifeCycleLabel] = ClusterNodeLifeCycleLabelSpot
 	}
 
-	clusterName := c.config.Global.ClusterName
-	if clusterName == "" {
-		clusterName = c.talos.GetClusterName()
-	}
-
 	if clusterName != "" {
 		labels[ClusterNameNodeLabel] = clusterName
 	}
@@ -206,6 +495,113 @@ func setTalosNodeLabels(c *client, meta *runtime.PlatformMetadataSpec) map[strin
 	return labels
 }
 
+// talosNodeWellKnown returns the well-known labels and annotations of the node, which are enabled in the config.
+// The values, which are not available, are skipped.
+func talosNodeWellKnown(ctx context.Context, c *client, nodeIP string) (labels, annotations map[string]string) {
+	labels = map[string]string{}
+	annotations = map[string]string{}
+
+	cfg := c.config().Global.Labels
+	sys := &nodeSystem{ctx: ctx, c: c, nodeIP: nodeIP}
+
+	for _, l := range []struct {
+		enabled bool
+		key     string
+		value   func() (string, error)
+	}{
+		{cfg.MachineType, ClusterNodeMachineTypeLabel, sys.MachineType},
+		{cfg.Version, ClusterNodeTalosVersionLabel, sys.TalosVersion},
+		{cfg.Version, ClusterNodeKernelVersionLabel, sys.KernelVersion},
+		{cfg.Arch, ClusterNodeArchLabel, sys.Arch},
+		{cfg.SecureBoot, ClusterNodeSecureBootLabel, sys.SecureBoot},
+	} {
+		if !l.enabled {
+			continue
+		}
+
+		value, err := l.value()
+		if err != nil {
+			klog.V(4).InfoS("failed to get the node label value", "nodeIP", nodeIP, "label", l.key, "err", err)
+
+			continue
+		}
+
+		if errs := validation.IsValidLabelValue(value); value == "" || len(errs) != 0 {
+			klog.V(4).InfoS("skipping the invalid node label value", "nodeIP", nodeIP, "label", l.key, "value", value, "errs", errs)
+
+			continue
+		}
+
+		labels[l.key] = value
+	}
+
+	if cfg.Extensions {
+		exts, err := sys.Extensions()
+		if err != nil {
+			klog.V(4).InfoS("failed to get the node extensions", "nodeIP", nodeIP, "err", err)
+		} else {
+			names := make([]string, 0, len(exts))
+			for _, ext := range exts {
+				names = append(names, ext.Metadata.Name)
+			}
+
+			slices.Sort(names)
+
+			annotations[ClusterNodeExtensionsAnnotation] = strings.Join(slices.Compact(names), ",")
+		}
+	}
+
+	if cfg.Network {
+		topology, err := networkTopology(&nodeNetwork{ctx: ctx, c: c, nodeIP: nodeIP})
+		if err != nil {
+			klog.V(4).InfoS("failed to get the node network topology", "nodeIP", nodeIP, "err", err)
+		} else {
+			maps.Copy(annotations, networkAnnotations(topology))
+		}
+	}
+
+	return labels, annotations
+}
+
+func networkTopology(net transformer.NodeNetwork) (transformer.NetworkTopology, error) {
+	links, err := net.Links()
+	if err != nil {
+		return transformer.NetworkTopology{}, err
+	}
+
+	name, err := net.NodeLinkName()
+	if err != nil {
+		return transformer.NetworkTopology{}, err
+	}
+
+	return transformer.LinkTopology(links, name), nil
+}
+
+// networkAnnotations returns the network topology annotations, the unknown values are skipped.
+func networkAnnotations(topology transformer.NetworkTopology) map[string]string {
+	annotations := map[string]string{
+		ClusterNodeNetworkLinkAnnotation: topology.Link,
+	}
+
+	if topology.SpeedMbit > 0 {
+		annotations[ClusterNodeNetworkSpeedAnnotation] = strconv.Itoa(topology.SpeedMbit)
+	}
+
+	if topology.Driver != "" {
+		annotations[ClusterNodeNetworkDriverAnnotation] = topology.Driver
+	}
+
+	if topology.Bond != "" {
+		annotations[ClusterNodeNetworkBondAnnotation] = topology.Bond + "/" + topology.BondMode
+	}
+
+	if topology.VLAN > 0 {
+		annotations[ClusterNodeNetworkVLANAnnotation] = strconv.Itoa(int(topology.VLAN))
+	}
+
+	return annotations
+}
+
 func syncNodeLabels(c *client, node *v1.Node, nodeLabels map[string]string) error {
 	nodeLabelsOrig := node.ObjectMeta.Labels
 	labelsToUpdate := map[string]string{}
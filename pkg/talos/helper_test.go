@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/siderolabs/talos-cloud-controller-manager/pkg/nodeselector"
+	talosfake "github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
 	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
 	"github.com/siderolabs/talos/pkg/machinery/nethelpers"
 	"github.com/siderolabs/talos/pkg/machinery/resources/network"
@@ -189,9 +190,69 @@ func TestGetNodeAddresses(t *testing.T) {
 	}
 }
 
-func TestSyncNodeLabels(t *testing.T) {
-	t.Setenv("TALOSCONFIG", "../../hack/talosconfig")
+func TestGetTalosNodeIPs(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		cfg      cloudConfig
+		node     *v1.Node
+		expected []string
+	}{
+		{
+			name:     "node has no addresses",
+			node:     &v1.Node{},
+			expected: []string{},
+		},
+		{
+			name: "node has provided IP",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+					cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.1",
+				}},
+				Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+					{Type: v1.NodeInternalIP, Address: "192.168.0.2"},
+					{Type: v1.NodeInternalIP, Address: "fd00::2"},
+					{Type: v1.NodeExternalIP, Address: "2001:1234::1"},
+				}},
+			},
+			expected: []string{"192.168.0.1", "fd00::2"},
+		},
+		{
+			name: "node has internal IPs (IPv6 preferred)",
+			cfg:  cloudConfig{Global: cloudConfigGlobal{PreferIPv6: true}},
+			node: &v1.Node{
+				Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+					{Type: v1.NodeInternalIP, Address: "192.168.0.2"},
+					{Type: v1.NodeInternalIP, Address: "fd00::2"},
+					{Type: v1.NodeHostName, Address: "node1"},
+				}},
+			},
+			expected: []string{"fd00::2", "192.168.0.2"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, getTalosNodeIPs(&tt.cfg, tt.node))
+		})
+	}
+}
 
+func TestProviderIDNodeIP(t *testing.T) {
+	for _, tt := range []struct {
+		providerID string
+		expected   string
+	}{
+		{providerID: "", expected: ""},
+		{providerID: "talos://metal/192.168.0.1", expected: "192.168.0.1"},
+		{providerID: "talos://nocloud/fd00::1", expected: "fd00::1"},
+		{providerID: "talos://metal/node-1", expected: ""},
+		{providerID: "aws:///us-east-1f/i-1234567890abcdef0", expected: ""},
+	} {
+		t.Run(tt.providerID, func(t *testing.T) {
+			assert.Equal(t, tt.expected, providerIDNodeIP(tt.providerID))
+		})
+	}
+}
+
+func TestSyncNodeLabels(t *testing.T) {
 	cfg := cloudConfig{
 		Global: cloudConfigGlobal{
 			ClusterName: "test-cluster",
@@ -239,7 +300,7 @@ func TestSyncNodeLabels(t *testing.T) {
 		},
 	}
 
-	client, err := newClient(ctx, &cfg)
+	client, err := newClient(&cfg, talosfake.NewClient("", nil, nil, nil))
 	assert.NoError(t, err)
 
 	client.kclient = fake.NewClientset(nodes)
@@ -356,10 +417,10 @@ func TestSyncNodeLabels(t *testing.T) {
 		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			nodeSpec, err := transformer.TransformNode(client.config.Transformations, tt.meta, nil)
+			nodeSpec, err := transformer.TransformNode(client.config().Transformations, tt.meta, nil)
 			assert.NoError(t, err)
 
-			labels := setTalosNodeLabels(client, tt.meta)
+			labels := setTalosNodeLabels(ctx, client, "192.168.0.1", tt.meta)
 
 			if nodeSpec != nil && nodeSpec.Labels != nil {
 				maps.Copy(labels, nodeSpec.Labels)
@@ -378,6 +439,107 @@ func TestSyncNodeLabels(t *testing.T) {
 	}
 }
 
+func TestTalosNodeWellKnown(t *testing.T) {
+	extension := func(name string) runtime.ExtensionStatusSpec {
+		ext := runtime.ExtensionStatusSpec{}
+		ext.Metadata.Name = name
+
+		return ext
+	}
+
+	talos := talosfake.NewClient("test-cluster", nil, nil, map[string]*talosfake.Node{
+		"192.168.0.1": {
+			Version:       &runtime.VersionSpec{Version: "v1.13.5"},
+			KernelVersion: "6.18.44-talos",
+			MachineType:   "worker",
+			Arch:          "arm64",
+			SecurityState: &runtime.SecurityStateSpec{SecureBoot: true},
+			Extensions:    []runtime.ExtensionStatusSpec{extension("iscsi-tools"), extension("gvisor")},
+			Ifaces: []network.AddressStatusSpec{
+				{LinkName: "eth0", Address: netip.MustParsePrefix("192.168.0.1/24")},
+			},
+			Links: map[string]network.LinkStatusSpec{
+				"eth0": {Index: 2, LinkState: true, SpeedMegabits: 10000, Driver: "ixgbe"},
+			},
+		},
+		"192.168.0.2": {
+			MachineType:   "controlplane",
+			KernelVersion: "6.18.44-talos+debug",
+		},
+	})
+
+	for _, tt := range []struct {
+		name                string
+		labels              cloudConfigLabels
+		nodeIP              string
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:                "disabled",
+			nodeIP:              "192.168.0.1",
+			expectedLabels:      map[string]string{},
+			expectedAnnotations: map[string]string{},
+		},
+		{
+			name:   "all groups",
+			labels: cloudConfigLabels{MachineType: true, Version: true, Arch: true, SecureBoot: true, Extensions: true, Network: true},
+			nodeIP: "192.168.0.1",
+			expectedLabels: map[string]string{
+				ClusterNodeMachineTypeLabel:   "worker",
+				ClusterNodeTalosVersionLabel:  "v1.13.5",
+				ClusterNodeKernelVersionLabel: "6.18.44-talos",
+				ClusterNodeArchLabel:          "arm64",
+				ClusterNodeSecureBootLabel:    "true",
+			},
+			expectedAnnotations: map[string]string{
+				ClusterNodeExtensionsAnnotation:    "gvisor,iscsi-tools",
+				ClusterNodeNetworkLinkAnnotation:   "eth0",
+				ClusterNodeNetworkSpeedAnnotation:  "10000",
+				ClusterNodeNetworkDriverAnnotation: "ixgbe",
+			},
+		},
+		{
+			name:   "unavailable and invalid values are skipped",
+			labels: cloudConfigLabels{MachineType: true, Version: true, Arch: true, SecureBoot: true, Network: true},
+			nodeIP: "192.168.0.2",
+			expectedLabels: map[string]string{
+				ClusterNodeMachineTypeLabel: "controlplane",
+			},
+			expectedAnnotations: map[string]string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cloudConfig{}
+			cfg.Global.Labels = tt.labels
+
+			client, err := newClient(&cfg, talos)
+			assert.NoError(t, err)
+
+			labels, annotations := talosNodeWellKnown(t.Context(), client, tt.nodeIP)
+			assert.Equal(t, tt.expectedLabels, labels)
+			assert.Equal(t, tt.expectedAnnotations, annotations)
+		})
+	}
+}
+
+func TestNetworkAnnotations(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		ClusterNodeNetworkLinkAnnotation:   "bond0.100",
+		ClusterNodeNetworkSpeedAnnotation:  "50000",
+		ClusterNodeNetworkDriverAnnotation: "mlx5_core",
+		ClusterNodeNetworkBondAnnotation:   "bond0/802.3ad",
+		ClusterNodeNetworkVLANAnnotation:   "100",
+	}, networkAnnotations(transformer.NetworkTopology{
+		Link:      "bond0.100",
+		SpeedMbit: 50000,
+		Driver:    "mlx5_core",
+		Bond:      "bond0",
+		BondMode:  "802.3ad",
+		VLAN:      100,
+	}))
+}
+
 func TestCSRNodeChecks(t *testing.T) {
 	ctx := t.Context()
 	nodes := &v1.NodeList{
@@ -4,17 +4,19 @@ import (
 	"context"
 	"fmt"
 	"maps"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
-	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
 	"github.com/siderolabs/talos-cloud-controller-manager/pkg/utils/net"
-	"github.com/siderolabs/talos-cloud-controller-manager/pkg/utils/platform"
 	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
 	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	cloudprovider "k8s.io/cloud-provider"
 	cloudproviderapi "k8s.io/cloud-provider/api"
 	"k8s.io/klog/v2"
@@ -22,6 +24,27 @@ import (
 
 type instances struct {
 	c *client
+
+	// machineStages stores the last observed Talos machine stage of the nodes.
+	machineStagesLock sync.Mutex
+	machineStages     map[string]machineStage
+
+	// instanceFailures stores the consecutive failed existence checks of the nodes.
+	instanceFailuresLock sync.Mutex
+	instanceFailures     map[string]instanceFailure
+}
+
+// machineStage is the Talos machine stage observed at a point in time.
+type machineStage struct {
+	stage     runtime.MachineStage
+	timestamp time.Time
+}
+
+// instanceFailure is the consecutive failed existence checks of the node.
+type instanceFailure struct {
+	count int
+	since time.Time
+	last  time.Time
 }
 
 var (
@@ -36,17 +59,23 @@ var (
 	}
 
 	initializedNodeDelay = time.Second * 30
+
+	// machineRebootDelay is the time during which an unreachable node is considered rebooting
+	// after the last observed reboot or upgrade stage.
+	machineRebootDelay = time.Minute * 10
 )
 
 func newInstances(client *client) *instances {
 	return &instances{
-		c: client,
+		c:                client,
+		machineStages:    make(map[string]machineStage),
+		instanceFailures: make(map[string]instanceFailure),
 	}
 }
 
 // InstanceExists returns true if the instance for the given node exists according to the cloud provider.
 // Use the node.name or node.spec.providerID field to find the node in the cloud provider.
-func (i *instances) InstanceExists(_ context.Context, node *v1.Node) (bool, error) {
+func (i *instances) InstanceExists(ctx context.Context, node *v1.Node) (bool, error) {
 	klog.V(4).InfoS("instances.InstanceExists() called", "node", klog.KRef("", node.Name))
 
 	if node.Spec.ProviderID == "" {
@@ -78,19 +107,225 @@ func (i *instances) InstanceExists(_ context.Context, node *v1.Node) (bool, erro
 		// node resource must be deleted, so that it can be recreated with the new information
 		if node.Labels[ClusterNodePlatformLabel] == "gcp" && // nolint:goconst
 			node.Labels[ClusterNodeLifeCycleLabel] == ClusterNodeLifeCycleLabelSpot {
+			i.forgetNode(node.Name)
+
 			return false, nil
 		}
 	}
 
+	checkUnreachable := i.c.config().Global.InstanceExists.enabled(node.Labels[ClusterNodePlatformLabel])
+	checkReplacement := i.c.config().Global.machineReplacementAction() != MachineReplacementActionIgnore && hasMachineAnnotations(node)
+
+	if checkUnreachable || checkReplacement {
+		exists := i.instanceExists(ctx, node, checkUnreachable)
+		if !exists {
+			i.forgetNode(node.Name)
+		}
+
+		return exists, nil
+	}
+
 	return true, nil
 }
 
+// forgetNode removes the recorded state of the node, which is considered deleted.
+func (i *instances) forgetNode(name string) {
+	i.machineStagesLock.Lock()
+	defer i.machineStagesLock.Unlock()
+
+	delete(i.machineStages, name)
+}
+
+// instanceExists checks the Talos API of the node and compares the machine identity with the recorded one.
+// Unreachable node is considered deleted only after the failure threshold and grace period.
+func (i *instances) instanceExists(ctx context.Context, node *v1.Node, checkUnreachable bool) bool {
+	nodeIPs := getTalosNodeIPs(i.c.config(), node)
+	if ip := providerIDNodeIP(node.Spec.ProviderID); ip != "" && !slices.Contains(nodeIPs, ip) {
+		nodeIPs = append(nodeIPs, ip)
+	}
+
+	var (
+		sysInfo *hardware.SystemInformationSpec
+		err     error
+	)
+
+	mc := metrics.NewMetricContext(hardware.SystemInformationID)
+
+	for _, ip := range nodeIPs {
+		sysInfo, err = i.c.talos.GetNodeSystemInfo(ctx, ip)
+		if mc.ObserveRequest(err) == nil {
+			break
+		}
+
+		klog.V(4).InfoS("instances.InstanceExists() error getting system info from the node", "node", klog.KRef("", node.Name), "err", err)
+	}
+
+	i.instanceFailuresLock.Lock()
+	defer i.instanceFailuresLock.Unlock()
+
+	if sysInfo != nil {
+		delete(i.instanceFailures, node.Name)
+
+		if i.c.config().Global.machineReplacementAction() != MachineReplacementActionIgnore && isMachineReplaced(node, sysInfo) {
+			klog.InfoS("instances.InstanceExists() machine was replaced", "node", klog.KRef("", node.Name),
+				"uuid", node.Annotations[ClusterNodeMachineUUIDAnnotation], "machineUUID", sysInfo.UUID)
+
+			recordMachineReplaced(i.c, node, sysInfo)
+
+			if i.c.config().Global.machineReplacementAction() == MachineReplacementActionDelete {
+				if err := i.c.kclient.CoreV1().Nodes().Delete(ctx, node.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+					klog.ErrorS(err, "instances.InstanceExists() error deleting the node", "node", klog.KRef("", node.Name))
+				}
+			}
+
+			return false
+		}
+
+		return true
+	}
+
+	if !checkUnreachable {
+		return true
+	}
+
+	now := time.Now()
+
+	failure, ok := i.instanceFailures[node.Name]
+	failure = nextInstanceFailure(i.c.config().Global.InstanceExists, failure, ok && !isNodeReady(node), now)
+	i.instanceFailures[node.Name] = failure
+
+	if isInstanceDeleted(i.c.config().Global.InstanceExists, failure, now) {
+		klog.InfoS("instances.InstanceExists() node is unreachable", "node", klog.KRef("", node.Name), "failures", failure.count, "since", failure.since)
+
+		delete(i.instanceFailures, node.Name)
+
+		return false
+	}
+
+	return true
+}
+
+// nextInstanceFailure records the failed check. The node is checked only while it is NotReady,
+// so a new failure window is started if the node was seen Ready or the last failure is older than the grace period.
+func nextInstanceFailure(cfg cloudConfigInstanceExists, failure instanceFailure, ok bool, now time.Time) instanceFailure {
+	if !ok || now.Sub(failure.last) > cfg.gracePeriod() {
+		failure = instanceFailure{since: now}
+	}
+
+	failure.count++
+	failure.last = now
+
+	return failure
+}
+
+// isNodeReady returns true if the node has the Ready condition.
+func isNodeReady(node *v1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// isInstanceDeleted returns true if the failed checks reached the threshold and the grace period has passed.
+func isInstanceDeleted(cfg cloudConfigInstanceExists, failure instanceFailure, now time.Time) bool {
+	return failure.count >= cfg.failureThreshold() && now.Sub(failure.since) >= cfg.gracePeriod()
+}
+
 // InstanceShutdown returns true if the instance is shutdown according to the cloud provider.
 // Use the node.name or node.spec.providerID field to find the node in the cloud provider.
-func (i *instances) InstanceShutdown(_ context.Context, node *v1.Node) (bool, error) {
+func (i *instances) InstanceShutdown(ctx context.Context, node *v1.Node) (bool, error) {
 	klog.V(4).InfoS("instances.InstanceShutdown() called", "node", klog.KRef("", node.Name))
 
-	return false, nil
+	if node.Spec.ProviderID == "" {
+		return false, nil
+	}
+
+	nodeIPs := getTalosNodeIPs(i.c.config(), node)
+	if len(nodeIPs) == 0 {
+		return false, nil
+	}
+
+	var (
+		status *runtime.MachineStatusSpec
+		err    error
+	)
+
+	mc := metrics.NewMetricContext("machinestatus")
+
+	for _, ip := range nodeIPs {
+		status, err = i.c.talos.GetNodeMachineStatus(ctx, ip)
+		if mc.ObserveRequest(err) == nil {
+			break
+		}
+
+		klog.V(4).InfoS("instances.InstanceShutdown() error getting machine status from the node", "node", klog.KRef("", node.Name), "err", err)
+	}
+
+	now := time.Now()
+
+	i.machineStagesLock.Lock()
+	defer i.machineStagesLock.Unlock()
+
+	last, ok := i.machineStages[node.Name]
+
+	var lastStage *machineStage
+	if ok {
+		lastStage = &last
+	}
+
+	shutdown := isMachineShutdown(status, lastStage, now)
+
+	if status != nil {
+		i.machineStages[node.Name] = machineStage{stage: status.Stage, timestamp: now}
+	}
+
+	klog.V(4).InfoS("instances.InstanceShutdown() machine status", "node", klog.KRef("", node.Name), "status", status, "shutdown", shutdown)
+
+	return shutdown, nil
+}
+
+// recordMachineStage records the machine stage of the reachable node on each sync,
+// so the stage is known by the InstanceShutdown check after the node becomes unreachable and NotReady.
+func (i *instances) recordMachineStage(ctx context.Context, node *v1.Node, nodeIP string) {
+	mc := metrics.NewMetricContext("machinestatus")
+
+	status, err := i.c.talos.GetNodeMachineStatus(ctx, nodeIP)
+	if mc.ObserveRequest(err) != nil {
+		klog.V(4).InfoS("instances.InstanceMetadata() error getting machine status from the node", "node", klog.KRef("", node.Name), "err", err)
+
+		return
+	}
+
+	i.machineStagesLock.Lock()
+	defer i.machineStagesLock.Unlock()
+
+	i.machineStages[node.Name] = machineStage{stage: status.Stage, timestamp: time.Now()}
+}
+
+// isMachineShutdown returns true if the machine is powered off.
+// A machine that is not reachable is considered powered off, unless it was rebooting or upgrading recently.
+// The state of a machine that is not reachable and was never observed is unknown, it is not considered powered off.
+func isMachineShutdown(status *runtime.MachineStatusSpec, last *machineStage, now time.Time) bool {
+	if status != nil {
+		return status.Stage == runtime.MachineStageShuttingDown
+	}
+
+	if last == nil {
+		return false
+	}
+
+	switch last.stage { //nolint:exhaustive
+	case runtime.MachineStageRebooting,
+		runtime.MachineStageUpgrading,
+		runtime.MachineStageBooting,
+		runtime.MachineStageInstalling:
+		return now.Sub(last.timestamp) > machineRebootDelay
+	default:
+		return true
+	}
 }
 
 // InstanceMetadata returns the instance's metadata. The values returned in InstanceMetadata are
@@ -102,85 +337,64 @@ func (i *instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloud
 	klog.V(4).InfoS("instances.InstanceMetadata() called", "node", klog.KRef("", node.Name))
 
 	if providedIP, ok := node.ObjectMeta.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr]; ok {
-		nodeIPs := net.PreferredDualStackNodeIPs(i.c.config.Global.PreferIPv6, strings.Split(providedIP, ","))
-
-		var (
-			meta   *runtime.PlatformMetadataSpec
-			err    error
-			nodeIP string
-		)
+		nodeIPs := net.PreferredDualStackNodeIPs(i.c.config().Global.PreferIPv6, strings.Split(providedIP, ","))
 
-		mc := metrics.NewMetricContext(runtime.PlatformMetadataID)
+		nm, err := getNodeMetadata(ctx, i.c, node, nodeIPs)
+		if err != nil {
+			return nil, err
+		}
 
-		for _, ip := range nodeIPs {
-			meta, err = i.c.talos.GetNodeMetadata(ctx, ip)
-			if mc.ObserveRequest(err) == nil {
-				nodeIP = ip
+		meta, sysInfo, nodeSpec := nm.meta, nm.sysInfo, nm.nodeSpec
 
-				break
-			}
+		i.recordMachineStage(ctx, node, nm.nodeIP)
 
-			klog.ErrorS(err, "error getting metadata from the node", "node", klog.KRef("", node.Name))
-		}
+		// The recorded machine identity is kept, unless the replacement is ignored,
+		// the node is removed by the cloud-node-lifecycle controller with the InstanceExists check.
+		machineReplaced := isMachineReplaced(node, sysInfo)
+		if machineReplaced {
+			klog.InfoS("instances.InstanceMetadata() machine was replaced", "node", klog.KRef("", node.Name),
+				"uuid", node.Annotations[ClusterNodeMachineUUIDAnnotation], "machineUUID", sysInfo.UUID,
+				"action", i.c.config().Global.machineReplacementAction())
 
-		if meta == nil {
-			return nil, fmt.Errorf("error getting metadata from the node %s", node.Name)
+			recordMachineReplaced(i.c, node, sysInfo)
 		}
 
-		klog.V(5).InfoS("instances.InstanceMetadata()", "node", klog.KRef("", node.Name), "resource", meta)
+		mc := metrics.NewMetricContext("addresses")
 
-		if meta.ProviderID == "" {
-			meta.ProviderID = fmt.Sprintf("%s://%s/%s", ProviderName, meta.Platform, nodeIP)
+		ifaces, err := i.c.talos.GetNodeIfaces(ctx, nm.nodeIP)
+		if mc.ObserveRequest(err) != nil {
+			return nil, fmt.Errorf("error getting interfaces list from the node %s: %w", node.Name, err)
 		}
 
-		// Fix for Azure, resource group name must be lower case.
-		// Since Talos 1.8 fixed it, we can remove this code in the future.
-		if meta.Platform == "azure" {
-			meta.ProviderID, err = platform.AzureConvertResourceGroupNameToLower(meta.ProviderID)
-			if err != nil {
-				return nil, fmt.Errorf("error converting resource group name to lower case: %w", err)
-			}
-		}
+		addresses := getNodeAllAddresses(i.c.config(), node.Name, meta, &nodeSpec.Features, nodeIPs, ifaces)
 
-		if meta.Hostname == "" {
-			meta.Hostname = node.Name
+		if nodeSpec.Annotations == nil {
+			nodeSpec.Annotations = make(map[string]string)
 		}
 
-		var sysInfo *hardware.SystemInformationSpec
+		wellKnownLabels, wellKnownAnnotations := talosNodeWellKnown(ctx, i.c, nm.nodeIP)
 
-		if len(i.c.config.Transformations) > 0 {
-			msys := metrics.NewMetricContext(hardware.SystemInformationID)
-
-			sysInfo, err = i.c.talos.GetNodeSystemInfo(ctx, nodeIP)
-			if msys.ObserveRequest(err) != nil {
-				return nil, fmt.Errorf("error getting system info from the node %s: %w", node.Name, err)
+		for k, v := range wellKnownAnnotations {
+			if _, ok := nodeSpec.Annotations[k]; !ok {
+				nodeSpec.Annotations[k] = v
 			}
 		}
 
-		mct := metrics.NewMetricContext("transformer")
-
-		nodeSpec, err := transformer.TransformNode(i.c.config.Transformations, meta, sysInfo)
-		if mct.ObserveTransformer(err) != nil {
-			return nil, fmt.Errorf("error transforming node: %w", err)
-		}
-
-		if nodeSpec == nil {
-			nodeSpec = &transformer.NodeSpec{}
+		if !machineReplaced || i.c.config().Global.machineReplacementAction() == MachineReplacementActionIgnore {
+			maps.Copy(nodeSpec.Annotations, machineAnnotations(node, sysInfo, machineReplaced))
 		}
 
-		mc = metrics.NewMetricContext("addresses")
+		if i.c.config().Global.TransformationsTrace.Annotation {
+			value, err := nodeTraceAnnotation(nm.trace)
+			if err != nil {
+				return nil, err
+			}
 
-		ifaces, err := i.c.talos.GetNodeIfaces(ctx, nodeIP)
-		if mc.ObserveRequest(err) != nil {
-			return nil, fmt.Errorf("error getting interfaces list from the node %s: %w", node.Name, err)
+			nodeSpec.Annotations[ClusterNodeTransformationsTraceAnnotation] = value
 		}
 
-		addresses := getNodeAddresses(i.c.config, meta.Platform, &nodeSpec.Features, nodeIPs, ifaces)
-
-		addresses = append(addresses, v1.NodeAddress{Type: v1.NodeHostName, Address: node.Name})
-
-		if meta.Hostname != "" && strings.IndexByte(meta.Hostname, '.') > 0 {
-			addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalDNS, Address: meta.Hostname})
+		if i.c.config().Global.TransformationsTrace.Events && taintExists(node.Spec.Taints, uninitializedTaint) {
+			recordNodeTrace(i.c, node, nm.trace)
 		}
 
 		if len(nodeSpec.Annotations) > 0 {
@@ -201,7 +415,8 @@ func (i *instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloud
 			}
 		}
 
-		nodeLabels := setTalosNodeLabels(i.c, meta)
+		nodeLabels := setTalosNodeLabels(ctx, i.c, nm.nodeIP, meta)
+		maps.Copy(nodeLabels, wellKnownLabels)
 
 		if len(nodeSpec.Labels) > 0 {
 			klog.V(4).InfoS("instances.InstanceMetadata() node has labels", "node", klog.KRef("", node.Name), "labels", nodeSpec.Labels)
@@ -226,3 +441,53 @@ func (i *instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloud
 
 	return &cloudprovider.InstanceMetadata{}, nil
 }
+
+// isMachineReplaced returns true if the machine identity differs from the one recorded at node registration.
+func isMachineReplaced(node *v1.Node, sysInfo *hardware.SystemInformationSpec) bool {
+	if sysInfo == nil {
+		return false
+	}
+
+	if uuid := node.Annotations[ClusterNodeMachineUUIDAnnotation]; uuid != "" && sysInfo.UUID != "" && uuid != sysInfo.UUID {
+		return true
+	}
+
+	if serial := node.Annotations[ClusterNodeMachineSerialAnnotation]; serial != "" && sysInfo.SerialNumber != "" && serial != sysInfo.SerialNumber {
+		return true
+	}
+
+	return false
+}
+
+// machineAnnotations returns the machine identity annotations, which are not recorded yet.
+// All annotations are overwritten if the machine was replaced.
+func machineAnnotations(node *v1.Node, sysInfo *hardware.SystemInformationSpec, replaced bool) map[string]string {
+	annotations := map[string]string{}
+
+	if sysInfo == nil {
+		return annotations
+	}
+
+	for k, v := range map[string]string{
+		ClusterNodeMachineUUIDAnnotation:   sysInfo.UUID,
+		ClusterNodeMachineSerialAnnotation: sysInfo.SerialNumber,
+	} {
+		if _, ok := node.Annotations[k]; v != "" && (!ok || replaced) {
+			annotations[k] = v
+		}
+	}
+
+	return annotations
+}
+
+func hasMachineAnnotations(node *v1.Node) bool {
+	return node.Annotations[ClusterNodeMachineUUIDAnnotation] != "" || node.Annotations[ClusterNodeMachineSerialAnnotation] != ""
+}
+
+func recordMachineReplaced(c *client, node *v1.Node, sysInfo *hardware.SystemInformationSpec) {
+	recordNodeEvent(c, node, v1.EventTypeWarning, "MachineReplaced",
+		"Node %s is backed by a different machine, recorded UUID %q serial %q, found UUID %q serial %q",
+		node.Name,
+		node.Annotations[ClusterNodeMachineUUIDAnnotation], node.Annotations[ClusterNodeMachineSerialAnnotation],
+		sysInfo.UUID, sysInfo.SerialNumber)
+}
@@ -2,13 +2,28 @@ package talos
 
 import (
 	"context"
+	"maps"
+	"net/netip"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/nodeselector"
+	talosfake "github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 	cloudprovider "k8s.io/cloud-provider"
+	cloudproviderapi "k8s.io/cloud-provider/api"
 )
 
 type ccmTestSuite struct {
@@ -37,45 +52,646 @@ func (ts *ccmTestSuite) TestInstanceShutdown() {
 	ts.Require().False(exists)
 }
 
-func TestInstanceMetadata(t *testing.T) {
-	t.Setenv("TALOSCONFIG", "../../hack/talosconfig")
+func TestIsMachineShutdown(t *testing.T) {
+	now := time.Now()
+
+	for _, tt := range []struct {
+		name     string
+		status   *runtime.MachineStatusSpec
+		last     *machineStage
+		expected bool
+	}{
+		{
+			name:     "machine is running",
+			status:   &runtime.MachineStatusSpec{Stage: runtime.MachineStageRunning},
+			expected: false,
+		},
+		{
+			name:     "machine is shutting down",
+			status:   &runtime.MachineStatusSpec{Stage: runtime.MachineStageShuttingDown},
+			expected: true,
+		},
+		{
+			name:     "machine is rebooting",
+			status:   &runtime.MachineStatusSpec{Stage: runtime.MachineStageRebooting},
+			expected: false,
+		},
+		{
+			name:     "machine is unreachable and was never observed",
+			expected: false,
+		},
+		{
+			name:     "machine is unreachable after shutting down",
+			last:     &machineStage{stage: runtime.MachineStageShuttingDown, timestamp: now.Add(-time.Minute)},
+			expected: true,
+		},
+		{
+			name:     "machine is unreachable after reboot",
+			last:     &machineStage{stage: runtime.MachineStageRebooting, timestamp: now.Add(-time.Minute)},
+			expected: false,
+		},
+		{
+			name:     "machine is unreachable after upgrade",
+			last:     &machineStage{stage: runtime.MachineStageUpgrading, timestamp: now.Add(-time.Minute)},
+			expected: false,
+		},
+		{
+			name:     "machine is unreachable long time after reboot",
+			last:     &machineStage{stage: runtime.MachineStageRebooting, timestamp: now.Add(-time.Hour)},
+			expected: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isMachineShutdown(tt.status, tt.last, now))
+		})
+	}
+}
 
+func TestInstanceExistsForgetNode(t *testing.T) {
 	cfg := cloudConfig{}
 
-	ctx := t.Context()
-	client, err := newClient(ctx, &cfg)
-	assert.NoError(t, err)
+	client, err := newClient(&cfg, talosfake.NewClient("test-cluster", nil, nil, nil))
+	require.NoError(t, err)
 
 	i := newInstances(client)
+	i.machineStages["node-1"] = machineStage{stage: runtime.MachineStageRunning, timestamp: time.Now()}
+
+	exists, err := i.InstanceExists(t.Context(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "node-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			Labels: map[string]string{
+				ClusterNodePlatformLabel:  "gcp",
+				ClusterNodeLifeCycleLabel: ClusterNodeLifeCycleLabelSpot,
+			},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "talos://gcp/192.168.0.1",
+			Taints:     []v1.Taint{*notReadyTaint},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.False(t, exists)
+	assert.NotContains(t, i.machineStages, "node-1")
+}
+
+func TestInstanceShutdownPoweredOff(t *testing.T) {
+	talos := talosfake.NewClient("test-cluster", nil, nil, map[string]*talosfake.Node{
+		"192.168.0.1": {
+			Metadata:      &runtime.PlatformMetadataSpec{Platform: "metal", Hostname: "node-1"},
+			SystemInfo:    &hardware.SystemInformationSpec{},
+			MachineStatus: &runtime.MachineStatusSpec{Stage: runtime.MachineStageRunning},
+		},
+	})
+
+	client, err := newClient(&cloudConfig{}, talos)
+	require.NoError(t, err)
+
+	client.kclient = fake.NewClientset()
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node-1",
+			Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.1"},
+		},
+		Spec: v1.NodeSpec{ProviderID: "talos://metal/192.168.0.1"},
+		Status: v1.NodeStatus{
+			Addresses:  []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.1"}},
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+		},
+	}
+
+	i := newInstances(client)
+
+	// The stage of the Ready node is recorded by the periodic node sync.
+	_, err = i.InstanceMetadata(t.Context(), node)
+	require.NoError(t, err)
+	assert.Equal(t, runtime.MachineStageRunning, i.machineStages["node-1"].stage)
+
+	// The node is powered off, it is NotReady and unreachable.
+	talos.DeleteNode("192.168.0.1")
+
+	node.Spec.Taints = []v1.Taint{*notReadyTaint}
+	node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionUnknown}}
+
+	shutdown, err := i.InstanceShutdown(t.Context(), node)
+	require.NoError(t, err)
+	assert.True(t, shutdown)
+}
+
+func TestIsInstanceDeleted(t *testing.T) {
+	now := time.Now()
+
+	for _, tt := range []struct {
+		name     string
+		cfg      cloudConfigInstanceExists
+		failure  instanceFailure
+		expected bool
+	}{
+		{
+			name:     "first failure",
+			failure:  instanceFailure{count: 1, since: now},
+			expected: false,
+		},
+		{
+			name:     "failure threshold reached in grace period",
+			failure:  instanceFailure{count: 3, since: now.Add(-time.Minute)},
+			expected: false,
+		},
+		{
+			name:     "grace period passed below failure threshold",
+			failure:  instanceFailure{count: 2, since: now.Add(-time.Hour)},
+			expected: false,
+		},
+		{
+			name:     "failure threshold reached after grace period",
+			failure:  instanceFailure{count: 3, since: now.Add(-time.Hour)},
+			expected: true,
+		},
+		{
+			name:     "custom failure threshold and grace period",
+			cfg:      cloudConfigInstanceExists{GracePeriod: time.Minute, FailureThreshold: 1},
+			failure:  instanceFailure{count: 1, since: now.Add(-2 * time.Minute)},
+			expected: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isInstanceDeleted(tt.cfg, tt.failure, now))
+		})
+	}
+}
+
+func TestNextInstanceFailure(t *testing.T) {
+	now := time.Now()
+
+	for _, tt := range []struct {
+		name     string
+		failure  instanceFailure
+		ok       bool
+		expected instanceFailure
+	}{
+		{
+			name:     "first failure",
+			expected: instanceFailure{count: 1, since: now, last: now},
+		},
+		{
+			name:     "consecutive failure",
+			failure:  instanceFailure{count: 2, since: now.Add(-time.Minute), last: now.Add(-10 * time.Second)},
+			ok:       true,
+			expected: instanceFailure{count: 3, since: now.Add(-time.Minute), last: now},
+		},
+		{
+			name:     "failure after recovery",
+			failure:  instanceFailure{count: 2, since: now.Add(-14 * 24 * time.Hour), last: now.Add(-14 * 24 * time.Hour)},
+			ok:       true,
+			expected: instanceFailure{count: 1, since: now, last: now},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, nextInstanceFailure(cloudConfigInstanceExists{}, tt.failure, tt.ok, now))
+		})
+	}
+}
+
+func TestInstanceExistsFailureWindow(t *testing.T) {
+	cfg := cloudConfig{
+		Global: cloudConfigGlobal{
+			InstanceExists: cloudConfigInstanceExists{Platforms: []string{"*"}, FailureThreshold: 2, GracePeriod: time.Minute},
+		},
+	}
+
+	talos := talosfake.NewClient("test-cluster", nil, nil, map[string]*talosfake.Node{
+		"192.168.0.1": {SystemInfo: &hardware.SystemInformationSpec{}},
+	})
+
+	client, err := newClient(&cfg, talos)
+	require.NoError(t, err)
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "node-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		},
+		Spec: v1.NodeSpec{ProviderID: "talos://metal/192.168.0.1"},
+	}
 
 	for _, tt := range []struct {
 		name     string
-		node     *v1.Node
-		expected *cloudprovider.InstanceMetadata
+		failure  *instanceFailure
+		ready    bool
+		expected bool
+	}{
+		{
+			name:     "failures weeks ago, one failure after recovery",
+			failure:  &instanceFailure{count: 5, since: time.Now().Add(-14 * 24 * time.Hour), last: time.Now().Add(-14 * 24 * time.Hour)},
+			expected: true,
+		},
+		{
+			name:     "failures in the grace period, the node was seen ready",
+			failure:  &instanceFailure{count: 5, since: time.Now().Add(-time.Hour), last: time.Now().Add(-time.Second)},
+			ready:    true,
+			expected: true,
+		},
+		{
+			name:     "consecutive failures",
+			failure:  &instanceFailure{count: 5, since: time.Now().Add(-time.Hour), last: time.Now().Add(-time.Second)},
+			expected: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			talos.DeleteNode("192.168.0.1")
+
+			i := newInstances(client)
+			i.instanceFailures[node.Name] = *tt.failure
+
+			node := node.DeepCopy()
+			if tt.ready {
+				node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+			}
+
+			exists, err := i.InstanceExists(t.Context(), node)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, exists)
+
+			if tt.expected {
+				assert.Equal(t, 1, i.instanceFailures[node.Name].count)
+			}
+		})
+	}
+
+	// failure, recovery, then one more failure
+	i := newInstances(client)
+
+	exists, err := i.InstanceExists(t.Context(), node)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 1, i.instanceFailures[node.Name].count)
+
+	talos.SetNode("192.168.0.1", &talosfake.Node{SystemInfo: &hardware.SystemInformationSpec{}})
+
+	exists, err = i.InstanceExists(t.Context(), node)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.NotContains(t, i.instanceFailures, node.Name)
+
+	talos.DeleteNode("192.168.0.1")
+
+	exists, err = i.InstanceExists(t.Context(), node)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 1, i.instanceFailures[node.Name].count)
+}
+
+func TestInstanceExistsMachineReplaced(t *testing.T) {
+	talos := talosfake.NewClient("test-cluster", nil, nil, map[string]*talosfake.Node{
+		"192.168.0.1": {SystemInfo: &hardware.SystemInformationSpec{UUID: "e8e8c388-5812-4db0-87e2-ad1fee51a1c1"}},
+	})
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "node-1",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			Annotations: map[string]string{
+				ClusterNodeMachineUUIDAnnotation: "00000000-0000-0000-0000-000000000000",
+			},
+		},
+		Spec: v1.NodeSpec{ProviderID: "talos://metal/192.168.0.1"},
+	}
+
+	for _, tt := range []struct {
+		name            string
+		action          string
+		expected        bool
+		expectedDeleted bool
+	}{
+		{
+			name:     "not exists",
+			action:   MachineReplacementActionNotExists,
+			expected: false,
+		},
+		{
+			name:            "delete",
+			action:          MachineReplacementActionDelete,
+			expected:        false,
+			expectedDeleted: true,
+		},
+		{
+			name:     "ignore",
+			action:   MachineReplacementActionIgnore,
+			expected: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cloudConfig{Global: cloudConfigGlobal{MachineReplacementAction: tt.action}}
+
+			client, err := newClient(&cfg, talos)
+			require.NoError(t, err)
+
+			client.kclient = fake.NewClientset(node)
+
+			exists, err := newInstances(client).InstanceExists(t.Context(), node)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, exists)
+
+			_, err = client.kclient.CoreV1().Nodes().Get(t.Context(), node.Name, metav1.GetOptions{})
+			assert.Equal(t, tt.expectedDeleted, apierrors.IsNotFound(err))
+		})
+	}
+}
+
+func TestIsMachineReplaced(t *testing.T) {
+	sysInfo := &hardware.SystemInformationSpec{
+		UUID:         "e8e8c388-5812-4db0-87e2-ad1fee51a1c1",
+		SerialNumber: "SN-1234",
+	}
+
+	for _, tt := range []struct {
+		name                string
+		annotations         map[string]string
+		sysInfo             *hardware.SystemInformationSpec
+		expected            bool
+		expectedAnnotations map[string]string
 	}{
 		{
-			name: "node does not have --cloud-provider=external",
-			node: &v1.Node{
-				Spec: v1.NodeSpec{},
+			name:                "node has no system info",
+			expected:            false,
+			expectedAnnotations: map[string]string{},
+		},
+		{
+			name:     "node registration",
+			sysInfo:  sysInfo,
+			expected: false,
+			expectedAnnotations: map[string]string{
+				ClusterNodeMachineUUIDAnnotation:   "e8e8c388-5812-4db0-87e2-ad1fee51a1c1",
+				ClusterNodeMachineSerialAnnotation: "SN-1234",
+			},
+		},
+		{
+			name: "node has the same machine",
+			annotations: map[string]string{
+				ClusterNodeMachineUUIDAnnotation: "e8e8c388-5812-4db0-87e2-ad1fee51a1c1",
+			},
+			sysInfo:  sysInfo,
+			expected: false,
+			expectedAnnotations: map[string]string{
+				ClusterNodeMachineSerialAnnotation: "SN-1234",
+			},
+		},
+		{
+			name: "node has different machine UUID",
+			annotations: map[string]string{
+				ClusterNodeMachineUUIDAnnotation:   "7ab1c5b2-3b49-4a5b-9d3c-6f1f3c2d5e9a",
+				ClusterNodeMachineSerialAnnotation: "SN-1234",
+			},
+			sysInfo:  sysInfo,
+			expected: true,
+			expectedAnnotations: map[string]string{
+				ClusterNodeMachineUUIDAnnotation:   "e8e8c388-5812-4db0-87e2-ad1fee51a1c1",
+				ClusterNodeMachineSerialAnnotation: "SN-1234",
+			},
+		},
+		{
+			name: "node has different machine serial",
+			annotations: map[string]string{
+				ClusterNodeMachineUUIDAnnotation:   "e8e8c388-5812-4db0-87e2-ad1fee51a1c1",
+				ClusterNodeMachineSerialAnnotation: "SN-4321",
+			},
+			sysInfo:  sysInfo,
+			expected: true,
+			expectedAnnotations: map[string]string{
+				ClusterNodeMachineUUIDAnnotation:   "e8e8c388-5812-4db0-87e2-ad1fee51a1c1",
+				ClusterNodeMachineSerialAnnotation: "SN-1234",
+			},
+		},
+		{
+			name: "machine has no serial",
+			annotations: map[string]string{
+				ClusterNodeMachineUUIDAnnotation:   "e8e8c388-5812-4db0-87e2-ad1fee51a1c1",
+				ClusterNodeMachineSerialAnnotation: "SN-1234",
+			},
+			sysInfo:             &hardware.SystemInformationSpec{UUID: "e8e8c388-5812-4db0-87e2-ad1fee51a1c1"},
+			expected:            false,
+			expectedAnnotations: map[string]string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Annotations: tt.annotations}}
+
+			replaced := isMachineReplaced(node, tt.sysInfo)
+			assert.Equal(t, tt.expected, replaced)
+			assert.Equal(t, tt.expectedAnnotations, machineAnnotations(node, tt.sysInfo, replaced))
+		})
+	}
+}
+
+func TestInstanceMetadata(t *testing.T) {
+	cfg := cloudConfig{
+		Transformations: []transformer.NodeTerm{
+			{
+				NodeSelector: []nodeselector.NodeSelectorTerm{
+					{
+						MatchExpressions: []nodeselector.NodeSelectorRequirement{
+							{Key: "Platform", Operator: "In", Values: []string{"metal"}},
+						},
+					},
+				},
+				Labels:           map[string]string{"node.kubernetes.io/storage": "local"},
+				PlatformMetadata: map[string]string{"Zone": "rack-1"},
 			},
+		},
+	}
+
+	node := func(name, providedIP string, annotations map[string]string) *v1.Node {
+		n := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{}},
+		}
+
+		if providedIP != "" {
+			n.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr] = providedIP
+		}
+
+		maps.Copy(n.Annotations, annotations)
+
+		return n
+	}
+
+	sysInfo := &hardware.SystemInformationSpec{UUID: "e8e8c388-5812-4db0-87e2-ad1fee51a1c1", SerialNumber: "SN-1234"}
+
+	talos := talosfake.NewClient("test-cluster", nil, nil, map[string]*talosfake.Node{
+		"192.168.0.1": {
+			Metadata: &runtime.PlatformMetadataSpec{
+				Platform:     "metal",
+				Hostname:     "node-1.example.com",
+				InstanceType: "c1.small",
+				Region:       "region-1",
+			},
+			SystemInfo: sysInfo,
+			Ifaces: []network.AddressStatusSpec{
+				{LinkName: "eth0", Address: netip.MustParsePrefix("192.168.0.1/24")},
+				{LinkName: "eth0", Address: netip.MustParsePrefix("1.2.3.4/24")},
+			},
+		},
+		"192.168.0.2": {
+			Metadata: &runtime.PlatformMetadataSpec{
+				Platform:     "aws",
+				ProviderID:   "aws:///us-east-1a/i-0123456789",
+				InstanceType: "t3.small",
+				Region:       "us-east-1",
+				Zone:         "us-east-1a",
+				Spot:         true,
+			},
+			SystemInfo: &hardware.SystemInformationSpec{},
+			Ifaces: []network.AddressStatusSpec{
+				{LinkName: "external", Address: netip.MustParsePrefix("3.3.3.3/32")},
+			},
+		},
+		"192.168.0.3": {
+			Metadata: &runtime.PlatformMetadataSpec{Platform: "metal"},
+		},
+	})
+
+	for _, tt := range []struct {
+		name                string
+		node                *v1.Node
+		expected            *cloudprovider.InstanceMetadata
+		expectedError       string
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:     "node does not have --cloud-provider=external",
+			node:     node("node-0", "", nil),
 			expected: &cloudprovider.InstanceMetadata{},
 		},
-		// {
-		// 	name: "node has providerID",
-		// 	node: &v1.Node{
-		// 		Spec: v1.NodeSpec{ProviderID: "provider:///id"},
-		// 		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
-		// 			cloudproviderapi.AnnotationAlphaProvidedIPAddr: "127.0.0.1",
-		// 		}},
-		// 	},
-		// 	expected: &cloudprovider.InstanceMetadata{},
-		// },
+		{
+			name: "metal node with public IP",
+			node: node("node-1", "192.168.0.1", nil),
+			expected: &cloudprovider.InstanceMetadata{
+				ProviderID:   "talos://metal/192.168.0.1",
+				InstanceType: "c1.small",
+				NodeAddresses: []v1.NodeAddress{
+					{Type: v1.NodeInternalIP, Address: "192.168.0.1"},
+					{Type: v1.NodeExternalIP, Address: "1.2.3.4"},
+					{Type: v1.NodeHostName, Address: "node-1"},
+					{Type: v1.NodeInternalDNS, Address: "node-1.example.com"},
+				},
+				Zone:   "rack-1",
+				Region: "region-1",
+			},
+			expectedLabels: map[string]string{
+				ClusterNameNodeLabel:         "test-cluster",
+				ClusterNodePlatformLabel:     "metal",
+				"node.kubernetes.io/storage": "local",
+			},
+			expectedAnnotations: map[string]string{
+				cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.1",
+				ClusterNodeMachineUUIDAnnotation:               sysInfo.UUID,
+				ClusterNodeMachineSerialAnnotation:             sysInfo.SerialNumber,
+			},
+		},
+		{
+			name: "cloud spot node",
+			node: node("node-2", "192.168.0.2", nil),
+			expected: &cloudprovider.InstanceMetadata{
+				ProviderID:   "aws:///us-east-1a/i-0123456789",
+				InstanceType: "t3.small",
+				NodeAddresses: []v1.NodeAddress{
+					{Type: v1.NodeInternalIP, Address: "192.168.0.2"},
+					{Type: v1.NodeExternalIP, Address: "3.3.3.3"},
+					{Type: v1.NodeHostName, Address: "node-2"},
+				},
+				Zone:   "us-east-1a",
+				Region: "us-east-1",
+			},
+			expectedLabels: map[string]string{
+				ClusterNameNodeLabel:      "test-cluster",
+				ClusterNodePlatformLabel:  "aws",
+				ClusterNodeLifeCycleLabel: ClusterNodeLifeCycleLabelSpot,
+			},
+			expectedAnnotations: map[string]string{
+				cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.2",
+			},
+		},
+		{
+			name:          "node is not reachable",
+			node:          node("node-4", "192.168.0.4", nil),
+			expectedError: "error getting metadata from the node node-4",
+		},
+		{
+			name: "node does not have system information",
+			node: node("node-3", "192.168.0.3", map[string]string{
+				ClusterNodeMachineUUIDAnnotation: "00000000-0000-0000-0000-000000000000",
+			}),
+			expected: &cloudprovider.InstanceMetadata{
+				ProviderID: "talos://metal/192.168.0.3",
+				NodeAddresses: []v1.NodeAddress{
+					{Type: v1.NodeInternalIP, Address: "192.168.0.3"},
+					{Type: v1.NodeHostName, Address: "node-3"},
+				},
+				Zone: "rack-1",
+			},
+			expectedLabels: map[string]string{
+				ClusterNameNodeLabel:         "test-cluster",
+				ClusterNodePlatformLabel:     "metal",
+				"node.kubernetes.io/storage": "local",
+			},
+			expectedAnnotations: map[string]string{
+				cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.3",
+				ClusterNodeMachineUUIDAnnotation:               "00000000-0000-0000-0000-000000000000",
+			},
+		},
+		{
+			name: "machine was replaced",
+			node: node("node-1", "192.168.0.1", map[string]string{
+				ClusterNodeMachineUUIDAnnotation: "00000000-0000-0000-0000-000000000000",
+			}),
+			expected: &cloudprovider.InstanceMetadata{
+				ProviderID:   "talos://metal/192.168.0.1",
+				InstanceType: "c1.small",
+				NodeAddresses: []v1.NodeAddress{
+					{Type: v1.NodeInternalIP, Address: "192.168.0.1"},
+					{Type: v1.NodeExternalIP, Address: "1.2.3.4"},
+					{Type: v1.NodeHostName, Address: "node-1"},
+					{Type: v1.NodeInternalDNS, Address: "node-1.example.com"},
+				},
+				Zone:   "rack-1",
+				Region: "region-1",
+			},
+			expectedLabels: map[string]string{
+				ClusterNameNodeLabel:         "test-cluster",
+				ClusterNodePlatformLabel:     "metal",
+				"node.kubernetes.io/storage": "local",
+			},
+			expectedAnnotations: map[string]string{
+				cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.1",
+				ClusterNodeMachineUUIDAnnotation:               "00000000-0000-0000-0000-000000000000",
+			},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			metadata, err := i.InstanceMetadata(ctx, tt.node)
+			client, err := newClient(&cfg, talos)
+			require.NoError(t, err)
+
+			client.kclient = fake.NewClientset(tt.node)
+
+			metadata, err := newInstances(client).InstanceMetadata(t.Context(), tt.node)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+
+				return
+			}
 
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expected, metadata)
+
+			if tt.expectedLabels != nil || tt.expectedAnnotations != nil {
+				node, err := client.kclient.CoreV1().Nodes().Get(t.Context(), tt.node.Name, metav1.GetOptions{})
+				require.NoError(t, err)
+
+				assert.Equal(t, tt.expectedLabels, node.Labels)
+				assert.Equal(t, tt.expectedAnnotations, node.Annotations)
+			}
 		})
 	}
 }
@@ -0,0 +1,537 @@
+package talos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/addresspool"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/nodeselector"
+	talosconfig "github.com/siderolabs/talos/pkg/machinery/config/config"
+	networkconfig "github.com/siderolabs/talos/pkg/machinery/config/types/network"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+// virtualIPUpdateConcurrency is the number of the nodes updated in parallel.
+const virtualIPUpdateConcurrency = 8
+
+type loadBalancer struct {
+	c *client
+
+	pools []*addressPool
+
+	mu sync.Mutex
+	// synced is true, when the allocated addresses are restored from the service statuses.
+	synced bool
+	// allocated holds the services of the allocated addresses.
+	allocated map[netip.Addr]types.NamespacedName
+	// vipNodes holds the names of the nodes with the virtual IP config of the address.
+	vipNodes map[netip.Addr]map[string]struct{}
+}
+
+// addressPool is the address pool with its configuration.
+type addressPool struct {
+	*addresspool.Pool
+
+	config cloudConfigAddressPool
+}
+
+func newLoadBalancer(client *client) (*loadBalancer, error) {
+	poolConfigs, err := client.config().Global.LoadBalancer.addressPools()
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]*addressPool, 0, len(poolConfigs))
+
+	for _, cfg := range poolConfigs {
+		ranges, err := cfg.addressRanges()
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := addresspool.NewPool(cfg.Name, ranges)
+		if err != nil {
+			return nil, err
+		}
+
+		pools = append(pools, &addressPool{Pool: pool, config: cfg})
+	}
+
+	return &loadBalancer{
+		c:         client,
+		pools:     pools,
+		allocated: map[netip.Addr]types.NamespacedName{},
+		vipNodes:  map[netip.Addr]map[string]struct{}{},
+	}, nil
+}
+
+// GetLoadBalancer returns whether the specified load balancer exists, and
+// if so, what its status is.
+func (l *loadBalancer) GetLoadBalancer(_ context.Context, clusterName string, service *v1.Service) (*v1.LoadBalancerStatus, bool, error) {
+	klog.V(4).InfoS("loadBalancer.GetLoadBalancer() called", "cluster", clusterName, "service", klog.KObj(service))
+
+	addr, ok := l.serviceAddress(service)
+	if !ok {
+		return nil, false, nil
+	}
+
+	return loadBalancerStatus(addr), true, nil
+}
+
+// GetLoadBalancerName returns the name of the load balancer.
+func (l *loadBalancer) GetLoadBalancerName(_ context.Context, _ string, service *v1.Service) string {
+	return cloudprovider.DefaultLoadBalancerName(service)
+}
+
+// EnsureLoadBalancer allocates the virtual IP from the address pool and assigns it to the selected nodes.
+// The nodes argument is ignored, the virtual IP is assigned to the nodes matched by the node selector.
+func (l *loadBalancer) EnsureLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, _ []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	klog.V(4).InfoS("loadBalancer.EnsureLoadBalancer() called", "cluster", clusterName, "service", klog.KObj(service))
+
+	prevAddr, _ := l.assignedAddress(service)
+
+	addr, err := l.allocateAddress(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+
+	if prevAddr.IsValid() && prevAddr != addr {
+		if err := l.updateVirtualIP(ctx, prevAddr, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.updateVirtualIP(ctx, addr, true); err != nil {
+		return nil, err
+	}
+
+	return loadBalancerStatus(addr), nil
+}
+
+// UpdateLoadBalancer reassigns the virtual IP of the service, if the set of the selected nodes was changed.
+func (l *loadBalancer) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, _ []*v1.Node) error {
+	klog.V(4).InfoS("loadBalancer.UpdateLoadBalancer() called", "cluster", clusterName, "service", klog.KObj(service))
+
+	addr, ok := l.assignedAddress(service)
+	if !ok {
+		return fmt.Errorf("service %s has no load balancer address", klog.KObj(service))
+	}
+
+	return l.updateVirtualIP(ctx, addr, true)
+}
+
+// EnsureLoadBalancerDeleted removes the virtual IP of the service from all nodes and releases the address.
+func (l *loadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error {
+	klog.V(4).InfoS("loadBalancer.EnsureLoadBalancerDeleted() called", "cluster", clusterName, "service", klog.KObj(service))
+
+	addr, ok := l.assignedAddress(service)
+	if !ok {
+		return nil
+	}
+
+	if err := l.updateVirtualIP(ctx, addr, false); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.allocated[addr] == serviceKey(service) {
+		l.releaseAddress(addr)
+	}
+
+	return nil
+}
+
+// serviceAddress returns the load balancer address of the service from the address pools.
+func (l *loadBalancer) serviceAddress(service *v1.Service) (netip.Addr, bool) {
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		addr, err := netip.ParseAddr(ingress.IP)
+		if err == nil && poolByAddress(l.pools, addr) != nil {
+			return addr, true
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// assignedAddress returns the load balancer address of the service,
+// including the allocated address which is not yet reported in the service status.
+func (l *loadBalancer) assignedAddress(service *v1.Service) (netip.Addr, bool) {
+	if addr, ok := l.serviceAddress(service); ok {
+		return addr, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.allocatedAddress(serviceKey(service))
+}
+
+func (l *loadBalancer) allocatedAddress(key types.NamespacedName) (netip.Addr, bool) {
+	for addr, owner := range l.allocated {
+		if owner == key {
+			return addr, true
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// syncAllocated restores the allocated addresses from the service statuses, only once.
+func (l *loadBalancer) syncAllocated(ctx context.Context) error {
+	if l.synced {
+		return nil
+	}
+
+	services, err := l.c.kclient.CoreV1().Services("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing services: %w", err)
+	}
+
+	for idx := range services.Items {
+		service := &services.Items[idx]
+		if service.Spec.Type != v1.ServiceTypeLoadBalancer || service.DeletionTimestamp != nil {
+			continue
+		}
+
+		addr, ok := l.serviceAddress(service)
+		if !ok {
+			continue
+		}
+
+		if owner, ok := l.allocated[addr]; ok {
+			klog.ErrorS(nil, "load balancer address is used by several services", "address", addr, "service", klog.KObj(service), "owner", owner)
+
+			continue
+		}
+
+		klog.V(4).InfoS("service has load balancer address, occupying it in the address pool", "service", klog.KObj(service), "address", addr)
+
+		if err := poolByAddress(l.pools, addr).Occupy(addr); err != nil {
+			return err
+		}
+
+		l.allocated[addr] = serviceKey(service)
+	}
+
+	l.synced = true
+
+	return nil
+}
+
+// servicePools returns the address pools, which the service is allowed to use.
+func (l *loadBalancer) servicePools(service *v1.Service) ([]*addressPool, error) {
+	name := service.Annotations[ServiceAddressPoolAnnotation]
+	pools := []*addressPool{}
+
+	for _, pool := range l.pools {
+		if name != "" && pool.Name() != name {
+			continue
+		}
+
+		if !pool.config.allowed(service.Namespace) {
+			if name != "" {
+				return nil, fmt.Errorf("address pool %s is not allowed in namespace %s", name, service.Namespace)
+			}
+
+			continue
+		}
+
+		pools = append(pools, pool)
+	}
+
+	if len(pools) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("address pool %s not found", name)
+		}
+
+		return nil, fmt.Errorf("no address pools are allowed in namespace %s", service.Namespace)
+	}
+
+	return pools, nil
+}
+
+// allocateAddress returns the address of the service, the requested or already allocated address is preferred.
+// Otherwise, the first free address of the service IP family is allocated from the allowed address pools.
+func (l *loadBalancer) allocateAddress(ctx context.Context, service *v1.Service) (netip.Addr, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.syncAllocated(ctx); err != nil {
+		return netip.Addr{}, err
+	}
+
+	key := serviceKey(service)
+
+	pools, err := l.servicePools(service)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	requested := service.Annotations[ServiceLoadBalancerIPAnnotation]
+	if requested == "" {
+		requested = service.Spec.LoadBalancerIP
+	}
+
+	var addr netip.Addr
+
+	switch {
+	case requested != "":
+		addr, err = netip.ParseAddr(requested)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("failed to parse load balancer IP %s: %w", requested, err)
+		}
+
+		pool := poolByAddress(pools, addr)
+		if pool == nil {
+			return netip.Addr{}, fmt.Errorf("load balancer IP %s is not in the allowed address pools", addr)
+		}
+
+		if owner, ok := l.allocated[addr]; ok && owner != key {
+			return netip.Addr{}, fmt.Errorf("load balancer IP %s is already used by service %s", addr, owner)
+		}
+
+		if err = pool.Occupy(addr); err != nil {
+			return netip.Addr{}, err
+		}
+	default:
+		if current, ok := l.allocatedAddress(key); ok && poolByAddress(pools, current) != nil {
+			return current, nil
+		}
+
+		ipv6 := len(service.Spec.IPFamilies) > 0 && service.Spec.IPFamilies[0] == v1.IPv6Protocol
+
+		for _, pool := range pools {
+			addr, err = pool.AllocateNext(ipv6)
+			if err == nil {
+				break
+			}
+
+			if !errors.Is(err, addresspool.ErrNoAddressesRemaining) {
+				return netip.Addr{}, err
+			}
+		}
+
+		if !addr.IsValid() {
+			return netip.Addr{}, fmt.Errorf("no free addresses in the address pools")
+		}
+	}
+
+	for a, owner := range l.allocated {
+		if owner == key && a != addr {
+			l.releaseAddress(a)
+		}
+	}
+
+	l.allocated[addr] = key
+
+	klog.V(4).InfoS("load balancer address is allocated", "service", klog.KObj(service), "address", addr)
+
+	return addr, nil
+}
+
+func (l *loadBalancer) releaseAddress(addr netip.Addr) {
+	if pool := poolByAddress(l.pools, addr); pool != nil {
+		if err := pool.Release(addr); err != nil {
+			klog.ErrorS(err, "failed to release load balancer address", "address", addr)
+		}
+	}
+
+	delete(l.allocated, addr)
+}
+
+// updateVirtualIP assigns the virtual IP to the selected nodes and removes it from the other nodes.
+// If assign is false, the virtual IP is removed from all nodes.
+// Only the selected nodes and the nodes which have the virtual IP config are updated,
+// all nodes are updated on the first update of the address.
+func (l *loadBalancer) updateVirtualIP(ctx context.Context, addr netip.Addr, assign bool) error {
+	nodes, err := getTalosNodes(ctx, l.c)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	vipNodes, known := l.vipNodes[addr]
+	l.mu.Unlock()
+
+	type vipUpdate struct {
+		node     talosNode
+		selected bool
+		link     string
+		err      error
+	}
+
+	updates := []*vipUpdate{}
+
+	for _, node := range nodes {
+		selected := false
+
+		if assign {
+			selected, err = nodeselector.Match(l.c.config().Global.LoadBalancer.nodeSelector(), lowerKeys(node.labels))
+			if err != nil {
+				return fmt.Errorf("failed to match node selector: %w", err)
+			}
+		}
+
+		if _, ok := vipNodes[node.name]; selected || ok || !known {
+			updates = append(updates, &vipUpdate{node: node, selected: selected})
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	sem := make(chan struct{}, virtualIPUpdateConcurrency)
+
+	for _, u := range updates {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			u.link, u.err = l.updateNodeVirtualIP(ctx, u.node, addr, u.selected)
+		})
+	}
+
+	wg.Wait()
+
+	selected := 0
+	next := map[string]struct{}{}
+
+	var errs []error
+
+	for _, u := range updates {
+		_, ok := vipNodes[u.node.name]
+
+		switch {
+		case u.err != nil:
+			errs = append(errs, u.err)
+
+			// The config of the node is unknown, it is updated again on the next update.
+			if ok || !known || u.selected {
+				next[u.node.name] = struct{}{}
+			}
+		case u.link != "":
+			selected++
+
+			next[u.node.name] = struct{}{}
+		}
+	}
+
+	l.mu.Lock()
+	l.vipNodes[addr] = next
+	l.mu.Unlock()
+
+	if assign && selected == 0 {
+		errs = append(errs, fmt.Errorf("no nodes are selected for the virtual IP %s", addr))
+	}
+
+	return errors.Join(errs...)
+}
+
+// updateNodeVirtualIP assigns the virtual IP to the link of the node in the network of the address,
+// or removes it from the node, if the node is not selected. It returns the link of the virtual IP.
+func (l *loadBalancer) updateNodeVirtualIP(ctx context.Context, node talosNode, addr netip.Addr, selected bool) (string, error) {
+	link := ""
+
+	if selected {
+		statuses, err := getTalosNodeRoutes(ctx, l.c, node)
+		if err != nil {
+			return "", fmt.Errorf("failed to get routes on node %s: %w", node.name, err)
+		}
+
+		if link = directLinkName(statuses, addr); link == "" {
+			return "", fmt.Errorf("node %s has no link in the network of %s", node.name, addr)
+		}
+	}
+
+	klog.V(4).InfoS("loadBalancer.updateVirtualIP()", "node", klog.KRef("", node.name), "address", addr, "link", link)
+
+	if err := updateTalosNodeConfig(ctx, l.c, node, func(docs []talosconfig.Document) ([]talosconfig.Document, bool) {
+		return updateLayer2VIP(docs, addr, link)
+	}); err != nil {
+		return "", fmt.Errorf("failed to update virtual IP on node %s: %w", node.name, err)
+	}
+
+	return link, nil
+}
+
+// updateLayer2VIP removes the virtual IP config with the address from the documents,
+// and adds it on the link, if the link is not empty.
+func updateLayer2VIP(docs []talosconfig.Document, addr netip.Addr, link string) ([]talosconfig.Document, bool) {
+	res := make([]talosconfig.Document, 0, len(docs)+1)
+	changed := false
+	found := false
+
+	for _, doc := range docs {
+		vipConfig, ok := doc.(*networkconfig.Layer2VIPConfigV1Alpha1)
+		if !ok || vipConfig.MetaName != addr.String() {
+			res = append(res, doc)
+
+			continue
+		}
+
+		if link != "" && vipConfig.LinkName == link && !found {
+			res = append(res, doc)
+			found = true
+
+			continue
+		}
+
+		changed = true
+	}
+
+	if link != "" && !found {
+		vipConfig := networkconfig.NewLayer2VIPConfigV1Alpha1(addr.String())
+		vipConfig.LinkName = link
+
+		res = append(res, vipConfig)
+		changed = true
+	}
+
+	return res, changed
+}
+
+func loadBalancerStatus(addr netip.Addr) *v1.LoadBalancerStatus {
+	return &v1.LoadBalancerStatus{
+		Ingress: []v1.LoadBalancerIngress{
+			{
+				IP:     addr.String(),
+				IPMode: ptr.To(v1.LoadBalancerIPModeVIP),
+			},
+		},
+	}
+}
+
+func serviceKey(service *v1.Service) types.NamespacedName {
+	return types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+}
+
+func lowerKeys(in map[string]string) map[string]string {
+	res := make(map[string]string, len(in))
+
+	for k, v := range in {
+		res[strings.ToLower(k)] = v
+	}
+
+	return res
+}
+
+func poolByAddress(pools []*addressPool, addr netip.Addr) *addressPool {
+	for _, pool := range pools {
+		if pool.Contains(addr) {
+			return pool
+		}
+	}
+
+	return nil
+}
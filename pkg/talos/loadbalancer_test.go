@@ -0,0 +1,293 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	talosfake "github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+	talosconfig "github.com/siderolabs/talos/pkg/machinery/config/config"
+	networkconfig "github.com/siderolabs/talos/pkg/machinery/config/types/network"
+	"github.com/siderolabs/talos/pkg/machinery/constants"
+	"github.com/siderolabs/talos/pkg/machinery/nethelpers"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+)
+
+func TestAllocateAddress(t *testing.T) {
+	service := func(namespace, name, ip string, annotations map[string]string) *v1.Service {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: annotations},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+		}
+
+		if ip != "" {
+			svc.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: ip}}
+		}
+
+		return svc
+	}
+
+	cfg := cloudConfig{}
+	cfg.Global.LoadBalancer = cloudConfigLoadBalancer{
+		Enabled:   true,
+		Addresses: []string{"192.168.0.10-192.168.0.12", "fd00::10"},
+		Pools: []cloudConfigAddressPool{
+			{Name: "public", Addresses: []string{"1.2.3.4"}, Namespaces: []string{"ingress"}},
+		},
+	}
+
+	l, err := newLoadBalancer(&client{
+		cfg:     &cfg,
+		kclient: fake.NewClientset(service("default", "svc1", "192.168.0.10", nil)),
+	})
+	assert.NoError(t, err)
+
+	addr, err := l.allocateAddress(t.Context(), service("default", "svc1", "192.168.0.10", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.168.0.10"), addr)
+
+	addr, err = l.allocateAddress(t.Context(), service("default", "svc2", "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.168.0.11"), addr)
+
+	svc := service("default", "svc2-v6", "", nil)
+	svc.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol}
+
+	addr, err = l.allocateAddress(t.Context(), svc)
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("fd00::10"), addr)
+
+	_, err = l.allocateAddress(t.Context(), service("default", "svc3", "", map[string]string{ServiceLoadBalancerIPAnnotation: "192.168.0.11"}))
+	assert.EqualError(t, err, "load balancer IP 192.168.0.11 is already used by service default/svc2")
+
+	_, err = l.allocateAddress(t.Context(), service("default", "svc3", "", map[string]string{ServiceLoadBalancerIPAnnotation: "1.2.3.4"}))
+	assert.EqualError(t, err, "load balancer IP 1.2.3.4 is not in the allowed address pools")
+
+	_, err = l.allocateAddress(t.Context(), service("default", "svc3", "", map[string]string{ServiceAddressPoolAnnotation: "public"}))
+	assert.EqualError(t, err, "address pool public is not allowed in namespace default")
+
+	_, err = l.allocateAddress(t.Context(), service("default", "svc3", "", map[string]string{ServiceAddressPoolAnnotation: "private"}))
+	assert.EqualError(t, err, "address pool private not found")
+
+	addr, err = l.allocateAddress(t.Context(), service("ingress", "svc3", "", map[string]string{ServiceAddressPoolAnnotation: "public"}))
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("1.2.3.4"), addr)
+
+	addr, err = l.allocateAddress(t.Context(), service("default", "svc4", "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.168.0.12"), addr)
+
+	_, err = l.allocateAddress(t.Context(), service("default", "svc5", "", nil))
+	assert.EqualError(t, err, "no free addresses in the address pools")
+
+	addr, err = l.allocateAddress(t.Context(), service("default", "svc4", "", map[string]string{ServiceLoadBalancerIPAnnotation: "192.168.0.12"}))
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.168.0.12"), addr)
+
+	addr, ok := l.assignedAddress(service("default", "svc2", "", nil))
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParseAddr("192.168.0.11"), addr)
+
+	l.releaseAddress(addr)
+
+	addr, err = l.allocateAddress(t.Context(), service("default", "svc5", "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.168.0.11"), addr)
+}
+
+func TestUpdateLayer2VIP(t *testing.T) {
+	addr := netip.MustParseAddr("192.168.0.10")
+
+	vipConfig := func(name, link string) *networkconfig.Layer2VIPConfigV1Alpha1 {
+		cfg := networkconfig.NewLayer2VIPConfigV1Alpha1(name)
+		cfg.LinkName = link
+
+		return cfg
+	}
+
+	for _, tt := range []struct {
+		name            string
+		docs            []talosconfig.Document
+		link            string
+		expected        []talosconfig.Document
+		expectedChanged bool
+	}{
+		{
+			name:            "add virtual IP",
+			docs:            []talosconfig.Document{vipConfig("192.168.0.1", "eth0")},
+			link:            "eth0",
+			expected:        []talosconfig.Document{vipConfig("192.168.0.1", "eth0"), vipConfig("192.168.0.10", "eth0")},
+			expectedChanged: true,
+		},
+		{
+			name:            "virtual IP already exists",
+			docs:            []talosconfig.Document{vipConfig("192.168.0.10", "eth0")},
+			link:            "eth0",
+			expected:        []talosconfig.Document{vipConfig("192.168.0.10", "eth0")},
+			expectedChanged: false,
+		},
+		{
+			name:            "move virtual IP to the other link",
+			docs:            []talosconfig.Document{vipConfig("192.168.0.10", "eth1")},
+			link:            "eth0",
+			expected:        []talosconfig.Document{vipConfig("192.168.0.10", "eth0")},
+			expectedChanged: true,
+		},
+		{
+			name:            "remove virtual IP",
+			docs:            []talosconfig.Document{vipConfig("192.168.0.1", "eth0"), vipConfig("192.168.0.10", "eth0")},
+			expected:        []talosconfig.Document{vipConfig("192.168.0.1", "eth0")},
+			expectedChanged: true,
+		},
+		{
+			name:            "remove virtual IP does not exist",
+			docs:            []talosconfig.Document{vipConfig("192.168.0.1", "eth0")},
+			expected:        []talosconfig.Document{vipConfig("192.168.0.1", "eth0")},
+			expectedChanged: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, changed := updateLayer2VIP(tt.docs, addr, tt.link)
+			assert.Equal(t, tt.expectedChanged, changed)
+			assert.Equal(t, tt.expected, docs)
+		})
+	}
+}
+
+// countingConfigClient counts the machine config reads of the nodes.
+type countingConfigClient struct {
+	*talosfake.Client
+
+	mu    sync.Mutex
+	reads map[string]int
+}
+
+func (c *countingConfigClient) GetNodeConfigDocuments(ctx context.Context, nodeIP string) ([]talosconfig.Document, error) {
+	c.mu.Lock()
+	c.reads[nodeIP]++
+	c.mu.Unlock()
+
+	return c.Client.GetNodeConfigDocuments(ctx, nodeIP)
+}
+
+func (c *countingConfigClient) resetReads() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reads := c.reads
+	c.reads = map[string]int{}
+
+	return reads
+}
+
+func TestUpdateVirtualIP(t *testing.T) {
+	addr := netip.MustParseAddr("192.168.0.10")
+
+	talosNodes := map[string]*talosfake.Node{}
+	kubeNodes := []runtime.Object{}
+
+	for i := range 4 {
+		ip := fmt.Sprintf("192.168.0.%d", i+1)
+
+		talosNodes[ip] = &talosfake.Node{
+			Routes: []network.RouteStatusSpec{
+				{
+					Destination: netip.MustParsePrefix("192.168.0.0/24"),
+					Table:       nethelpers.TableMain,
+					Scope:       nethelpers.ScopeLink,
+					OutLinkName: "eth0",
+				},
+			},
+		}
+
+		node := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("node-%d", i),
+				Labels:      map[string]string{},
+				Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: ip},
+			},
+			Spec: v1.NodeSpec{ProviderID: "talos://metal/" + ip},
+		}
+
+		if i < 2 {
+			node.Labels[constants.LabelNodeRoleControlPlane] = ""
+		}
+
+		kubeNodes = append(kubeNodes, node)
+	}
+
+	talos := &countingConfigClient{Client: talosfake.NewClient("test-cluster", nil, nil, talosNodes), reads: map[string]int{}}
+
+	cfg := cloudConfig{}
+	cfg.Global.LoadBalancer = cloudConfigLoadBalancer{Enabled: true, Addresses: []string{"192.168.0.10"}}
+
+	client, err := newClient(&cfg, talos)
+	require.NoError(t, err)
+
+	client.kclient = fake.NewClientset(kubeNodes...)
+
+	l, err := newLoadBalancer(client)
+	require.NoError(t, err)
+
+	vipNodes := func() []string {
+		res := []string{}
+
+		for i := range 4 {
+			docs, err := talos.Client.GetNodeConfigDocuments(t.Context(), fmt.Sprintf("192.168.0.%d", i+1))
+			require.NoError(t, err)
+
+			if len(docs) > 0 {
+				res = append(res, fmt.Sprintf("node-%d", i))
+			}
+		}
+
+		return res
+	}
+
+	setControlPlane := func(name string, controlPlane bool) {
+		node, err := client.kclient.CoreV1().Nodes().Get(t.Context(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		delete(node.Labels, constants.LabelNodeRoleControlPlane)
+
+		if controlPlane {
+			node.Labels[constants.LabelNodeRoleControlPlane] = ""
+		}
+
+		_, err = client.kclient.CoreV1().Nodes().Update(t.Context(), node, metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+
+	// all nodes are updated on the first update
+	require.NoError(t, l.updateVirtualIP(t.Context(), addr, true))
+	assert.Equal(t, []string{"node-0", "node-1"}, vipNodes())
+	assert.Len(t, talos.resetReads(), 4)
+
+	// only the selected nodes are updated
+	require.NoError(t, l.updateVirtualIP(t.Context(), addr, true))
+	assert.Equal(t, map[string]int{"192.168.0.1": 1, "192.168.0.2": 1}, talos.resetReads())
+
+	// the virtual IP is moved from the node which is not selected anymore
+	setControlPlane("node-1", false)
+	setControlPlane("node-2", true)
+
+	require.NoError(t, l.updateVirtualIP(t.Context(), addr, true))
+	assert.Equal(t, []string{"node-0", "node-2"}, vipNodes())
+	assert.Equal(t, map[string]int{"192.168.0.1": 1, "192.168.0.2": 1, "192.168.0.3": 1}, talos.resetReads())
+
+	// the virtual IP is removed from the nodes which have it
+	require.NoError(t, l.updateVirtualIP(t.Context(), addr, false))
+	assert.Empty(t, vipNodes())
+	assert.Equal(t, map[string]int{"192.168.0.1": 1, "192.168.0.3": 1}, talos.resetReads())
+}
@@ -0,0 +1,61 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+)
+
+// nodeNetwork requests the network links of the node for the transformation rules.
+type nodeNetwork struct {
+	ctx    context.Context //nolint:containedctx
+	c      *client
+	nodeIP string
+}
+
+var _ transformer.NodeNetwork = &nodeNetwork{}
+
+func (n *nodeNetwork) Links() ([]transformer.Link, error) {
+	mc := metrics.NewMetricContext("links")
+
+	res, err := n.c.talos.GetNodeLinks(n.ctx, n.nodeIP)
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	links := make([]transformer.Link, 0, len(res))
+	for name, spec := range res {
+		links = append(links, transformer.Link{Name: name, LinkStatusSpec: spec})
+	}
+
+	slices.SortFunc(links, func(a, b transformer.Link) int { return strings.Compare(a.Name, b.Name) })
+
+	return links, nil
+}
+
+func (n *nodeNetwork) NodeLinkName() (string, error) {
+	nodeIP, err := netip.ParseAddr(n.nodeIP)
+	if err != nil {
+		return "", err
+	}
+
+	mc := metrics.NewMetricContext("addresses")
+
+	ifaces, err := n.c.talos.GetNodeIfaces(n.ctx, n.nodeIP)
+	if mc.ObserveRequest(err) != nil {
+		return "", err
+	}
+
+	for _, iface := range ifaces {
+		if iface.Address.Addr() == nodeIP {
+			return iface.LinkName, nil
+		}
+	}
+
+	return "", fmt.Errorf("node IP %s is not assigned to any link", n.nodeIP)
+}
@@ -0,0 +1,72 @@
+package talos
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// nodeIPIndex is the index of the nodes by the Talos node IPs.
+	nodeIPIndex = "nodeIP"
+	// nodeProviderIDIndex is the index of the nodes by the provider ID.
+	nodeProviderIDIndex = "providerID"
+)
+
+// newNodeInformer creates the node informer indexed by the Talos node IPs and the provider ID.
+func newNodeInformer(c *client) (cache.SharedIndexInformer, error) {
+	informer := informers.NewSharedInformerFactory(c.kclient, 0).Core().V1().Nodes().Informer()
+
+	if err := informer.AddIndexers(cache.Indexers{
+		nodeIPIndex:         c.nodeIPs,
+		nodeProviderIDIndex: nodeProviderID,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to add the node indexers: %w", err)
+	}
+
+	return informer, nil
+}
+
+// nodeIPs is the index function of the nodes by the Talos node IPs.
+func (c *client) nodeIPs(obj any) ([]string, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return nil, nil
+	}
+
+	return getTalosNodeIPs(c.config(), node), nil
+}
+
+// nodeProviderID is the index function of the nodes by the provider ID.
+func nodeProviderID(obj any) ([]string, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok || node.Spec.ProviderID == "" {
+		return nil, nil
+	}
+
+	return []string{node.Spec.ProviderID}, nil
+}
+
+// nodesByIndex returns the cached nodes by the index value, the nodes are shared and must not be modified.
+func (c *client) nodesByIndex(index, value string) ([]*v1.Node, error) {
+	if c.nodeInformer == nil || !c.nodeInformer.HasSynced() {
+		return nil, fmt.Errorf("node cache is not synced")
+	}
+
+	objs, err := c.nodeInformer.GetIndexer().ByIndex(index, value)
+	if err != nil {
+		return nil, fmt.Errorf("error getting nodes by the %s index: %w", index, err)
+	}
+
+	nodes := make([]*v1.Node, 0, len(objs))
+
+	for _, obj := range objs {
+		if node, ok := obj.(*v1.Node); ok {
+			nodes = append(nodes, node)
+		}
+	}
+
+	return nodes, nil
+}
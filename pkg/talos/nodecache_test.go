@@ -0,0 +1,76 @@
+package talos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+)
+
+// startNodeInformer starts the node informer of the client and waits for the cache sync.
+func startNodeInformer(t *testing.T, c *client) {
+	t.Helper()
+
+	informer, err := newNodeInformer(c)
+	require.NoError(t, err)
+
+	c.nodeInformer = informer
+
+	go informer.RunWithContext(t.Context())
+
+	require.True(t, cache.WaitForCacheSync(t.Context().Done(), informer.HasSynced))
+}
+
+func TestNodesByIndex(t *testing.T) {
+	c := &client{
+		cfg: &cloudConfig{},
+		kclient: fake.NewClientset(
+			&v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "node-1",
+					Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.1"},
+				},
+				Spec: v1.NodeSpec{ProviderID: "talos://metal/192.168.0.1"},
+			},
+			&v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+				Status: v1.NodeStatus{
+					Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.2"}},
+				},
+			},
+		),
+	}
+
+	_, err := c.nodesByIndex(nodeIPIndex, "192.168.0.1")
+	assert.EqualError(t, err, "node cache is not synced")
+
+	startNodeInformer(t, c)
+
+	for _, tt := range []struct {
+		index    string
+		value    string
+		expected []string
+	}{
+		{index: nodeIPIndex, value: "192.168.0.1", expected: []string{"node-1"}},
+		{index: nodeIPIndex, value: "192.168.0.2", expected: []string{"node-2"}},
+		{index: nodeIPIndex, value: "192.168.0.3"},
+		{index: nodeProviderIDIndex, value: "talos://metal/192.168.0.1", expected: []string{"node-1"}},
+		{index: nodeProviderIDIndex, value: "talos://metal/192.168.0.2"},
+	} {
+		nodes, err := c.nodesByIndex(tt.index, tt.value)
+		require.NoError(t, err)
+
+		var names []string
+		for _, node := range nodes {
+			names = append(names, node.Name)
+		}
+
+		assert.Equal(t, tt.expected, names, "%s=%s", tt.index, tt.value)
+	}
+}
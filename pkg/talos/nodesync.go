@@ -0,0 +1,289 @@
+package talos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/utils/net"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+	"k8s.io/klog/v2"
+)
+
+const nodeSyncWorkers = 2
+
+// nodeSyncer re-syncs the nodes when the Talos resources of the nodes change,
+// without waiting for the next periodic sync of the cloud-node controller.
+type nodeSyncer struct {
+	c *client
+
+	queue workqueue.TypedRateLimitingInterface[string]
+}
+
+func newNodeSyncer(client *client) *nodeSyncer {
+	return &nodeSyncer{
+		c: client,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "talos_node_sync"},
+		),
+	}
+}
+
+// enqueue schedules the sync of the node with the node IP.
+func (s *nodeSyncer) enqueue(nodeIP string) {
+	s.queue.Add(nodeIP)
+}
+
+// Run starts the workers and blocks until the context is done.
+func (s *nodeSyncer) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+	defer s.queue.ShutDown()
+
+	klog.InfoS("starting talos node syncer")
+	defer klog.InfoS("shutting down talos node syncer")
+
+	if s.c.nodeInformer == nil || !cache.WaitForCacheSync(ctx.Done(), s.c.nodeInformer.HasSynced) {
+		return
+	}
+
+	for range nodeSyncWorkers {
+		go wait.UntilWithContext(ctx, s.runWorker, time.Second)
+	}
+
+	<-ctx.Done()
+}
+
+func (s *nodeSyncer) runWorker(ctx context.Context) {
+	for s.processNextWorkItem(ctx) {
+	}
+}
+
+func (s *nodeSyncer) processNextWorkItem(ctx context.Context) bool {
+	nodeIP, shutdown := s.queue.Get()
+	if shutdown {
+		return false
+	}
+
+	defer s.queue.Done(nodeIP)
+
+	if err := s.syncNodeIP(ctx, nodeIP); err != nil {
+		s.queue.AddRateLimited(nodeIP)
+
+		utilruntime.HandleError(fmt.Errorf("error syncing the node %s: %w, requeuing", nodeIP, err))
+
+		return true
+	}
+
+	s.queue.Forget(nodeIP)
+
+	return true
+}
+
+// syncNodeIP syncs the initialized nodes, which are reachable by the node IP.
+func (s *nodeSyncer) syncNodeIP(ctx context.Context, nodeIP string) error {
+	nodes, err := s.c.nodesByIndex(nodeIPIndex, nodeIP)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if node.Spec.ProviderID == "" || taintExists(node.Spec.Taints, uninitializedTaint) {
+			continue
+		}
+
+		if err := s.syncNode(ctx, node.DeepCopy()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncNode updates the labels, annotations, taints and addresses of the node from the Talos resources.
+// The labels of the platform metadata and the cluster name are updated, and the transformation rules are applied
+// the same way as by the reconciler, the stale keys of the previous transformation are removed.
+func (s *nodeSyncer) syncNode(ctx context.Context, node *v1.Node) error {
+	providedIP, ok := node.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr]
+	if !ok {
+		return nil
+	}
+
+	klog.V(4).InfoS("syncing the node on talos resources change", "node", klog.KRef("", node.Name))
+
+	config := s.c.config()
+	nodeIPs := net.PreferredDualStackNodeIPs(config.Global.PreferIPv6, strings.Split(providedIP, ","))
+
+	nm, err := getNodeMetadata(ctx, s.c, node, nodeIPs)
+	if err != nil {
+		return err
+	}
+
+	mc := metrics.NewMetricContext("addresses")
+
+	ifaces, err := s.c.talos.GetNodeIfaces(ctx, nm.nodeIP)
+	if mc.ObserveRequest(err) != nil {
+		return fmt.Errorf("error getting interfaces list from the node %s: %w", node.Name, err)
+	}
+
+	addresses := getNodeAllAddresses(config, node.Name, nm.meta, &nm.nodeSpec.Features, nodeIPs, ifaces)
+	labels := setTalosNodeLabels(ctx, s.c, nm.nodeIP, nm.meta)
+
+	var changes []string
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := s.c.kclient.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		updated := current.DeepCopy()
+		updated.Labels = setNodeTopologyLabels(updated.Labels, nm.meta)
+
+		if updated.Labels == nil {
+			updated.Labels = map[string]string{}
+		}
+
+		maps.Copy(updated.Labels, labels)
+
+		if _, err := applyNodeSpec(updated, nm.nodeSpec); err != nil {
+			return err
+		}
+
+		if _, err := applyNodeTrace(updated, nm.trace, config.Global.TransformationsTrace.Annotation); err != nil {
+			return err
+		}
+
+		changes = nodeChanges(current, updated)
+		if len(changes) == 0 {
+			return nil
+		}
+
+		_, err = s.c.kclient.CoreV1().Nodes().Update(ctx, updated, metav1.UpdateOptions{})
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the node: %w", err)
+	}
+
+	if !slices.Equal(addresses, node.Status.Addresses) {
+		// The merge patch replaces the whole list, the strategic merge patch merges the addresses by type.
+		patch, err := json.Marshal(map[string]any{
+			"status": map[string]any{
+				"addresses": addresses,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal the node addresses: %w", err)
+		}
+
+		if _, err := s.c.kclient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, patch, metav1.PatchOptions{}, "status"); err != nil {
+			return fmt.Errorf("failed to patch the node addresses: %w", err)
+		}
+
+		changes = append(changes, "addresses "+nodeAddressesString(addresses))
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	recordNodeEvent(s.c, node, v1.EventTypeNormal, "TalosNodeSynced", "Node %s was synced with the changed Talos resources: %s",
+		node.Name, strings.Join(changes, "; "))
+
+	return nil
+}
+
+// setNodeTopologyLabels sets the zone, region and instance type labels of the platform metadata,
+// the deprecated beta labels are updated only if the node has them.
+func setNodeTopologyLabels(labels map[string]string, meta *runtime.PlatformMetadataSpec) map[string]string {
+	for _, label := range []struct {
+		key, beta, value string
+	}{
+		{v1.LabelTopologyZone, v1.LabelFailureDomainBetaZone, meta.Zone},
+		{v1.LabelTopologyRegion, v1.LabelFailureDomainBetaRegion, meta.Region},
+		{v1.LabelInstanceTypeStable, v1.LabelInstanceType, meta.InstanceType},
+	} {
+		if label.value == "" {
+			continue
+		}
+
+		if labels == nil {
+			labels = map[string]string{}
+		}
+
+		labels[label.key] = label.value
+
+		if _, ok := labels[label.beta]; ok {
+			labels[label.beta] = label.value
+		}
+	}
+
+	return labels
+}
+
+// nodeChanges returns the description of the changed labels, annotations and taints of the node.
+func nodeChanges(current, updated *v1.Node) []string {
+	var changes []string
+
+	if keys := changedKeys(current.Labels, updated.Labels); len(keys) > 0 {
+		changes = append(changes, fmt.Sprintf("labels [%s]", strings.Join(keys, ",")))
+	}
+
+	if keys := changedKeys(current.Annotations, updated.Annotations); len(keys) > 0 {
+		changes = append(changes, fmt.Sprintf("annotations [%s]", strings.Join(keys, ",")))
+	}
+
+	if !reflect.DeepEqual(current.Spec.Taints, updated.Spec.Taints) {
+		changes = append(changes, "taints")
+	}
+
+	return changes
+}
+
+// changedKeys returns the sorted keys, which were added, changed or removed.
+func changedKeys(current, updated map[string]string) []string {
+	var keys []string
+
+	for k, v := range updated {
+		if r, ok := current[k]; !ok || r != v {
+			keys = append(keys, k)
+		}
+	}
+
+	for k := range current {
+		if _, ok := updated[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+func nodeAddressesString(addresses []v1.NodeAddress) string {
+	res := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		res = append(res, fmt.Sprintf("%s=%s", addr.Type, addr.Address))
+	}
+
+	return strings.Join(res, ",")
+}
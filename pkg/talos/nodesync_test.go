@@ -0,0 +1,198 @@
+package talos
+
+import (
+	"maps"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	talosfake "github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+)
+
+func TestNodeSyncerSyncNodeIP(t *testing.T) {
+	talos := talosfake.NewClient("test-cluster", nil, nil, map[string]*talosfake.Node{
+		"192.168.0.1": {
+			Metadata:   &runtime.PlatformMetadataSpec{Platform: "metal", Zone: "zone-1"},
+			SystemInfo: &hardware.SystemInformationSpec{},
+			Ifaces: []network.AddressStatusSpec{
+				{LinkName: "eth0", Address: netip.MustParsePrefix("192.168.0.1/24")},
+				{LinkName: "eth0", Address: netip.MustParsePrefix("1.2.3.4/24")},
+			},
+		},
+	})
+
+	cfg := cloudConfig{
+		Transformations: []transformer.NodeTerm{
+			{Labels: map[string]string{"example.com/rack": "{{ .Zone }}"}},
+		},
+	}
+
+	syncedAddresses := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "192.168.0.1"},
+		{Type: v1.NodeExternalIP, Address: "1.2.3.4"},
+		{Type: v1.NodeHostName, Address: "node-1"},
+	}
+
+	syncedLabels := map[string]string{
+		ClusterNameNodeLabel:     "test-cluster",
+		ClusterNodePlatformLabel: "metal",
+		v1.LabelTopologyZone:     "zone-1",
+		"example.com/rack":       "zone-1",
+	}
+
+	syncedAnnotations := map[string]string{
+		cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.1",
+		ClusterNodeTransformationsAnnotation:           `{"labels":["example.com/rack"]}`,
+	}
+
+	node := func(name, nodeIP string, labels, annotations map[string]string, addresses []v1.NodeAddress, taints ...v1.Taint) *v1.Node {
+		n := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      maps.Clone(labels),
+				Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: nodeIP},
+			},
+			Spec: v1.NodeSpec{
+				ProviderID: "talos://metal/" + nodeIP,
+				Taints:     taints,
+			},
+			Status: v1.NodeStatus{
+				Addresses: addresses,
+			},
+		}
+
+		maps.Copy(n.Annotations, annotations)
+
+		if n.Status.Addresses == nil {
+			n.Status.Addresses = []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: nodeIP},
+				{Type: v1.NodeHostName, Address: name},
+			}
+		}
+
+		return n
+	}
+
+	for _, tt := range []struct {
+		name                string
+		node                *v1.Node
+		expectedAddresses   []v1.NodeAddress
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+		expectedEvent       string
+	}{
+		{
+			name:                "node has a new public IP",
+			node:                node("node-1", "192.168.0.1", nil, nil, nil),
+			expectedAddresses:   syncedAddresses,
+			expectedLabels:      syncedLabels,
+			expectedAnnotations: syncedAnnotations,
+			expectedEvent: "Normal TalosNodeSynced Node node-1 was synced with the changed Talos resources: " +
+				"labels [example.com/rack,node.cloudprovider.kubernetes.io/clustername,node.cloudprovider.kubernetes.io/platform,topology.kubernetes.io/zone]; " +
+				"annotations [node.cloudprovider.kubernetes.io/transformations]; " +
+				"addresses InternalIP=192.168.0.1,ExternalIP=1.2.3.4,Hostname=node-1",
+		},
+		{
+			name:                "node is synced",
+			node:                node("node-1", "192.168.0.1", syncedLabels, syncedAnnotations, syncedAddresses),
+			expectedAddresses:   syncedAddresses,
+			expectedLabels:      syncedLabels,
+			expectedAnnotations: syncedAnnotations,
+		},
+		{
+			name: "node zone is changed",
+			node: node("node-1", "192.168.0.1", map[string]string{
+				ClusterNameNodeLabel:          "test-cluster",
+				ClusterNodePlatformLabel:      "metal",
+				v1.LabelTopologyZone:          "zone-0",
+				v1.LabelFailureDomainBetaZone: "zone-0",
+				"example.com/rack":            "zone-0",
+				"example.com/old":             "true",
+			}, map[string]string{
+				ClusterNodeTransformationsAnnotation: `{"labels":["example.com/old","example.com/rack"]}`,
+			}, syncedAddresses),
+			expectedAddresses: syncedAddresses,
+			expectedLabels: map[string]string{
+				ClusterNameNodeLabel:          "test-cluster",
+				ClusterNodePlatformLabel:      "metal",
+				v1.LabelTopologyZone:          "zone-1",
+				v1.LabelFailureDomainBetaZone: "zone-1",
+				"example.com/rack":            "zone-1",
+			},
+			expectedAnnotations: syncedAnnotations,
+			expectedEvent: "Normal TalosNodeSynced Node node-1 was synced with the changed Talos resources: " +
+				"labels [example.com/old,example.com/rack,failure-domain.beta.kubernetes.io/zone,topology.kubernetes.io/zone]; " +
+				"annotations [node.cloudprovider.kubernetes.io/transformations]",
+		},
+		{
+			name: "node is not initialized",
+			node: node("node-1", "192.168.0.1", nil, nil, nil, *uninitializedTaint),
+			expectedAddresses: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.1"},
+				{Type: v1.NodeHostName, Address: "node-1"},
+			},
+			expectedAnnotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.1"},
+		},
+		{
+			name: "node has a different IP",
+			node: node("node-2", "192.168.0.2", nil, nil, nil),
+			expectedAddresses: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.2"},
+				{Type: v1.NodeHostName, Address: "node-2"},
+			},
+			expectedAnnotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.2"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newClient(&cfg, talos)
+			require.NoError(t, err)
+
+			client.kclient = fake.NewClientset(tt.node)
+
+			recorder := record.NewFakeRecorder(10)
+			client.recorder = recorder
+
+			startNodeInformer(t, client)
+
+			syncer := newNodeSyncer(client)
+
+			require.NoError(t, syncer.syncNodeIP(t.Context(), "192.168.0.1"))
+
+			node, err := client.kclient.CoreV1().Nodes().Get(t.Context(), tt.node.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedAddresses, node.Status.Addresses)
+			assert.Equal(t, tt.expectedLabels, node.Labels)
+			assert.Equal(t, tt.expectedAnnotations, node.Annotations)
+
+			close(recorder.Events)
+
+			var events []string
+
+			for event := range recorder.Events {
+				if strings.Contains(event, "TalosNodeSynced") {
+					events = append(events, event)
+				}
+			}
+
+			if tt.expectedEvent == "" {
+				assert.Empty(t, events)
+			} else {
+				assert.Equal(t, []string{tt.expectedEvent}, events)
+			}
+		})
+	}
+}
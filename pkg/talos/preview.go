@@ -0,0 +1,201 @@
+package talos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/utils/net"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// NodeResources is the Talos resources of the node, they are used to preview the transformation rules offline.
+type NodeResources struct {
+	PlatformMetadata  *runtime.PlatformMetadataSpec
+	SystemInformation *hardware.SystemInformationSpec
+	Addresses         []network.AddressStatusSpec
+}
+
+// NodePreview is the result of the transformation rules and the address discovery of the node.
+type NodePreview struct {
+	Labels           map[string]string             `yaml:"labels,omitempty"`
+	Annotations      map[string]string             `yaml:"annotations,omitempty"`
+	Taints           map[string]string             `yaml:"taints,omitempty"`
+	PlatformMetadata *runtime.PlatformMetadataSpec `yaml:"platformMetadata"`
+	Addresses        []v1.NodeAddress              `yaml:"addresses,omitempty"`
+	Trace            *transformer.Trace            `yaml:"trace,omitempty"`
+}
+
+// talosResource is the resource in the output format of `talosctl get -o yaml` or `talosctl get -o json`.
+type talosResource struct {
+	Metadata struct {
+		Type string `yaml:"type"`
+		ID   string `yaml:"id"`
+	} `yaml:"metadata"`
+	Spec yaml.Node `yaml:"spec"`
+}
+
+// ReadNodeResources reads the platform metadata, system information and address resources
+// in the output format of `talosctl get -o yaml` or `talosctl get -o json` into the node resources.
+func ReadNodeResources(r io.Reader, res *NodeResources) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	docs, err := decodeResourceDocuments(data)
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		var resource talosResource
+		if err := doc.Decode(&resource); err != nil {
+			return err
+		}
+
+		switch resource.Metadata.Type {
+		case runtime.PlatformMetadataType:
+			res.PlatformMetadata = &runtime.PlatformMetadataSpec{}
+			err = resource.Spec.Decode(res.PlatformMetadata)
+		case hardware.SystemInformationType:
+			res.SystemInformation = &hardware.SystemInformationSpec{}
+			err = resource.Spec.Decode(res.SystemInformation)
+		case network.AddressStatusType:
+			var addr network.AddressStatusSpec
+			if err = resource.Spec.Decode(&addr); err == nil {
+				res.Addresses = append(res.Addresses, addr)
+			}
+		default:
+			return fmt.Errorf("unsupported talos resource type %q", resource.Metadata.Type)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to decode talos resource %s/%s: %w", resource.Metadata.Type, resource.Metadata.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// decodeResourceDocuments returns the YAML documents, the stream of JSON objects is converted to the documents.
+func decodeResourceDocuments(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+
+		for decoder.More() {
+			var obj any
+			if err := decoder.Decode(&obj); err != nil {
+				return nil, err
+			}
+
+			var doc yaml.Node
+			if err := doc.Encode(obj); err != nil {
+				return nil, err
+			}
+
+			docs = append(docs, &doc)
+		}
+
+		return docs, nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+
+			return nil, err
+		}
+
+		docs = append(docs, &doc)
+	}
+}
+
+// PreviewNode applies the transformation rules of the cloud config to the Talos resources of the node,
+// the same way as on the node initialization, but without the Talos and Kubernetes API.
+// The hardware, operating system and network values of the transformation rules are empty,
+// the labels include the topology, platform and cluster name labels, but not the well-known labels of the Talos API.
+func PreviewNode(config io.Reader, nodeName string, nodeIPs []string, res *NodeResources) (*NodePreview, error) {
+	cfg, err := readCloudConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cloud config: %w", err)
+	}
+
+	if res.PlatformMetadata == nil {
+		return nil, fmt.Errorf("platform metadata resource is required")
+	}
+
+	if len(nodeIPs) == 0 {
+		return nil, fmt.Errorf("node IP is required")
+	}
+
+	nodeIPs = net.PreferredDualStackNodeIPs(cfg.Global.PreferIPv6, nodeIPs)
+
+	meta := *res.PlatformMetadata
+	if err := setPlatformMetadataDefaults(&meta, nodeName, nodeIPs[0]); err != nil {
+		return nil, err
+	}
+
+	trace := &transformer.Trace{}
+
+	nodeSpec, err := transformer.TransformNode(cfg.Transformations, &meta, res.SystemInformation, transformer.WithTrace(trace))
+	if err != nil {
+		return nil, fmt.Errorf("error transforming node: %w", err)
+	}
+
+	labels := setNodeTopologyLabels(nil, &meta)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	maps.Copy(labels, talosNodeLabels(&meta, cfg.Global.ClusterName))
+	maps.Copy(labels, nodeSpec.Labels)
+
+	return &NodePreview{
+		Labels:           labels,
+		Annotations:      nodeSpec.Annotations,
+		Taints:           nodeSpec.Taints,
+		PlatformMetadata: &meta,
+		Addresses:        getNodeAllAddresses(&cfg, nodeName, &meta, &nodeSpec.Features, nodeIPs, res.Addresses),
+		Trace:            trace,
+	}, nil
+}
+
+// Apply returns the copy of the node with the labels, annotations, taints and addresses of the preview,
+// as they are applied by the reconciliation of the transformation rules.
+// The internal annotation with the keys of the applied transformations is not a part of the preview.
+func (p *NodePreview) Apply(node *v1.Node) (*v1.Node, error) {
+	res := node.DeepCopy()
+	res.Labels = setNodeTopologyLabels(res.Labels, p.PlatformMetadata)
+
+	if _, err := applyNodeSpec(res, &transformer.NodeSpec{
+		Labels:      p.Labels,
+		Annotations: p.Annotations,
+		Taints:      p.Taints,
+	}); err != nil {
+		return nil, err
+	}
+
+	delete(res.Annotations, ClusterNodeTransformationsAnnotation)
+
+	res.Status.Addresses = p.Addresses
+
+	return res, nil
+}
@@ -0,0 +1,163 @@
+package talos
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReadNodeResources(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		resources string
+	}{
+		{
+			name: "yaml",
+			resources: `
+node: 10.0.0.2
+metadata:
+    namespace: runtime
+    type: PlatformMetadatas.talos.dev
+    id: platformmetadata
+spec:
+    platform: metal
+    hostname: web-1
+---
+node: 10.0.0.2
+metadata:
+    namespace: hardware
+    type: SystemInformations.hardware.talos.dev
+    id: systeminformation
+spec:
+    manufacturer: QEMU
+---
+node: 10.0.0.2
+metadata:
+    namespace: network
+    type: AddressStatuses.net.talos.dev
+    id: eth0/1.2.3.4/24
+spec:
+    address: 1.2.3.4/24
+    linkName: eth0
+    family: inet4
+`,
+		},
+		{
+			name: "json",
+			resources: `
+{"node":"10.0.0.2","metadata":{"type":"PlatformMetadatas.talos.dev","id":"platformmetadata"},"spec":{"platform":"metal","hostname":"web-1"}}
+{"node":"10.0.0.2","metadata":{"type":"SystemInformations.hardware.talos.dev","id":"systeminformation"},"spec":{"manufacturer":"QEMU"}}
+{"node":"10.0.0.2","metadata":{"type":"AddressStatuses.net.talos.dev","id":"eth0/1.2.3.4/24"},"spec":{"address":"1.2.3.4/24","linkName":"eth0","family":"inet4"}}
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res := NodeResources{}
+			require.NoError(t, ReadNodeResources(strings.NewReader(tt.resources), &res))
+
+			assert.Equal(t, &runtime.PlatformMetadataSpec{Platform: "metal", Hostname: "web-1"}, res.PlatformMetadata)
+			assert.Equal(t, &hardware.SystemInformationSpec{Manufacturer: "QEMU"}, res.SystemInformation)
+			require.Len(t, res.Addresses, 1)
+			assert.Equal(t, netip.MustParsePrefix("1.2.3.4/24"), res.Addresses[0].Address)
+			assert.Equal(t, "eth0", res.Addresses[0].LinkName)
+		})
+	}
+
+	err := ReadNodeResources(strings.NewReader(`{"metadata":{"type":"Members.cluster.talos.dev"}}`), &NodeResources{})
+	assert.EqualError(t, err, `unsupported talos resource type "Members.cluster.talos.dev"`)
+}
+
+func TestPreviewNode(t *testing.T) {
+	config := `
+global:
+  clusterName: test-cluster
+transformations:
+  - name: web
+    nodeSelector:
+      - matchExpressions:
+          - key: hostname
+            operator: Regexp
+            values: ["^web-.+$"]
+    labels:
+      node-role.kubernetes.io/web: ""
+    annotations:
+      example.com/hostname: "{{ .Hostname }}"
+    taints:
+      example.com/web: NoSchedule
+    platformMetadata:
+      Zone: "{{ .Hostname }}-zone"
+`
+
+	res := NodeResources{
+		PlatformMetadata: &runtime.PlatformMetadataSpec{Platform: "metal", Hostname: "web-1.example.com", Region: "region-1"},
+	}
+
+	preview, err := PreviewNode(strings.NewReader(config), "web-1", []string{"10.0.0.2"}, &res)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		ClusterNameNodeLabel:          "test-cluster",
+		ClusterNodePlatformLabel:      "metal",
+		v1.LabelTopologyRegion:        "region-1",
+		v1.LabelTopologyZone:          "web-1.example.com-zone",
+		"node-role.kubernetes.io/web": "",
+	}, preview.Labels)
+	assert.Equal(t, map[string]string{"example.com/hostname": "web-1.example.com"}, preview.Annotations)
+	assert.Equal(t, map[string]string{"example.com/web": "NoSchedule"}, preview.Taints)
+	assert.Equal(t, &runtime.PlatformMetadataSpec{
+		Platform:   "metal",
+		Hostname:   "web-1.example.com",
+		Region:     "region-1",
+		Zone:       "web-1.example.com-zone",
+		ProviderID: "talos://metal/10.0.0.2",
+	}, preview.PlatformMetadata)
+	assert.Equal(t, []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.2"},
+		{Type: v1.NodeHostName, Address: "web-1"},
+		{Type: v1.NodeInternalDNS, Address: "web-1.example.com"},
+	}, preview.Addresses)
+	assert.Equal(t, "transformations[0] (web) matched nodeSelector[0] annotations=[example.com/hostname] labels=[node-role.kubernetes.io/web] taints=[example.com/web] platformMetadata=[Zone]",
+		preview.Trace.String())
+	assert.Equal(t, "metal", res.PlatformMetadata.Platform)
+	assert.Empty(t, res.PlatformMetadata.Zone)
+
+	node, err := preview.Apply(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-1",
+			Labels: map[string]string{
+				"example.com/custom":          "true",
+				v1.LabelFailureDomainBetaZone: "zone-a",
+			},
+			Annotations: map[string]string{"example.com/custom": "true"},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		ClusterNameNodeLabel:          "test-cluster",
+		ClusterNodePlatformLabel:      "metal",
+		v1.LabelTopologyRegion:        "region-1",
+		v1.LabelTopologyZone:          "web-1.example.com-zone",
+		v1.LabelFailureDomainBetaZone: "web-1.example.com-zone",
+		"example.com/custom":          "true",
+		"node-role.kubernetes.io/web": "",
+	}, node.Labels)
+	assert.Equal(t, map[string]string{
+		"example.com/custom":   "true",
+		"example.com/hostname": "web-1.example.com",
+	}, node.Annotations)
+	assert.Equal(t, []v1.Taint{{Key: "example.com/web", Effect: v1.TaintEffectNoSchedule}}, node.Spec.Taints)
+	assert.Equal(t, preview.Addresses, node.Status.Addresses)
+
+	_, err = PreviewNode(strings.NewReader(config), "web-1", nil, &res)
+	assert.EqualError(t, err, "node IP is required")
+}
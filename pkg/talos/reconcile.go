@@ -0,0 +1,323 @@
+package talos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+const nodeReconcileWorkers = 2
+
+// nodeReconciler applies the transformation rules to the initialized nodes on start and periodically.
+// The labels, annotations and taints of the previous transformation are tracked in the node annotation,
+// so the ones which are not produced by the transformation rules anymore are removed.
+type nodeReconciler struct {
+	c *client
+
+	queue workqueue.TypedRateLimitingInterface[string]
+}
+
+// nodeTransformations is the keys of the labels, annotations and taints applied by the transformation rules.
+type nodeTransformations struct {
+	Labels      []string `json:"labels,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
+	Taints      []string `json:"taints,omitempty"`
+}
+
+func newNodeReconciler(client *client) *nodeReconciler {
+	return &nodeReconciler{
+		c: client,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "talos_node_reconcile"},
+		),
+	}
+}
+
+// Run starts the workers and blocks until the context is done.
+func (r *nodeReconciler) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+	defer r.queue.ShutDown()
+
+	klog.InfoS("starting talos node reconciler")
+	defer klog.InfoS("shutting down talos node reconciler")
+
+	for range nodeReconcileWorkers {
+		go wait.UntilWithContext(ctx, r.runWorker, time.Second)
+	}
+
+	// The interval is read on each period, so the reloaded interval is applied after the current one.
+	for {
+		r.enqueueAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.c.config().Global.Reconcile.interval()):
+		}
+	}
+}
+
+// enqueueAll schedules the reconciliation of all nodes.
+func (r *nodeReconciler) enqueueAll(ctx context.Context) {
+	nodes, err := r.c.kclient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("error listing nodes: %w", err))
+
+		return
+	}
+
+	for _, node := range nodes.Items {
+		r.queue.Add(node.Name)
+	}
+}
+
+func (r *nodeReconciler) runWorker(ctx context.Context) {
+	for r.processNextWorkItem(ctx) {
+	}
+}
+
+func (r *nodeReconciler) processNextWorkItem(ctx context.Context) bool {
+	name, shutdown := r.queue.Get()
+	if shutdown {
+		return false
+	}
+
+	defer r.queue.Done(name)
+
+	if err := r.reconcileNode(ctx, name); err != nil {
+		r.queue.AddRateLimited(name)
+
+		utilruntime.HandleError(fmt.Errorf("error reconciling the node %s: %w, requeuing", name, err))
+
+		return true
+	}
+
+	r.queue.Forget(name)
+
+	return true
+}
+
+// reconcileNode applies the transformation rules to the initialized node.
+func (r *nodeReconciler) reconcileNode(ctx context.Context, name string) error {
+	node, err := r.c.kclient.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("error getting the node: %w", err)
+	}
+
+	if node.Spec.ProviderID == "" || taintExists(node.Spec.Taints, uninitializedTaint) {
+		return nil
+	}
+
+	nodeIPs := getTalosNodeIPs(r.c.config(), node)
+	if len(nodeIPs) == 0 {
+		return nil
+	}
+
+	klog.V(4).InfoS("reconciling the node transformations", "node", klog.KRef("", node.Name))
+
+	nm, err := getNodeMetadata(ctx, r.c, node, nodeIPs)
+	if err != nil {
+		return err
+	}
+
+	trace := r.c.config().Global.TransformationsTrace
+	changed := false
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err = r.c.kclient.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		changed, err = applyNodeSpec(node, nm.nodeSpec)
+		if err != nil {
+			return err
+		}
+
+		traced, err := applyNodeTrace(node, nm.trace, trace.Annotation)
+		if err != nil || (!changed && !traced) {
+			return err
+		}
+
+		_, err = r.c.kclient.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the node: %w", err)
+	}
+
+	if changed {
+		recordNodeEvent(r.c, node, v1.EventTypeNormal, "TalosNodeTransformed", "Node %s was updated by the transformation rules", node.Name)
+
+		if trace.Events {
+			recordNodeTrace(r.c, node, nm.trace)
+		}
+	}
+
+	return nil
+}
+
+// applyNodeSpec updates the labels, annotations and taints of the node to the result of the transformation rules.
+// The ones applied by the previous transformation, which are not in the node spec anymore, are removed.
+// It returns true if the node was changed.
+func applyNodeSpec(node *v1.Node, spec *transformer.NodeSpec) (bool, error) {
+	prev := getNodeTransformations(node)
+
+	annotations := maps.Clone(spec.Annotations)
+	delete(annotations, ClusterNodeTransformationsAnnotation)
+
+	var labelsChanged, annotationsChanged, taintsChanged bool
+
+	node.Labels, labelsChanged = applyNodeMap(node.Labels, prev.Labels, spec.Labels)
+	node.Annotations, annotationsChanged = applyNodeMap(node.Annotations, prev.Annotations, annotations)
+	node.Spec.Taints, taintsChanged = applyNodeTaints(node.Spec.Taints, prev.Taints, spec.Taints)
+
+	changed := labelsChanged || annotationsChanged || taintsChanged
+
+	next := nodeTransformations{
+		Labels:      slices.Sorted(maps.Keys(spec.Labels)),
+		Annotations: slices.Sorted(maps.Keys(annotations)),
+		Taints:      slices.Sorted(maps.Keys(spec.Taints)),
+	}
+
+	if len(next.Labels) == 0 && len(next.Annotations) == 0 && len(next.Taints) == 0 {
+		if _, ok := node.Annotations[ClusterNodeTransformationsAnnotation]; ok {
+			delete(node.Annotations, ClusterNodeTransformationsAnnotation)
+
+			changed = true
+		}
+
+		return changed, nil
+	}
+
+	value, err := json.Marshal(next)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal the node transformations: %w", err)
+	}
+
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+
+	if node.Annotations[ClusterNodeTransformationsAnnotation] != string(value) {
+		node.Annotations[ClusterNodeTransformationsAnnotation] = string(value)
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// getNodeTransformations returns the keys applied by the previous transformation.
+func getNodeTransformations(node *v1.Node) nodeTransformations {
+	var res nodeTransformations
+
+	value, ok := node.Annotations[ClusterNodeTransformationsAnnotation]
+	if !ok {
+		return res
+	}
+
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
+		klog.ErrorS(err, "invalid node transformations annotation, ignoring it", "node", klog.KRef("", node.Name))
+
+		return nodeTransformations{}
+	}
+
+	return res
+}
+
+// applyNodeMap removes the previous keys which are not desired anymore and sets the desired values.
+func applyNodeMap(current map[string]string, prev []string, desired map[string]string) (map[string]string, bool) {
+	changed := false
+
+	for _, k := range prev {
+		if _, ok := desired[k]; ok {
+			continue
+		}
+
+		if _, ok := current[k]; ok {
+			delete(current, k)
+
+			changed = true
+		}
+	}
+
+	for k, v := range desired {
+		if r, ok := current[k]; ok && r == v {
+			continue
+		}
+
+		if current == nil {
+			current = map[string]string{}
+		}
+
+		current[k] = v
+		changed = true
+	}
+
+	return current, changed
+}
+
+// applyNodeTaints removes the previous taints which are not desired anymore and replaces the taints with the desired keys.
+func applyNodeTaints(current []v1.Taint, prev []string, desired map[string]string) ([]v1.Taint, bool) {
+	var res []v1.Taint
+
+	changed := false
+	found := map[string]bool{}
+
+	for _, taint := range current {
+		value, ok := desired[taint.Key]
+
+		switch {
+		case ok:
+			want := parseTaint(taint.Key, value)
+			if !found[taint.Key] && taint.Value == want.Value && taint.Effect == want.Effect {
+				found[taint.Key] = true
+				res = append(res, taint)
+
+				continue
+			}
+		case !slices.Contains(prev, taint.Key):
+			res = append(res, taint)
+
+			continue
+		}
+
+		changed = true
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(desired)) {
+		if found[k] {
+			continue
+		}
+
+		taint := parseTaint(k, desired[k])
+		if taint.Effect == v1.TaintEffectNoExecute {
+			taint.TimeAdded = &metav1.Time{Time: time.Now()}
+		}
+
+		res = append(res, taint)
+		changed = true
+	}
+
+	return res, changed
+}
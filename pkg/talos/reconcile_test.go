@@ -0,0 +1,227 @@
+package talos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	talosfake "github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+)
+
+func TestApplyNodeSpec(t *testing.T) {
+	for _, tt := range []struct {
+		name                string
+		node                *v1.Node
+		spec                *transformer.NodeSpec
+		expectedChanged     bool
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+		expectedTaints      []v1.Taint
+	}{
+		{
+			name: "new node",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"node-label": "true"},
+				},
+			},
+			spec: &transformer.NodeSpec{
+				Labels:      map[string]string{"label": "value"},
+				Annotations: map[string]string{"annotation": "value"},
+				Taints:      map[string]string{"taint": "value:NoSchedule"},
+			},
+			expectedChanged: true,
+			expectedLabels:  map[string]string{"node-label": "true", "label": "value"},
+			expectedAnnotations: map[string]string{
+				"annotation":                         "value",
+				ClusterNodeTransformationsAnnotation: `{"labels":["label"],"annotations":["annotation"],"taints":["taint"]}`,
+			},
+			expectedTaints: []v1.Taint{
+				{Key: "taint", Value: "value", Effect: v1.TaintEffectNoSchedule},
+			},
+		},
+		{
+			name: "node is up to date",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"label": "value"},
+					Annotations: map[string]string{
+						ClusterNodeTransformationsAnnotation: `{"labels":["label"],"taints":["taint"]}`,
+					},
+				},
+				Spec: v1.NodeSpec{
+					Taints: []v1.Taint{{Key: "taint", Effect: v1.TaintEffectNoSchedule}},
+				},
+			},
+			spec: &transformer.NodeSpec{
+				Labels: map[string]string{"label": "value"},
+				Taints: map[string]string{"taint": "NoSchedule"},
+			},
+			expectedLabels: map[string]string{"label": "value"},
+			expectedAnnotations: map[string]string{
+				ClusterNodeTransformationsAnnotation: `{"labels":["label"],"taints":["taint"]}`,
+			},
+			expectedTaints: []v1.Taint{
+				{Key: "taint", Effect: v1.TaintEffectNoSchedule},
+			},
+		},
+		{
+			name: "rules were changed",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"label": "value", "stale-label": "value", "node-label": "true"},
+					Annotations: map[string]string{
+						"stale-annotation":                   "value",
+						"node-annotation":                    "value",
+						ClusterNodeTransformationsAnnotation: `{"labels":["label","stale-label"],"annotations":["stale-annotation"],"taints":["stale-taint","taint"]}`,
+					},
+				},
+				Spec: v1.NodeSpec{
+					Taints: []v1.Taint{
+						{Key: "node-taint", Effect: v1.TaintEffectNoSchedule},
+						{Key: "stale-taint", Effect: v1.TaintEffectNoSchedule},
+						{Key: "taint", Value: "value", Effect: v1.TaintEffectNoSchedule},
+					},
+				},
+			},
+			spec: &transformer.NodeSpec{
+				Labels: map[string]string{"label": "new-value"},
+				Taints: map[string]string{"taint": "value:PreferNoSchedule"},
+			},
+			expectedChanged: true,
+			expectedLabels:  map[string]string{"label": "new-value", "node-label": "true"},
+			expectedAnnotations: map[string]string{
+				"node-annotation":                    "value",
+				ClusterNodeTransformationsAnnotation: `{"labels":["label"],"taints":["taint"]}`,
+			},
+			expectedTaints: []v1.Taint{
+				{Key: "node-taint", Effect: v1.TaintEffectNoSchedule},
+				{Key: "taint", Value: "value", Effect: v1.TaintEffectPreferNoSchedule},
+			},
+		},
+		{
+			name: "rules were removed",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"label": "value"},
+					Annotations: map[string]string{
+						ClusterNodeTransformationsAnnotation: `{"labels":["label"]}`,
+					},
+				},
+			},
+			spec:                &transformer.NodeSpec{},
+			expectedChanged:     true,
+			expectedLabels:      map[string]string{},
+			expectedAnnotations: map[string]string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, err := applyNodeSpec(tt.node, tt.spec)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedChanged, changed)
+			assert.Equal(t, tt.expectedLabels, tt.node.Labels)
+			assert.Equal(t, tt.expectedAnnotations, tt.node.Annotations)
+			assert.Equal(t, tt.expectedTaints, tt.node.Spec.Taints)
+		})
+	}
+}
+
+func TestNodeReconcilerReconcileNode(t *testing.T) {
+	talos := talosfake.NewClient("test-cluster", nil, nil, map[string]*talosfake.Node{
+		"192.168.0.1": {
+			Metadata:   &runtime.PlatformMetadataSpec{Platform: "metal", Zone: "zone-1"},
+			SystemInfo: &hardware.SystemInformationSpec{},
+		},
+	})
+
+	cfg := cloudConfig{
+		Global: cloudConfigGlobal{
+			TransformationsTrace: cloudConfigTransformationsTrace{Annotation: true},
+		},
+		Transformations: []transformer.NodeTerm{
+			{
+				Name:   "zone",
+				Labels: map[string]string{"node.example.com/zone": "{{ .Zone }}"},
+			},
+		},
+	}
+
+	node := func(taints ...v1.Taint) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+				Labels: map[string]string{
+					"node.example.com/stale": "true",
+				},
+				Annotations: map[string]string{
+					cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.1",
+					ClusterNodeTransformationsAnnotation:           `{"labels":["node.example.com/stale"]}`,
+				},
+			},
+			Spec: v1.NodeSpec{
+				ProviderID: "talos://metal/192.168.0.1",
+				Taints:     taints,
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name           string
+		node           *v1.Node
+		expectedLabels map[string]string
+		expectedTrace  string
+	}{
+		{
+			name: "initialized node",
+			node: node(),
+			expectedLabels: map[string]string{
+				"node.example.com/zone": "zone-1",
+			},
+			expectedTrace: `{"rules":[{"index":0,"name":"zone","labels":["node.example.com/zone"]}]}`,
+		},
+		{
+			name: "node is not initialized",
+			node: node(*uninitializedTaint),
+			expectedLabels: map[string]string{
+				"node.example.com/stale": "true",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newClient(&cfg, talos)
+			require.NoError(t, err)
+
+			client.kclient = fake.NewClientset(tt.node)
+
+			reconciler := newNodeReconciler(client)
+			require.NoError(t, reconciler.reconcileNode(t.Context(), tt.node.Name))
+			require.NoError(t, reconciler.reconcileNode(t.Context(), "node-2"))
+
+			node, err := client.kclient.CoreV1().Nodes().Get(t.Context(), tt.node.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedLabels, node.Labels)
+			assert.Equal(t, tt.expectedTrace, node.Annotations[ClusterNodeTransformationsTraceAnnotation])
+		})
+	}
+}
+
+func TestApplyNodeTaintsNoExecute(t *testing.T) {
+	taints, changed := applyNodeTaints(nil, nil, map[string]string{"taint": "NoExecute"})
+	assert.True(t, changed)
+	require.Len(t, taints, 1)
+	assert.NotNil(t, taints[0].TimeAdded)
+
+	_, changed = applyNodeTaints(taints, []string{"taint"}, map[string]string{"taint": "NoExecute"})
+	assert.False(t, changed)
+}
@@ -0,0 +1,132 @@
+package talos
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
+
+	"k8s.io/klog/v2"
+)
+
+const configReloadInterval = 10 * time.Second
+
+// configReloader reloads the cloud config when the config file changes, for example when the mounted ConfigMap is updated.
+// The invalid config is rejected, and the previous config stays active.
+type configReloader struct {
+	c        *client
+	path     string
+	interval time.Duration
+	checksum string
+
+	// onReload is called after the new config is active.
+	onReload func(context.Context)
+}
+
+func newConfigReloader(client *client, path string) *configReloader {
+	r := &configReloader{
+		c:        client,
+		path:     path,
+		interval: configReloadInterval,
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		r.checksum = configChecksum(data)
+
+		metrics.ConfigActive(r.checksum)
+	}
+
+	return r
+}
+
+// Run checks the config file for changes until the context is done.
+func (r *configReloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		r.reload(ctx)
+	}
+}
+
+// reload reads the config file and activates the config if the file has changed and the config is valid.
+func (r *configReloader) reload(ctx context.Context) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		klog.ErrorS(err, "failed to read the cloud config", "path", r.path)
+
+		return
+	}
+
+	checksum := configChecksum(data)
+	if checksum == r.checksum {
+		return
+	}
+
+	// The same invalid config is not reported again.
+	r.checksum = checksum
+
+	cfg, err := readCloudConfig(bytes.NewReader(data))
+	if err != nil {
+		metrics.ConfigReload(metrics.ConfigReloadResultError)
+
+		klog.ErrorS(err, "invalid cloud config, the previous config stays active", "path", r.path)
+
+		return
+	}
+
+	if sections := keepStartupConfig(r.c.config(), &cfg); len(sections) > 0 {
+		klog.InfoS("cloud config sections were changed, the restart is required to apply them", "path", r.path, "sections", sections)
+	}
+
+	r.c.setConfig(&cfg)
+
+	metrics.ConfigActive(checksum)
+	metrics.ConfigReload(metrics.ConfigReloadResultSuccess)
+
+	klog.InfoS("cloud config was reloaded", "path", r.path, "hash", checksum)
+
+	if r.onReload != nil {
+		r.onReload(ctx)
+	}
+}
+
+// keepStartupConfig keeps the active sections of the config, which are used only on start.
+// It returns the names of the changed sections.
+func keepStartupConfig(active, next *cloudConfig) []string {
+	var changed []string
+
+	keepConfig(&changed, "global.clusterName", active.Global.ClusterName, &next.Global.ClusterName)
+	keepConfig(&changed, "global.talos", active.Global.Talos, &next.Global.Talos)
+	keepConfig(&changed, "global.clusters", active.Global.Clusters, &next.Global.Clusters)
+	keepConfig(&changed, "global.routes.enabled", active.Global.Routes.Enabled, &next.Global.Routes.Enabled)
+	keepConfig(&changed, "global.loadBalancer", active.Global.LoadBalancer, &next.Global.LoadBalancer)
+	keepConfig(&changed, "global.cache", active.Global.Cache, &next.Global.Cache)
+	keepConfig(&changed, "global.reconcile.enabled", active.Global.Reconcile.Enabled, &next.Global.Reconcile.Enabled)
+
+	return changed
+}
+
+func keepConfig[T any](changed *[]string, name string, active T, next *T) {
+	if !reflect.DeepEqual(active, *next) {
+		*changed = append(*changed, name)
+		*next = active
+	}
+}
+
+func configChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
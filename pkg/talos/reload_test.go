@@ -0,0 +1,116 @@
+package talos
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	talosfake "github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+)
+
+func TestConfigReloaderReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ccm-config.yaml")
+
+	writeConfig := func(config string) {
+		require.NoError(t, os.WriteFile(path, []byte(strings.TrimSpace(config)), 0o600))
+	}
+
+	writeConfig(`
+global:
+  clusterName: test-cluster
+  preferIPv6: false
+`)
+
+	cfg, err := readCloudConfig(strings.NewReader(`{"global":{"clusterName":"test-cluster"}}`))
+	require.NoError(t, err)
+
+	client, err := newClient(&cfg, talosfake.NewClient("test-cluster", nil, nil, nil))
+	require.NoError(t, err)
+
+	reloaded := 0
+
+	reloader := newConfigReloader(client, path)
+	reloader.onReload = func(_ context.Context) { reloaded++ }
+
+	for _, tt := range []struct {
+		name             string
+		config           string
+		expectedReloaded int
+		expectedConfig   func(*testing.T, *cloudConfig)
+	}{
+		{
+			name: "config is not changed",
+			config: `
+global:
+  clusterName: test-cluster
+  preferIPv6: false
+`,
+			expectedConfig: func(t *testing.T, cfg *cloudConfig) {
+				assert.Empty(t, cfg.Transformations)
+			},
+		},
+		{
+			name: "transformations are changed",
+			config: `
+global:
+  clusterName: test-cluster
+  preferIPv6: true
+transformations:
+  - name: web
+    labels:
+      node-role.kubernetes.io/web: ""
+`,
+			expectedReloaded: 1,
+			expectedConfig: func(t *testing.T, cfg *cloudConfig) {
+				assert.True(t, cfg.Global.PreferIPv6)
+				assert.Len(t, cfg.Transformations, 1)
+			},
+		},
+		{
+			name: "config is invalid",
+			config: `
+global:
+  machineReplacementAction: unknown
+`,
+			expectedReloaded: 1,
+			expectedConfig: func(t *testing.T, cfg *cloudConfig) {
+				assert.Len(t, cfg.Transformations, 1)
+			},
+		},
+		{
+			name: "startup sections are changed",
+			config: `
+global:
+  clusterName: new-cluster
+  cache:
+    enabled: true
+  reconcile:
+    enabled: true
+    interval: 5m
+`,
+			expectedReloaded: 2,
+			expectedConfig: func(t *testing.T, cfg *cloudConfig) {
+				assert.Equal(t, "test-cluster", cfg.Global.ClusterName)
+				assert.False(t, cfg.Global.Cache.Enabled)
+				assert.False(t, cfg.Global.Reconcile.Enabled)
+				assert.Equal(t, 5*time.Minute, cfg.Global.Reconcile.Interval)
+				assert.Empty(t, cfg.Transformations)
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			writeConfig(tt.config)
+
+			reloader.reload(t.Context())
+
+			assert.Equal(t, tt.expectedReloaded, reloaded)
+			tt.expectedConfig(t, client.config())
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package talos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"slices"
+
+	talosconfig "github.com/siderolabs/talos/pkg/machinery/config/config"
+	networkconfig "github.com/siderolabs/talos/pkg/machinery/config/types/network"
+	"github.com/siderolabs/talos/pkg/machinery/nethelpers"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+type routes struct {
+	c *client
+}
+
+func newRoutes(client *client) *routes {
+	return &routes{
+		c: client,
+	}
+}
+
+// ListRoutes lists all managed routes that belong to the specified clusterName.
+// The route is reported only if it exists on all other reachable nodes, so the route controller creates the missing ones.
+func (r *routes) ListRoutes(ctx context.Context, clusterName string) ([]*cloudprovider.Route, error) {
+	klog.V(4).InfoS("routes.ListRoutes() called", "cluster", clusterName)
+
+	nodes, err := getTalosNodes(ctx, r.c)
+	if err != nil {
+		return nil, err
+	}
+
+	metric := r.c.config().Global.Routes.metric()
+	nodeRoutes := map[string][]network.RouteStatusSpec{}
+
+	for _, node := range nodes {
+		statuses, err := getTalosNodeRoutes(ctx, r.c, node)
+		if err != nil {
+			klog.ErrorS(err, "failed to get routes from the node, skipping", "node", klog.KRef("", node.name))
+
+			continue
+		}
+
+		nodeRoutes[node.name] = statuses
+	}
+
+	return managedRoutes(nodes, nodeRoutes, metric), nil
+}
+
+// CreateRoute creates the described managed route on all other nodes.
+// Route.Name will be ignored, the route is identified by the destination CIDR.
+func (r *routes) CreateRoute(ctx context.Context, clusterName string, nameHint string, route *cloudprovider.Route) error {
+	klog.V(4).InfoS("routes.CreateRoute() called", "cluster", clusterName, "nameHint", nameHint,
+		"node", klog.KRef("", string(route.TargetNode)), "cidr", route.DestinationCIDR)
+
+	dst, err := netip.ParsePrefix(route.DestinationCIDR)
+	if err != nil {
+		return fmt.Errorf("failed to parse destination CIDR %s: %w", route.DestinationCIDR, err)
+	}
+
+	nodes, err := getTalosNodes(ctx, r.c)
+	if err != nil {
+		return err
+	}
+
+	idx := slices.IndexFunc(nodes, func(n talosNode) bool { return n.name == string(route.TargetNode) })
+	if idx < 0 {
+		return fmt.Errorf("target node %s not found", route.TargetNode)
+	}
+
+	gw, ok := routeGateway(nodes[idx].nodeIPs, dst)
+	if !ok {
+		return fmt.Errorf("target node %s has no address for the destination CIDR %s", route.TargetNode, route.DestinationCIDR)
+	}
+
+	var errs []error
+
+	for _, node := range nodes {
+		if node.name == string(route.TargetNode) {
+			continue
+		}
+
+		if err := r.updateNodeRoute(ctx, node, dst, gw); err != nil {
+			errs = append(errs, fmt.Errorf("failed to create route on node %s: %w", node.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// DeleteRoute deletes the managed route with the destination CIDR from all nodes.
+func (r *routes) DeleteRoute(ctx context.Context, clusterName string, route *cloudprovider.Route) error {
+	klog.V(4).InfoS("routes.DeleteRoute() called", "cluster", clusterName,
+		"node", klog.KRef("", string(route.TargetNode)), "cidr", route.DestinationCIDR)
+
+	dst, err := netip.ParsePrefix(route.DestinationCIDR)
+	if err != nil {
+		return fmt.Errorf("failed to parse destination CIDR %s: %w", route.DestinationCIDR, err)
+	}
+
+	nodes, err := getTalosNodes(ctx, r.c)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, node := range nodes {
+		if err := r.updateNodeRoute(ctx, node, dst, netip.Addr{}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete route on node %s: %w", node.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// updateNodeRoute sets the managed route to the destination CIDR in the machine config of the node.
+// If the gateway is not valid, the route is removed.
+func (r *routes) updateNodeRoute(ctx context.Context, node talosNode, dst netip.Prefix, gw netip.Addr) error {
+	var link string
+
+	if gw.IsValid() {
+		statuses, err := getTalosNodeRoutes(ctx, r.c, node)
+		if err != nil {
+			return err
+		}
+
+		if link = directLinkName(statuses, gw); link == "" {
+			return fmt.Errorf("gateway %s is not directly reachable", gw)
+		}
+	}
+
+	klog.V(4).InfoS("routes.updateNodeRoute()", "node", klog.KRef("", node.name), "cidr", dst, "gateway", gw, "link", link)
+
+	return updateTalosNodeConfig(ctx, r.c, node, func(docs []talosconfig.Document) ([]talosconfig.Document, bool) {
+		return updateLinkRoutes(docs, link, dst, gw, r.c.config().Global.Routes.metric())
+	})
+}
+
+// managedRoutes returns the managed routes, that exist on all other reachable nodes.
+// Routes to unknown gateways are always returned, so the route controller can remove them.
+func managedRoutes(nodes []talosNode, nodeRoutes map[string][]network.RouteStatusSpec, metric uint32) []*cloudprovider.Route {
+	type routeKey struct {
+		dst netip.Prefix
+		gw  netip.Addr
+	}
+
+	counts := map[routeKey]int{}
+	keys := []routeKey{}
+
+	for _, node := range nodes {
+		for _, status := range nodeRoutes[node.name] {
+			if !isManagedRoute(status, metric) {
+				continue
+			}
+
+			key := routeKey{dst: status.Destination, gw: status.Gateway}
+			if counts[key] == 0 {
+				keys = append(keys, key)
+			}
+
+			counts[key]++
+		}
+	}
+
+	res := []*cloudprovider.Route{}
+
+	for _, key := range keys {
+		target := ""
+		targetReachable := false
+
+		for _, node := range nodes {
+			if slices.Contains(node.nodeIPs, key.gw) {
+				target = node.name
+				_, targetReachable = nodeRoutes[node.name]
+
+				break
+			}
+		}
+
+		expected := len(nodeRoutes)
+		if targetReachable {
+			expected--
+		}
+
+		if target != "" && counts[key] < expected {
+			continue
+		}
+
+		res = append(res, &cloudprovider.Route{
+			Name:            fmt.Sprintf("%s-%s", target, key.dst),
+			TargetNode:      types.NodeName(target),
+			DestinationCIDR: key.dst.String(),
+		})
+	}
+
+	return res
+}
+
+func isManagedRoute(status network.RouteStatusSpec, metric uint32) bool {
+	return status.Priority == metric &&
+		status.Table == nethelpers.TableMain &&
+		status.Gateway.IsValid() &&
+		status.Destination.Bits() > 0
+}
+
+// routeGateway returns the node address of the same IP family as the destination CIDR.
+func routeGateway(nodeIPs []netip.Addr, dst netip.Prefix) (netip.Addr, bool) {
+	for _, ip := range nodeIPs {
+		if ip.Is4() == dst.Addr().Is4() {
+			return ip, true
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// updateLinkRoutes removes the managed routes to the destination CIDR from all link configs,
+// and adds the route via the gateway to the link config, if the gateway is valid.
+// Link config created only for the managed routes is removed with the last route.
+func updateLinkRoutes(docs []talosconfig.Document, link string, dst netip.Prefix, gw netip.Addr, metric uint32) ([]talosconfig.Document, bool) {
+	res := make([]talosconfig.Document, 0, len(docs)+1)
+	changed := false
+	found := false
+
+	for _, doc := range docs {
+		linkConfig, ok := doc.(*networkconfig.LinkConfigV1Alpha1)
+		if !ok {
+			res = append(res, doc)
+
+			continue
+		}
+
+		target := gw.IsValid() && linkConfig.MetaName == link
+		linkRoutes := make([]networkconfig.RouteConfig, 0, len(linkConfig.LinkRoutes)+1)
+
+		for _, route := range linkConfig.LinkRoutes {
+			if route.RouteDestination.Prefix != dst || route.RouteMetric != metric {
+				linkRoutes = append(linkRoutes, route)
+
+				continue
+			}
+
+			if target && !found {
+				linkRoutes = append(linkRoutes, newRouteConfig(dst, gw, metric))
+				found = true
+			}
+		}
+
+		if target && !found {
+			linkRoutes = append(linkRoutes, newRouteConfig(dst, gw, metric))
+			found = true
+		}
+
+		if slices.Equal(linkRoutes, linkConfig.LinkRoutes) {
+			res = append(res, doc)
+
+			continue
+		}
+
+		changed = true
+
+		if len(linkRoutes) == 0 {
+			linkRoutes = nil
+		}
+
+		linkConfig.LinkRoutes = linkRoutes
+
+		if reflect.DeepEqual(linkConfig, networkconfig.NewLinkConfigV1Alpha1(linkConfig.MetaName)) {
+			continue
+		}
+
+		res = append(res, linkConfig)
+	}
+
+	if gw.IsValid() && !found {
+		linkConfig := networkconfig.NewLinkConfigV1Alpha1(link)
+		linkConfig.LinkRoutes = []networkconfig.RouteConfig{newRouteConfig(dst, gw, metric)}
+
+		res = append(res, linkConfig)
+		changed = true
+	}
+
+	return res, changed
+}
+
+func newRouteConfig(dst netip.Prefix, gw netip.Addr, metric uint32) networkconfig.RouteConfig {
+	return networkconfig.RouteConfig{
+		RouteDestination: networkconfig.Prefix{Prefix: dst},
+		RouteGateway:     networkconfig.Addr{Addr: gw},
+		RouteMetric:      metric,
+	}
+}
@@ -0,0 +1,302 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	talosfake "github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+	talosconfig "github.com/siderolabs/talos/pkg/machinery/config/config"
+	networkconfig "github.com/siderolabs/talos/pkg/machinery/config/types/network"
+	"github.com/siderolabs/talos/pkg/machinery/nethelpers"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	cloudprovider "k8s.io/cloud-provider"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+)
+
+func TestManagedRoutes(t *testing.T) {
+	nodes := []talosNode{
+		{name: "node1", nodeIPs: []netip.Addr{netip.MustParseAddr("192.168.0.1")}},
+		{name: "node2", nodeIPs: []netip.Addr{netip.MustParseAddr("192.168.0.2")}},
+		{name: "node3", nodeIPs: []netip.Addr{netip.MustParseAddr("192.168.0.3")}},
+	}
+
+	route := func(dst, gw string) network.RouteStatusSpec {
+		return network.RouteStatusSpec{
+			Destination: netip.MustParsePrefix(dst),
+			Gateway:     netip.MustParseAddr(gw),
+			Table:       nethelpers.TableMain,
+			Priority:    defaultRoutesMetric,
+		}
+	}
+
+	for _, tt := range []struct {
+		name       string
+		nodeRoutes map[string][]network.RouteStatusSpec
+		expected   []*cloudprovider.Route
+	}{
+		{
+			name: "no managed routes",
+			nodeRoutes: map[string][]network.RouteStatusSpec{
+				"node1": {{Destination: netip.MustParsePrefix("10.32.1.0/24"), Gateway: netip.MustParseAddr("192.168.0.2"), Table: nethelpers.TableMain, Priority: 1024}},
+				"node2": {},
+				"node3": {},
+			},
+			expected: []*cloudprovider.Route{},
+		},
+		{
+			name: "route exists on all other nodes",
+			nodeRoutes: map[string][]network.RouteStatusSpec{
+				"node1": {route("10.32.2.0/24", "192.168.0.2")},
+				"node2": {},
+				"node3": {route("10.32.2.0/24", "192.168.0.2")},
+			},
+			expected: []*cloudprovider.Route{
+				{Name: "node2-10.32.2.0/24", TargetNode: "node2", DestinationCIDR: "10.32.2.0/24"},
+			},
+		},
+		{
+			name: "route is missing on the node",
+			nodeRoutes: map[string][]network.RouteStatusSpec{
+				"node1": {route("10.32.2.0/24", "192.168.0.2")},
+				"node2": {},
+				"node3": {},
+			},
+			expected: []*cloudprovider.Route{},
+		},
+		{
+			name: "node is unreachable",
+			nodeRoutes: map[string][]network.RouteStatusSpec{
+				"node1": {route("10.32.2.0/24", "192.168.0.2"), route("10.32.3.0/24", "192.168.0.3")},
+				"node2": {route("10.32.3.0/24", "192.168.0.3")},
+			},
+			expected: []*cloudprovider.Route{
+				{Name: "node2-10.32.2.0/24", TargetNode: "node2", DestinationCIDR: "10.32.2.0/24"},
+				{Name: "node3-10.32.3.0/24", TargetNode: "node3", DestinationCIDR: "10.32.3.0/24"},
+			},
+		},
+		{
+			name: "route to the deleted node",
+			nodeRoutes: map[string][]network.RouteStatusSpec{
+				"node1": {route("10.32.4.0/24", "192.168.0.4")},
+				"node2": {},
+				"node3": {},
+			},
+			expected: []*cloudprovider.Route{
+				{Name: "-10.32.4.0/24", TargetNode: "", DestinationCIDR: "10.32.4.0/24"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, managedRoutes(nodes, tt.nodeRoutes, defaultRoutesMetric))
+		})
+	}
+}
+
+func TestRouteGateway(t *testing.T) {
+	nodeIPs := []netip.Addr{netip.MustParseAddr("192.168.0.1"), netip.MustParseAddr("fd00::1")}
+
+	gw, ok := routeGateway(nodeIPs, netip.MustParsePrefix("10.32.1.0/24"))
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParseAddr("192.168.0.1"), gw)
+
+	gw, ok = routeGateway(nodeIPs, netip.MustParsePrefix("fd00:10:32::/80"))
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParseAddr("fd00::1"), gw)
+
+	_, ok = routeGateway(nodeIPs[:1], netip.MustParsePrefix("fd00:10:32::/80"))
+	assert.False(t, ok)
+}
+
+func TestDirectLinkName(t *testing.T) {
+	statuses := []network.RouteStatusSpec{
+		{Destination: netip.MustParsePrefix("0.0.0.0/0"), Gateway: netip.MustParseAddr("192.168.0.254"), Table: nethelpers.TableMain, OutLinkName: "eth0"},
+		{Destination: netip.MustParsePrefix("192.168.0.0/24"), Table: nethelpers.TableMain, Scope: nethelpers.ScopeLink, OutLinkName: "eth0"},
+		{Destination: netip.MustParsePrefix("172.16.0.0/24"), Table: nethelpers.TableMain, Scope: nethelpers.ScopeLink, OutLinkName: "eth1"},
+	}
+
+	assert.Equal(t, "eth0", directLinkName(statuses, netip.MustParseAddr("192.168.0.2")))
+	assert.Equal(t, "eth1", directLinkName(statuses, netip.MustParseAddr("172.16.0.2")))
+	assert.Empty(t, directLinkName(statuses, netip.MustParseAddr("10.0.0.2")))
+}
+
+func TestUpdateLinkRoutes(t *testing.T) {
+	dst := netip.MustParsePrefix("10.32.2.0/24")
+	gw := netip.MustParseAddr("192.168.0.2")
+
+	linkConfig := func(name string, routes ...networkconfig.RouteConfig) *networkconfig.LinkConfigV1Alpha1 {
+		cfg := networkconfig.NewLinkConfigV1Alpha1(name)
+		cfg.LinkRoutes = routes
+
+		return cfg
+	}
+
+	otherRoute := networkconfig.RouteConfig{
+		RouteDestination: networkconfig.Prefix{Prefix: netip.MustParsePrefix("10.0.0.0/8")},
+		RouteGateway:     networkconfig.Addr{Addr: netip.MustParseAddr("192.168.0.254")},
+	}
+
+	for _, tt := range []struct {
+		name            string
+		docs            []talosconfig.Document
+		gw              netip.Addr
+		expected        []talosconfig.Document
+		expectedChanged bool
+	}{
+		{
+			name:            "add route to the new link config",
+			docs:            []talosconfig.Document{},
+			gw:              gw,
+			expected:        []talosconfig.Document{linkConfig("eth0", newRouteConfig(dst, gw, defaultRoutesMetric))},
+			expectedChanged: true,
+		},
+		{
+			name:            "add route to the existing link config",
+			docs:            []talosconfig.Document{linkConfig("eth0", otherRoute)},
+			gw:              gw,
+			expected:        []talosconfig.Document{linkConfig("eth0", otherRoute, newRouteConfig(dst, gw, defaultRoutesMetric))},
+			expectedChanged: true,
+		},
+		{
+			name:            "route already exists",
+			docs:            []talosconfig.Document{linkConfig("eth0", newRouteConfig(dst, gw, defaultRoutesMetric))},
+			gw:              gw,
+			expected:        []talosconfig.Document{linkConfig("eth0", newRouteConfig(dst, gw, defaultRoutesMetric))},
+			expectedChanged: false,
+		},
+		{
+			name:            "update route gateway",
+			docs:            []talosconfig.Document{linkConfig("eth0", newRouteConfig(dst, netip.MustParseAddr("192.168.0.3"), defaultRoutesMetric))},
+			gw:              gw,
+			expected:        []talosconfig.Document{linkConfig("eth0", newRouteConfig(dst, gw, defaultRoutesMetric))},
+			expectedChanged: true,
+		},
+		{
+			name:            "move route to the other link",
+			docs:            []talosconfig.Document{linkConfig("eth1", otherRoute, newRouteConfig(dst, gw, defaultRoutesMetric))},
+			gw:              gw,
+			expected:        []talosconfig.Document{linkConfig("eth1", otherRoute), linkConfig("eth0", newRouteConfig(dst, gw, defaultRoutesMetric))},
+			expectedChanged: true,
+		},
+		{
+			name:            "delete route",
+			docs:            []talosconfig.Document{linkConfig("eth0", otherRoute, newRouteConfig(dst, gw, defaultRoutesMetric))},
+			expected:        []talosconfig.Document{linkConfig("eth0", otherRoute)},
+			expectedChanged: true,
+		},
+		{
+			name:            "delete last route with the link config",
+			docs:            []talosconfig.Document{linkConfig("eth0", newRouteConfig(dst, gw, defaultRoutesMetric))},
+			expected:        []talosconfig.Document{},
+			expectedChanged: true,
+		},
+		{
+			name:            "delete route does not exist",
+			docs:            []talosconfig.Document{linkConfig("eth0", otherRoute)},
+			expected:        []talosconfig.Document{linkConfig("eth0", otherRoute)},
+			expectedChanged: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, changed := updateLinkRoutes(tt.docs, "eth0", dst, tt.gw, defaultRoutesMetric)
+			assert.Equal(t, tt.expectedChanged, changed)
+			assert.Equal(t, tt.expected, docs)
+		})
+	}
+}
+
+// slowConfigClient delays the machine config responses, so the concurrent updates overlap.
+type slowConfigClient struct {
+	*talosfake.Client
+}
+
+func (c *slowConfigClient) GetNodeConfigDocuments(ctx context.Context, nodeIP string) ([]talosconfig.Document, error) {
+	docs, err := c.Client.GetNodeConfigDocuments(ctx, nodeIP)
+
+	time.Sleep(10 * time.Millisecond)
+
+	return docs, err
+}
+
+func TestCreateRouteConcurrent(t *testing.T) {
+	const count = 5
+
+	talosNodes := map[string]*talosfake.Node{}
+	kubeNodes := []runtime.Object{}
+
+	for i := range count {
+		ip := fmt.Sprintf("192.168.0.%d", i+1)
+
+		talosNodes[ip] = &talosfake.Node{
+			Routes: []network.RouteStatusSpec{
+				{
+					Destination: netip.MustParsePrefix("192.168.0.0/24"),
+					Table:       nethelpers.TableMain,
+					Scope:       nethelpers.ScopeLink,
+					OutLinkName: "eth0",
+				},
+			},
+		}
+
+		kubeNodes = append(kubeNodes, &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("node-%d", i),
+				Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: ip},
+			},
+			Spec: v1.NodeSpec{ProviderID: "talos://metal/" + ip},
+		})
+	}
+
+	talos := talosfake.NewClient("test-cluster", nil, nil, talosNodes)
+
+	cfg := cloudConfig{}
+
+	client, err := newClient(&cfg, &slowConfigClient{Client: talos})
+	require.NoError(t, err)
+
+	client.kclient = fake.NewClientset(kubeNodes...)
+
+	r := newRoutes(client)
+
+	var wg sync.WaitGroup
+
+	for i := range count {
+		wg.Go(func() {
+			assert.NoError(t, r.CreateRoute(t.Context(), "test-cluster", "", &cloudprovider.Route{
+				TargetNode:      types.NodeName(fmt.Sprintf("node-%d", i)),
+				DestinationCIDR: fmt.Sprintf("10.32.%d.0/24", i),
+			}))
+		})
+	}
+
+	wg.Wait()
+
+	for i := range count {
+		docs, err := talos.GetNodeConfigDocuments(t.Context(), fmt.Sprintf("192.168.0.%d", i+1))
+		require.NoError(t, err)
+		require.Len(t, docs, 1)
+
+		linkConfig, ok := docs[0].(*networkconfig.LinkConfigV1Alpha1)
+		require.True(t, ok)
+
+		dsts := []string{}
+		for _, route := range linkConfig.LinkRoutes {
+			dsts = append(dsts, route.RouteDestination.Prefix.String())
+		}
+
+		assert.Len(t, dsts, count-1, "node-%d routes %v", i, dsts)
+		assert.NotContains(t, dsts, fmt.Sprintf("10.32.%d.0/24", i))
+	}
+}
@@ -0,0 +1,75 @@
+package talos
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+)
+
+// nodeSystem requests the operating system information of the node for the transformation rules.
+type nodeSystem struct {
+	ctx    context.Context //nolint:containedctx
+	c      *client
+	nodeIP string
+}
+
+var _ transformer.NodeSystem = &nodeSystem{}
+
+func (s *nodeSystem) TalosVersion() (string, error) {
+	mc := metrics.NewMetricContext("version")
+
+	res, err := s.c.talos.GetNodeVersion(s.ctx, s.nodeIP)
+	if mc.ObserveRequest(err) != nil {
+		return "", err
+	}
+
+	return res.Version, nil
+}
+
+func (s *nodeSystem) KernelVersion() (string, error) {
+	mc := metrics.NewMetricContext("kernelversion")
+
+	res, err := s.c.talos.GetNodeKernelVersion(s.ctx, s.nodeIP)
+
+	return res, mc.ObserveRequest(err)
+}
+
+func (s *nodeSystem) MachineType() (string, error) {
+	mc := metrics.NewMetricContext("machinetype")
+
+	res, err := s.c.talos.GetNodeMachineType(s.ctx, s.nodeIP)
+
+	return res, mc.ObserveRequest(err)
+}
+
+func (s *nodeSystem) Extensions() ([]runtime.ExtensionStatusSpec, error) {
+	mc := metrics.NewMetricContext("extensions")
+
+	res, err := s.c.talos.GetNodeExtensions(s.ctx, s.nodeIP)
+
+	return res, mc.ObserveRequest(err)
+}
+
+// Arch returns the CPU architecture of the node.
+func (s *nodeSystem) Arch() (string, error) {
+	mc := metrics.NewMetricContext("arch")
+
+	res, err := s.c.talos.GetNodeArch(s.ctx, s.nodeIP)
+
+	return res, mc.ObserveRequest(err)
+}
+
+// SecureBoot returns the secure boot state of the node, true or false.
+func (s *nodeSystem) SecureBoot() (string, error) {
+	mc := metrics.NewMetricContext("securitystate")
+
+	res, err := s.c.talos.GetNodeSecurityState(s.ctx, s.nodeIP)
+	if mc.ObserveRequest(err) != nil {
+		return "", err
+	}
+
+	return strconv.FormatBool(res.SecureBoot), nil
+}
@@ -0,0 +1,60 @@
+package talos
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// nodeTraceAnnotation returns the value of the transformations trace annotation.
+func nodeTraceAnnotation(trace *transformer.Trace) (string, error) {
+	value, err := json.Marshal(trace)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the transformations trace: %w", err)
+	}
+
+	return string(value), nil
+}
+
+// applyNodeTrace sets the transformations trace annotation of the node,
+// the annotation is removed if the trace annotation is disabled.
+func applyNodeTrace(node *v1.Node, trace *transformer.Trace, enabled bool) (bool, error) {
+	current, ok := node.Annotations[ClusterNodeTransformationsTraceAnnotation]
+
+	if !enabled || trace == nil {
+		if ok {
+			delete(node.Annotations, ClusterNodeTransformationsTraceAnnotation)
+		}
+
+		return ok, nil
+	}
+
+	value, err := nodeTraceAnnotation(trace)
+	if err != nil {
+		return false, err
+	}
+
+	if ok && current == value {
+		return false, nil
+	}
+
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+
+	node.Annotations[ClusterNodeTransformationsTraceAnnotation] = value
+
+	return true, nil
+}
+
+// recordNodeTrace emits the transformations trace as the node event.
+func recordNodeTrace(c *client, node *v1.Node, trace *transformer.Trace) {
+	if trace == nil {
+		return
+	}
+
+	recordNodeEvent(c, node, v1.EventTypeNormal, "TalosNodeTransformationTrace", "Transformation rules of the node %s: %s", node.Name, trace)
+}
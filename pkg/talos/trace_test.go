@@ -0,0 +1,58 @@
+package talos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyNodeTrace(t *testing.T) {
+	trace := &transformer.Trace{Rules: []transformer.TraceRule{{Index: 0, Name: "web", Labels: []string{"node-role.kubernetes.io/web"}}}}
+	value := `{"rules":[{"index":0,"name":"web","labels":["node-role.kubernetes.io/web"]}]}`
+
+	for _, tt := range []struct {
+		name                string
+		annotations         map[string]string
+		enabled             bool
+		expectedChanged     bool
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:                "trace is set",
+			enabled:             true,
+			expectedChanged:     true,
+			expectedAnnotations: map[string]string{ClusterNodeTransformationsTraceAnnotation: value},
+		},
+		{
+			name:                "trace is not changed",
+			annotations:         map[string]string{ClusterNodeTransformationsTraceAnnotation: value},
+			enabled:             true,
+			expectedAnnotations: map[string]string{ClusterNodeTransformationsTraceAnnotation: value},
+		},
+		{
+			name:                "trace is disabled",
+			annotations:         map[string]string{ClusterNodeTransformationsTraceAnnotation: value, "example.com/custom": "true"},
+			expectedChanged:     true,
+			expectedAnnotations: map[string]string{"example.com/custom": "true"},
+		},
+		{
+			name: "trace is disabled on the node without the trace",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: tt.annotations}}
+
+			changed, err := applyNodeTrace(node, trace, tt.enabled)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedChanged, changed)
+			assert.Equal(t, tt.expectedAnnotations, node.Annotations)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+type zones struct {
+	c *client
+}
+
+func newZones(client *client) *zones {
+	return &zones{
+		c: client,
+	}
+}
+
+// GetZone returns the Zone containing the current failure zone and locality region that the program is running in.
+// The cloud controller manager does not run on the node, so it is not implemented.
+func (z *zones) GetZone(_ context.Context) (cloudprovider.Zone, error) {
+	return cloudprovider.Zone{}, cloudprovider.NotImplemented
+}
+
+// GetZoneByProviderID returns the Zone containing the current zone and locality region of the node specified by providerID.
+func (z *zones) GetZoneByProviderID(ctx context.Context, providerID string) (cloudprovider.Zone, error) {
+	klog.V(4).InfoS("zones.GetZoneByProviderID() called", "providerID", providerID)
+
+	nodes, err := z.c.nodesByIndex(nodeProviderIDIndex, providerID)
+	if err != nil {
+		return cloudprovider.Zone{}, err
+	}
+
+	if len(nodes) == 0 {
+		return cloudprovider.Zone{}, cloudprovider.InstanceNotFound
+	}
+
+	return z.getZone(ctx, nodes[0])
+}
+
+// GetZoneByNodeName returns the Zone containing the current zone and locality region of the node specified by node name.
+func (z *zones) GetZoneByNodeName(ctx context.Context, nodeName types.NodeName) (cloudprovider.Zone, error) {
+	klog.V(4).InfoS("zones.GetZoneByNodeName() called", "node", klog.KRef("", string(nodeName)))
+
+	node, err := z.c.kclient.CoreV1().Nodes().Get(ctx, string(nodeName), metav1.GetOptions{})
+	if err != nil {
+		return cloudprovider.Zone{}, fmt.Errorf("error getting node %s: %w", nodeName, err)
+	}
+
+	return z.getZone(ctx, node)
+}
+
+func (z *zones) getZone(ctx context.Context, node *v1.Node) (cloudprovider.Zone, error) {
+	nodeIPs := getTalosNodeIPs(z.c.config(), node)
+	if len(nodeIPs) == 0 {
+		return cloudprovider.Zone{}, fmt.Errorf("node %s has no addresses", node.Name)
+	}
+
+	nm, err := getNodeMetadata(ctx, z.c, node, nodeIPs)
+	if err != nil {
+		return cloudprovider.Zone{}, err
+	}
+
+	return cloudprovider.Zone{
+		FailureDomain: nm.meta.Zone,
+		Region:        nm.meta.Region,
+	}, nil
+}
@@ -0,0 +1,143 @@
+package talos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/nodeselector"
+	talosfake "github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	cloudprovider "k8s.io/cloud-provider"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+)
+
+func TestGetZone(t *testing.T) {
+	z := newZones(nil)
+
+	zone, err := z.GetZone(t.Context())
+	assert.ErrorIs(t, err, cloudprovider.NotImplemented)
+	assert.Equal(t, cloudprovider.Zone{}, zone)
+}
+
+func TestGetZoneByProviderIDNotFound(t *testing.T) {
+	cfg := cloudConfig{}
+
+	c := &client{
+		cfg: &cfg,
+		kclient: fake.NewClientset(&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+			Spec:       v1.NodeSpec{ProviderID: "talos://metal/192.168.0.1"},
+		}),
+	}
+
+	startNodeInformer(t, c)
+
+	z := newZones(c)
+
+	zone, err := z.GetZoneByProviderID(t.Context(), "talos://metal/192.168.0.2")
+	assert.ErrorIs(t, err, cloudprovider.InstanceNotFound)
+	assert.Equal(t, cloudprovider.Zone{}, zone)
+}
+
+func TestGetZoneByNode(t *testing.T) {
+	talos := talosfake.NewClient("test-cluster", nil, nil, map[string]*talosfake.Node{
+		"192.168.0.1": {
+			Metadata:   &runtime.PlatformMetadataSpec{Platform: "metal", Hostname: "node-1", Zone: "zone-1", Region: "region-1"},
+			SystemInfo: &hardware.SystemInformationSpec{},
+		},
+		"192.168.0.2": {
+			Metadata:   &runtime.PlatformMetadataSpec{Platform: "metal", Hostname: "rack-2-node-2", Zone: "zone-1", Region: "region-1"},
+			SystemInfo: &hardware.SystemInformationSpec{},
+		},
+	})
+
+	node := func(name, ip string) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: ip},
+			},
+			Spec: v1.NodeSpec{ProviderID: "talos://metal/" + ip},
+		}
+	}
+
+	cfg := cloudConfig{
+		Transformations: []transformer.NodeTerm{
+			{
+				Name: "rack",
+				NodeSelector: []nodeselector.NodeSelectorTerm{
+					{
+						MatchExpressions: []nodeselector.NodeSelectorRequirement{
+							{Key: "hostname", Operator: "Regexp", Values: []string{"^rack-.+$"}},
+						},
+					},
+				},
+				PlatformMetadata: map[string]string{
+					"Zone":   `{{ regexFindString "^(rack-[0-9]+)" .Hostname 1 }}`,
+					"Region": "region-2",
+				},
+			},
+		},
+	}
+
+	client, err := newClient(&cfg, talos)
+	require.NoError(t, err)
+
+	client.kclient = fake.NewClientset(node("node-1", "192.168.0.1"), node("node-2", "192.168.0.2"))
+	startNodeInformer(t, client)
+
+	z := newZones(client)
+
+	for _, tt := range []struct {
+		name       string
+		nodeName   string
+		providerID string
+		expected   cloudprovider.Zone
+	}{
+		{
+			name:     "platform metadata by node name",
+			nodeName: "node-1",
+			expected: cloudprovider.Zone{FailureDomain: "zone-1", Region: "region-1"},
+		},
+		{
+			name:       "platform metadata by provider ID",
+			providerID: "talos://metal/192.168.0.1",
+			expected:   cloudprovider.Zone{FailureDomain: "zone-1", Region: "region-1"},
+		},
+		{
+			name:     "transformation rule by node name",
+			nodeName: "node-2",
+			expected: cloudprovider.Zone{FailureDomain: "rack-2", Region: "region-2"},
+		},
+		{
+			name:       "transformation rule by provider ID",
+			providerID: "talos://metal/192.168.0.2",
+			expected:   cloudprovider.Zone{FailureDomain: "rack-2", Region: "region-2"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				zone cloudprovider.Zone
+				err  error
+			)
+
+			if tt.providerID != "" {
+				zone, err = z.GetZoneByProviderID(t.Context(), tt.providerID)
+			} else {
+				zone, err = z.GetZoneByNodeName(t.Context(), types.NodeName(tt.nodeName))
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, zone)
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package talosclient
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cosi-project/runtime/api/v1alpha1"
+	"github.com/cosi-project/runtime/pkg/resource"
+	"github.com/cosi-project/runtime/pkg/state"
+	cosiclient "github.com/cosi-project/runtime/pkg/state/protobuf/client"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
+	talos "github.com/siderolabs/talos/pkg/machinery/client"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	watchBackoffInitial = time.Second
+	watchBackoffMax     = 30 * time.Second
+)
+
+// CacheOptions is the options of the Talos resource cache.
+type CacheOptions struct {
+	// MaxStaleness is how long the cached resources are served after the watch stream of the node is broken.
+	MaxStaleness time.Duration
+	// IdleTimeout stops the watch of the node, which resources were not requested for this duration.
+	IdleTimeout time.Duration
+}
+
+// NodeWatcher notifies about the changes of the node resources observed by the watch streams.
+type NodeWatcher interface {
+	// OnNodeChange registers the handler, it is called with the node IP when the cached platform metadata
+	// or addresses of the node change. The handler must not block.
+	OnNodeChange(handler func(nodeIP string))
+}
+
+// CachedClient is the Talos client, which serves the platform metadata, system information, machine status and addresses
+// of the nodes from the in-memory cache. The cache of the node is kept up to date by the COSI watch streams,
+// started on the first request to the node. The requests fall back to the Talos API if the cache is not synced yet
+// or the watch stream is broken longer than MaxStaleness.
+type CachedClient struct {
+	*Client
+
+	opts CacheOptions
+
+	ctx    context.Context //nolint:containedctx
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	nodes    map[string]*nodeCache
+	handlers []func(nodeIP string)
+}
+
+var (
+	_ Interface   = &CachedClient{}
+	_ NodeWatcher = &CachedClient{}
+)
+
+// nodeCache is the cached resources of the node.
+type nodeCache struct {
+	cancel context.CancelFunc
+
+	mu      sync.RWMutex
+	meta    *runtime.PlatformMetadataSpec
+	sysInfo *hardware.SystemInformationSpec
+	status  *runtime.MachineStatusSpec
+	ifaces  []network.AddressStatusSpec
+	// healthy is true while the watch stream is established.
+	healthy bool
+	// brokenAt is the time the watch stream was broken.
+	brokenAt time.Time
+	// accessedAt is the time of the last request to the node.
+	accessedAt time.Time
+}
+
+// NewCachedClient creates the cached Talos client.
+func NewCachedClient(client *Client, opts CacheOptions) *CachedClient {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &CachedClient{
+		Client: client,
+		opts:   opts,
+		ctx:    ctx,
+		cancel: cancel,
+		nodes:  map[string]*nodeCache{},
+	}
+
+	if opts.IdleTimeout > 0 {
+		go c.expireIdle(ctx)
+	}
+
+	return c
+}
+
+// GetNodeMetadata returns the metadata of the node.
+func (c *CachedClient) GetNodeMetadata(ctx context.Context, nodeIP string) (*runtime.PlatformMetadataSpec, error) {
+	n := c.node(nodeIP)
+
+	n.mu.RLock()
+
+	if n.meta != nil && n.fresh(c.opts.MaxStaleness) {
+		meta := n.meta.DeepCopy()
+		n.mu.RUnlock()
+
+		metrics.CacheRequest(runtime.PlatformMetadataID, metrics.CacheResultHit)
+
+		return &meta, nil
+	}
+
+	n.mu.RUnlock()
+
+	metrics.CacheRequest(runtime.PlatformMetadataID, metrics.CacheResultMiss)
+
+	return c.Client.GetNodeMetadata(ctx, nodeIP)
+}
+
+// GetNodeSystemInfo returns the system information of the node.
+func (c *CachedClient) GetNodeSystemInfo(ctx context.Context, nodeIP string) (*hardware.SystemInformationSpec, error) {
+	n := c.node(nodeIP)
+
+	n.mu.RLock()
+
+	if n.sysInfo != nil && n.fresh(c.opts.MaxStaleness) {
+		sysInfo := n.sysInfo.DeepCopy()
+		n.mu.RUnlock()
+
+		metrics.CacheRequest(hardware.SystemInformationID, metrics.CacheResultHit)
+
+		return &sysInfo, nil
+	}
+
+	n.mu.RUnlock()
+
+	metrics.CacheRequest(hardware.SystemInformationID, metrics.CacheResultMiss)
+
+	return c.Client.GetNodeSystemInfo(ctx, nodeIP)
+}
+
+// GetNodeMachineStatus returns the machine status of the node.
+func (c *CachedClient) GetNodeMachineStatus(ctx context.Context, nodeIP string) (*runtime.MachineStatusSpec, error) {
+	n := c.node(nodeIP)
+
+	n.mu.RLock()
+
+	if n.status != nil && n.fresh(c.opts.MaxStaleness) {
+		status := n.status.DeepCopy()
+		n.mu.RUnlock()
+
+		metrics.CacheRequest("machinestatus", metrics.CacheResultHit)
+
+		return &status, nil
+	}
+
+	n.mu.RUnlock()
+
+	metrics.CacheRequest("machinestatus", metrics.CacheResultMiss)
+
+	return c.Client.GetNodeMachineStatus(ctx, nodeIP)
+}
+
+// GetNodeIfaces returns the network interfaces of the node.
+func (c *CachedClient) GetNodeIfaces(ctx context.Context, nodeIP string) ([]network.AddressStatusSpec, error) {
+	n := c.node(nodeIP)
+
+	n.mu.RLock()
+
+	if n.ifaces != nil && n.fresh(c.opts.MaxStaleness) {
+		ifaces := make([]network.AddressStatusSpec, 0, len(n.ifaces))
+		for _, iface := range n.ifaces {
+			ifaces = append(ifaces, iface.DeepCopy())
+		}
+
+		n.mu.RUnlock()
+
+		metrics.CacheRequest("addresses", metrics.CacheResultHit)
+
+		return ifaces, nil
+	}
+
+	n.mu.RUnlock()
+
+	metrics.CacheRequest("addresses", metrics.CacheResultMiss)
+
+	return c.Client.GetNodeIfaces(ctx, nodeIP)
+}
+
+// OnNodeChange registers the handler of the node resource changes.
+func (c *CachedClient) OnNodeChange(handler func(nodeIP string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.handlers = append(c.handlers, handler)
+}
+
+// Close stops the watch streams and closes the connections to the Talos API.
+func (c *CachedClient) Close() error {
+	c.cancel()
+
+	return c.Client.Close()
+}
+
+// node returns the cache of the node, the watch stream is started on the first request.
+func (c *CachedClient) node(nodeIP string) *nodeCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, ok := c.nodes[nodeIP]
+	if !ok {
+		ctx, cancel := context.WithCancel(c.ctx)
+
+		n = &nodeCache{cancel: cancel}
+		c.nodes[nodeIP] = n
+
+		go c.watch(ctx, nodeIP, n)
+
+		metrics.CacheWatches(len(c.nodes))
+	}
+
+	n.mu.Lock()
+	n.accessedAt = time.Now()
+	n.mu.Unlock()
+
+	return n
+}
+
+// watch keeps the watch stream of the node, the stream is restarted with backoff on errors.
+func (c *CachedClient) watch(ctx context.Context, nodeIP string, n *nodeCache) {
+	backoff := watchBackoffInitial
+
+	for {
+		start := time.Now()
+
+		err := c.watchNode(ctx, nodeIP, n)
+
+		n.mu.Lock()
+		if n.healthy {
+			n.healthy = false
+			n.brokenAt = time.Now()
+		}
+		n.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(start) > watchBackoffMax {
+			backoff = watchBackoffInitial
+		}
+
+		klog.V(4).InfoS("talos watch stream of the node is broken", "node", nodeIP, "retry", backoff, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, watchBackoffMax)
+	}
+}
+
+//nolint:gocyclo,cyclop
+func (c *CachedClient) watchNode(ctx context.Context, nodeIP string, n *nodeCache) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The watch retries of the COSI client are disabled, a broken stream has to be noticed to bound the staleness.
+	nodeCtx := talos.WithNode(metadata.AppendToOutgoingContext(ctx, "runtime", "Talos"), nodeIP)
+	cosi := state.WrapCore(cosiclient.NewAdapter(v1alpha1.NewStateClient(c.client().Conn()), cosiclient.WithDisableWatchRetry()))
+	events := make(chan state.Event)
+
+	if err := cosi.Watch(nodeCtx, resource.NewMetadata(runtime.NamespaceName, runtime.PlatformMetadataType, runtime.PlatformMetadataID, resource.VersionUndefined), events); err != nil {
+		return err
+	}
+
+	if err := cosi.Watch(nodeCtx, resource.NewMetadata(hardware.NamespaceName, hardware.SystemInformationType, hardware.SystemInformationID, resource.VersionUndefined), events); err != nil {
+		return err
+	}
+
+	if err := cosi.Watch(nodeCtx, resource.NewMetadata(runtime.NamespaceName, runtime.MachineStatusType, runtime.MachineStatusID, resource.VersionUndefined), events); err != nil {
+		return err
+	}
+
+	if err := cosi.WatchKind(nodeCtx, resource.NewMetadata(network.NamespaceName, network.AddressStatusType, "", resource.VersionUndefined), events, state.WithBootstrapContents(true)); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	n.healthy = true
+	n.mu.Unlock()
+
+	ifaces := map[resource.ID]network.AddressStatusSpec{}
+	bootstrapped := false
+
+	for {
+		var event state.Event
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event = <-events:
+		}
+
+		switch event.Type {
+		case state.Errored:
+			return event.Error
+		case state.Bootstrapped:
+			bootstrapped = true
+		case state.Created, state.Updated:
+			switch res := event.Resource.(type) {
+			case *runtime.PlatformMetadata:
+				meta := res.TypedSpec().DeepCopy()
+
+				n.mu.Lock()
+				changed := n.meta != nil && !reflect.DeepEqual(*n.meta, meta)
+				n.meta = &meta
+				n.mu.Unlock()
+
+				if changed {
+					c.notify(nodeIP)
+				}
+			case *hardware.SystemInformation:
+				sysInfo := res.TypedSpec().DeepCopy()
+
+				n.mu.Lock()
+				n.sysInfo = &sysInfo
+				n.mu.Unlock()
+			case *runtime.MachineStatus:
+				status := res.TypedSpec().DeepCopy()
+
+				n.mu.Lock()
+				n.status = &status
+				n.mu.Unlock()
+			case *network.AddressStatus:
+				ifaces[res.Metadata().ID()] = res.TypedSpec().DeepCopy()
+			}
+		case state.Destroyed:
+			switch event.Resource.Metadata().Type() {
+			case runtime.PlatformMetadataType:
+				n.mu.Lock()
+				n.meta = nil
+				n.mu.Unlock()
+			case hardware.SystemInformationType:
+				n.mu.Lock()
+				n.sysInfo = nil
+				n.mu.Unlock()
+			case runtime.MachineStatusType:
+				n.mu.Lock()
+				n.status = nil
+				n.mu.Unlock()
+			case network.AddressStatusType:
+				delete(ifaces, event.Resource.Metadata().ID())
+			}
+		case state.Noop:
+		}
+
+		if bootstrapped && (event.Type == state.Bootstrapped || event.Resource != nil && event.Resource.Metadata().Type() == network.AddressStatusType) {
+			// Keep the order of the List request.
+			ids := slices.Sorted(maps.Keys(ifaces))
+			list := make([]network.AddressStatusSpec, 0, len(ids))
+
+			for _, id := range ids {
+				list = append(list, ifaces[id])
+			}
+
+			n.mu.Lock()
+			changed := n.ifaces != nil && !reflect.DeepEqual(n.ifaces, list)
+			n.ifaces = list
+			n.mu.Unlock()
+
+			if changed {
+				c.notify(nodeIP)
+			}
+		}
+	}
+}
+
+// notify calls the handlers of the node resource changes.
+func (c *CachedClient) notify(nodeIP string) {
+	c.mu.Lock()
+	handlers := slices.Clone(c.handlers)
+	c.mu.Unlock()
+
+	klog.V(4).InfoS("talos resources of the node changed", "node", nodeIP)
+
+	for _, handler := range handlers {
+		handler(nodeIP)
+	}
+}
+
+// expireIdle stops the watch streams of the nodes, which were not requested for IdleTimeout.
+func (c *CachedClient) expireIdle(ctx context.Context) {
+	ticker := time.NewTicker(min(c.opts.IdleTimeout, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+
+		for nodeIP, n := range c.nodes {
+			n.mu.RLock()
+			idle := time.Since(n.accessedAt) > c.opts.IdleTimeout
+			n.mu.RUnlock()
+
+			if idle {
+				klog.V(4).InfoS("stopping talos watch stream of the idle node", "node", nodeIP)
+
+				n.cancel()
+				delete(c.nodes, nodeIP)
+			}
+		}
+
+		metrics.CacheWatches(len(c.nodes))
+
+		c.mu.Unlock()
+	}
+}
+
+// fresh returns true if the cached resources can be served.
+func (n *nodeCache) fresh(maxStaleness time.Duration) bool {
+	return n.healthy || (!n.brokenAt.IsZero() && time.Since(n.brokenAt) < maxStaleness)
+}
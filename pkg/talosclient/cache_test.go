@@ -0,0 +1,132 @@
+package talosclient_test
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/cosi-project/runtime/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+)
+
+// waitCached waits until the platform metadata of the node is served from the cache.
+func waitCached(t *testing.T, cache *talosclient.CachedClient, nodeIP, platform string) {
+	t.Helper()
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.True(c, cache.Cached(nodeIP))
+
+		meta, err := cache.GetNodeMetadata(t.Context(), nodeIP)
+		if assert.NoError(c, err) {
+			assert.Equal(c, platform, meta.Platform)
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCachedClient(t *testing.T) {
+	srv := newFakeServer(t)
+
+	createNode(t, srv, "192.168.0.1", "metal")
+
+	client, err := talosclient.New(t.Context())
+	require.NoError(t, err)
+
+	cache := talosclient.NewCachedClient(client, talosclient.CacheOptions{MaxStaleness: time.Hour, IdleTimeout: time.Hour})
+	t.Cleanup(func() { cache.Close() }) //nolint:errcheck
+
+	changes := make(chan string, 10)
+	cache.OnNodeChange(func(nodeIP string) {
+		select {
+		case changes <- nodeIP:
+		default:
+		}
+	})
+
+	waitCached(t, cache, "192.168.0.1", "metal")
+	assert.Empty(t, changes)
+
+	require.NoError(t, srv.State("192.168.0.1").Modify(t.Context(),
+		runtime.NewPlatformMetadataSpec(runtime.NamespaceName, runtime.PlatformMetadataID),
+		func(r resource.Resource) error {
+			r.(*runtime.PlatformMetadata).TypedSpec().Platform = "nocloud" //nolint:forcetypeassert
+
+			return nil
+		}))
+
+	addr := network.NewAddressStatus(network.NamespaceName, "eth0/1.2.3.4/24")
+	addr.TypedSpec().LinkName = "eth0"
+	addr.TypedSpec().Address = netip.MustParsePrefix("1.2.3.4/24")
+	require.NoError(t, srv.State("192.168.0.1").Create(t.Context(), addr))
+
+	status := runtime.NewMachineStatus()
+	status.TypedSpec().Stage = runtime.MachineStageRunning
+	require.NoError(t, srv.State("192.168.0.1").Create(t.Context(), status))
+
+	waitCached(t, cache, "192.168.0.1", "nocloud")
+
+	select {
+	case nodeIP := <-changes:
+		assert.Equal(t, "192.168.0.1", nodeIP)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the node change was not notified")
+	}
+
+	// The cached resources are served without the Talos API requests.
+	srv.FailRequests(100)
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		ifaces, err := cache.GetNodeIfaces(t.Context(), "192.168.0.1")
+		assert.NoError(c, err)
+		assert.Len(c, ifaces, 2)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	meta, err := cache.GetNodeMetadata(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "nocloud", meta.Platform)
+
+	sysInfo, err := cache.GetNodeSystemInfo(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "uuid-192.168.0.1", sysInfo.UUID)
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		status, err := cache.GetNodeMachineStatus(t.Context(), "192.168.0.1")
+		if assert.NoError(c, err) {
+			assert.Equal(c, runtime.MachineStageRunning, status.Stage)
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The cached resources are served within the staleness bound after the watch stream is broken.
+	srv.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	meta, err = cache.GetNodeMetadata(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "nocloud", meta.Platform)
+}
+
+func TestCachedClientStaleness(t *testing.T) {
+	srv := newFakeServer(t)
+
+	createNode(t, srv, "192.168.0.1", "metal")
+
+	client, err := talosclient.New(t.Context())
+	require.NoError(t, err)
+
+	cache := talosclient.NewCachedClient(client, talosclient.CacheOptions{MaxStaleness: time.Millisecond, IdleTimeout: time.Hour})
+	t.Cleanup(func() { cache.Close() }) //nolint:errcheck
+
+	waitCached(t, cache, "192.168.0.1", "metal")
+
+	srv.Stop()
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		_, err := cache.GetNodeMetadata(t.Context(), "192.168.0.1")
+		assert.Error(c, err)
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -19,53 +19,251 @@ package talosclient
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"net/netip"
 	"os"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cosi-project/runtime/pkg/resource"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
-	"github.com/siderolabs/go-retry/retry"
+	machineapi "github.com/siderolabs/talos/pkg/machinery/api/machine"
 	talos "github.com/siderolabs/talos/pkg/machinery/client"
+	"github.com/siderolabs/talos/pkg/machinery/config/config"
+	"github.com/siderolabs/talos/pkg/machinery/config/container"
+	"github.com/siderolabs/talos/pkg/machinery/config/encoder"
 	"github.com/siderolabs/talos/pkg/machinery/constants"
 	"github.com/siderolabs/talos/pkg/machinery/nethelpers"
+	"github.com/siderolabs/talos/pkg/machinery/resources/block"
+	configres "github.com/siderolabs/talos/pkg/machinery/resources/config"
 	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
 	"github.com/siderolabs/talos/pkg/machinery/resources/k8s"
 	"github.com/siderolabs/talos/pkg/machinery/resources/network"
 	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	"k8s.io/klog/v2"
+)
+
+// Interface is the interface for the Talos client.
+type Interface interface {
+	// GetPodCIDRs returns the pod CIDRs of the cluster.
+	GetPodCIDRs(ctx context.Context) ([]string, error)
+	// GetServiceCIDRs returns the service CIDRs of the cluster.
+	GetServiceCIDRs(ctx context.Context) ([]string, error)
+	// GetNodeIfaces returns the network interfaces of the node.
+	GetNodeIfaces(ctx context.Context, nodeIP string) ([]network.AddressStatusSpec, error)
+	// GetNodeLinks returns the network link statuses of the node by the link name.
+	GetNodeLinks(ctx context.Context, nodeIP string) (map[string]network.LinkStatusSpec, error)
+	// GetNodeMetadata returns the metadata of the node.
+	GetNodeMetadata(ctx context.Context, nodeIP string) (*runtime.PlatformMetadataSpec, error)
+	// GetNodeSystemInfo returns the system information of the node.
+	GetNodeSystemInfo(ctx context.Context, nodeIP string) (*hardware.SystemInformationSpec, error)
+	// GetNodeMachineStatus returns the machine status of the node.
+	GetNodeMachineStatus(ctx context.Context, nodeIP string) (*runtime.MachineStatusSpec, error)
+	// GetNodeProcessors returns the processors of the node.
+	GetNodeProcessors(ctx context.Context, nodeIP string) ([]hardware.ProcessorSpec, error)
+	// GetNodeMemoryModules returns the memory modules of the node.
+	GetNodeMemoryModules(ctx context.Context, nodeIP string) ([]hardware.MemoryModuleSpec, error)
+	// GetNodePCIDevices returns the PCI devices of the node.
+	GetNodePCIDevices(ctx context.Context, nodeIP string) ([]hardware.PCIDeviceSpec, error)
+	// GetNodeDisks returns the block devices of the node.
+	GetNodeDisks(ctx context.Context, nodeIP string) ([]block.DiskSpec, error)
+	// GetNodeVersion returns the Talos version of the node.
+	GetNodeVersion(ctx context.Context, nodeIP string) (*runtime.VersionSpec, error)
+	// GetNodeExtensions returns the installed system extensions of the node.
+	GetNodeExtensions(ctx context.Context, nodeIP string) ([]runtime.ExtensionStatusSpec, error)
+	// GetNodeKernelVersion returns the kernel release of the node.
+	GetNodeKernelVersion(ctx context.Context, nodeIP string) (string, error)
+	// GetNodeMachineType returns the machine type of the node: controlplane or worker.
+	GetNodeMachineType(ctx context.Context, nodeIP string) (string, error)
+	// GetNodeArch returns the CPU architecture of the node, for example amd64.
+	GetNodeArch(ctx context.Context, nodeIP string) (string, error)
+	// GetNodeSecurityState returns the security state of the node.
+	GetNodeSecurityState(ctx context.Context, nodeIP string) (*runtime.SecurityStateSpec, error)
+	// GetNodeRoutes returns the kernel routes of the node.
+	GetNodeRoutes(ctx context.Context, nodeIP string) ([]network.RouteStatusSpec, error)
+	// GetNodeConfigDocuments returns a copy of the active machine config documents of the node.
+	GetNodeConfigDocuments(ctx context.Context, nodeIP string) ([]config.Document, error)
+	// ApplyNodeConfigDocuments applies the machine config documents to the node without reboot.
+	ApplyNodeConfigDocuments(ctx context.Context, nodeIP string, docs []config.Document) error
+	// GetClusterName returns cluster name.
+	GetClusterName() string
+	// GetNodeClusterName returns the name of the cluster the node belongs to.
+	GetNodeClusterName(ctx context.Context, nodeIP string) (string, error)
+	// Close closes the connections to the Talos API.
+	Close() error
+}
+
+// Provider is implemented by the cloud provider which shares its Talos client with the controllers.
+type Provider interface {
+	// TalosClient returns the shared Talos client.
+	TalosClient() Interface
+}
+
+var _ Interface = &Client{}
+
+const (
+	// versionID is the ID of the singleton Talos version resource.
+	versionID = "version"
+	// kernelReleaseParam is the ID of the kernel parameter status with the kernel release.
+	kernelReleaseParam = "proc.sys.kernel.osrelease"
 )
 
-// Client is the interface for the Talos client.
+// Options is the options of the Talos API connection.
+// The zero value uses the default talosconfig and the TALOS_ENDPOINTS environment variable.
+type Options struct {
+	// ClusterName overrides the cluster name of the talosconfig context.
+	ClusterName string
+	// Endpoints overrides the endpoints of the talosconfig context.
+	Endpoints []string
+	// ConfigPath is the path of the talosconfig file.
+	ConfigPath string
+	// Context is the name of the talosconfig context.
+	Context string
+	// CAFile, CertFile and KeyFile are the PEM encoded TLS credentials,
+	// they override the credentials of the talosconfig context.
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// ReloadInterval is the interval of checking the talosconfig and TLS files for changes,
+	// the connection is recreated with the new credentials. Zero disables the reload.
+	ReloadInterval time.Duration
+	// Breaker is the circuit breaker of the unreachable nodes.
+	Breaker BreakerOptions
+	// Request is the default options of the Talos API requests.
+	Request RequestOptions
+	// Operations overrides the request options of the operations, see Operations.
+	Operations map[string]RequestOptions
+}
+
+// Client is the Talos client, it implements Interface.
+// It is safe for concurrent use, the underlying connection is recreated if the Talos API is not reachable.
+// The requests to the nodes, which are known to be unreachable, fail fast with ErrNodeUnreachable.
 type Client struct {
+	opts   Options
+	cancel context.CancelFunc
+	health *healthTracker
+
+	mu    sync.RWMutex
 	talos *talos.Client
 }
 
 // New is the interface for the Talos client.
 func New(ctx context.Context) (*Client, error) {
+	return NewWithOptions(ctx, Options{})
+}
+
+// NewWithOptions creates the Talos client with the connection options.
+func NewWithOptions(ctx context.Context, opts Options) (*Client, error) {
+	talos, err := newTalosClient(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	reloadCtx, cancel := context.WithCancel(context.Background())
+
+	c := &Client{
+		opts:   opts,
+		cancel: cancel,
+		health: newHealthTracker(opts.Breaker),
+		talos:  talos,
+	}
+
+	if files := opts.files(); opts.ReloadInterval > 0 && len(files) > 0 {
+		go c.reload(reloadCtx, files)
+	}
+
+	return c, nil
+}
+
+func newTalosClient(ctx context.Context, opts Options) (*talos.Client, error) {
 	clientOpts := []talos.OptionFunc{}
-	clientOpts = append(clientOpts, talos.WithDefaultConfig())
 
-	endpoints := os.Getenv("TALOS_ENDPOINTS")
-	if endpoints != "" {
-		clientOpts = append(clientOpts, talos.WithEndpoints(strings.Split(endpoints, ",")...))
+	if opts.ConfigPath != "" {
+		clientOpts = append(clientOpts, talos.WithConfigFromFile(opts.ConfigPath))
+	} else {
+		clientOpts = append(clientOpts, talos.WithDefaultConfig())
 	}
 
-	talos, err := talos.New(ctx, clientOpts...)
-	if err != nil {
-		return nil, err
+	if opts.Context != "" {
+		clientOpts = append(clientOpts, talos.WithContextName(opts.Context))
+	}
+
+	endpoints := opts.Endpoints
+	if len(endpoints) == 0 && os.Getenv("TALOS_ENDPOINTS") != "" {
+		endpoints = strings.Split(os.Getenv("TALOS_ENDPOINTS"), ",")
+	}
+
+	if len(endpoints) > 0 {
+		clientOpts = append(clientOpts, talos.WithEndpoints(endpoints...))
+	}
+
+	if opts.CAFile != "" || opts.CertFile != "" {
+		tlsConfig, err := opts.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		clientOpts = append(clientOpts, talos.WithTLSConfig(tlsConfig))
+	}
+
+	return talos.New(ctx, clientOpts...)
+}
+
+func (o Options) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS13}
+
+	if o.CAFile != "" {
+		ca, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read talos CA: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("failed to parse talos CA %s", o.CAFile)
+		}
+	}
+
+	if o.CertFile != "" {
+		crt, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load talos client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{crt}
 	}
 
-	return &Client{
-		talos: talos,
-	}, nil
+	return tlsConfig, nil
+}
+
+// files returns the files of the connection credentials.
+func (o Options) files() []string {
+	files := []string{}
+
+	for _, file := range []string{o.ConfigPath, o.CAFile, o.CertFile, o.KeyFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+
+	return files
 }
 
 // GetPodCIDRs returns the pod CIDRs of the cluster.
 func (c *Client) GetPodCIDRs(ctx context.Context) ([]string, error) {
-	res, err := c.talos.COSI.Get(ctx, resource.NewMetadata(k8s.ControlPlaneNamespaceName, k8s.ControllerManagerConfigType, k8s.ControllerManagerID, resource.VersionUndefined))
+	ctx, cancel := context.WithTimeout(ctx, c.opts.request(OperationClusterCIDRs).Timeout)
+	defer cancel()
+
+	res, err := c.client().COSI.Get(ctx, resource.NewMetadata(k8s.ControlPlaneNamespaceName, k8s.ControllerManagerConfigType, k8s.ControllerManagerID, resource.VersionUndefined))
 	if err != nil {
 		return nil, err
 	}
@@ -75,7 +273,10 @@ func (c *Client) GetPodCIDRs(ctx context.Context) ([]string, error) {
 
 // GetServiceCIDRs returns the service CIDRs of the cluster.
 func (c *Client) GetServiceCIDRs(ctx context.Context) ([]string, error) {
-	res, err := c.talos.COSI.Get(ctx, resource.NewMetadata(k8s.ControlPlaneNamespaceName, k8s.ControllerManagerConfigType, k8s.ControllerManagerID, resource.VersionUndefined))
+	ctx, cancel := context.WithTimeout(ctx, c.opts.request(OperationClusterCIDRs).Timeout)
+	defer cancel()
+
+	res, err := c.client().COSI.Get(ctx, resource.NewMetadata(k8s.ControlPlaneNamespaceName, k8s.ControllerManagerConfigType, k8s.ControllerManagerID, resource.VersionUndefined))
 	if err != nil {
 		return nil, err
 	}
@@ -85,24 +286,14 @@ func (c *Client) GetServiceCIDRs(ctx context.Context) ([]string, error) {
 
 // GetNodeIfaces returns the network interfaces of the node.
 func (c *Client) GetNodeIfaces(ctx context.Context, nodeIP string) ([]network.AddressStatusSpec, error) {
-	nodeCtx := talos.WithNode(ctx, nodeIP)
-
 	var resources resource.List
 
-	err := retry.Constant(10*time.Second, retry.WithUnits(100*time.Millisecond)).Retry(func() error {
+	err := c.nodeRequest(ctx, OperationAddresses, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
 		var listErr error
 
-		resources, listErr = c.talos.COSI.List(nodeCtx, resource.NewMetadata(network.NamespaceName, network.AddressStatusType, "", resource.VersionUndefined))
-		if listErr != nil {
-			err := c.refreshTalosClient(ctx) //nolint:errcheck
-			if err != nil {
-				return retry.ExpectedError(err)
-			}
+		resources, listErr = client.COSI.List(nodeCtx, resource.NewMetadata(network.NamespaceName, network.AddressStatusType, "", resource.VersionUndefined))
 
-			return listErr
-		}
-
-		return nil
+		return listErr
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error get resources: %w", err)
@@ -119,28 +310,44 @@ func (c *Client) GetNodeIfaces(ctx context.Context, nodeIP string) ([]network.Ad
 	return iface, nil
 }
 
+// GetNodeLinks returns the network link statuses of the node by the link name.
+func (c *Client) GetNodeLinks(ctx context.Context, nodeIP string) (map[string]network.LinkStatusSpec, error) {
+	var resources resource.List
+
+	err := c.nodeRequest(ctx, OperationAddresses, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		var listErr error
+
+		resources, listErr = client.COSI.List(nodeCtx, resource.NewMetadata(network.NamespaceName, network.LinkStatusType, "", resource.VersionUndefined))
+
+		return listErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error get resources: %w", err)
+	}
+
+	links := make(map[string]network.LinkStatusSpec, len(resources.Items))
+
+	for _, res := range resources.Items {
+		if link, ok := res.(*network.LinkStatus); ok {
+			links[link.Metadata().ID()] = link.TypedSpec().DeepCopy()
+		}
+	}
+
+	return links, nil
+}
+
 // GetNodeMetadata returns the metadata of the node.
 //
 //nolint:dupl
 func (c *Client) GetNodeMetadata(ctx context.Context, nodeIP string) (*runtime.PlatformMetadataSpec, error) {
-	nodeCtx := talos.WithNode(ctx, nodeIP)
-
 	var resources resource.Resource
 
-	err := retry.Constant(10*time.Second, retry.WithUnits(100*time.Millisecond)).Retry(func() error {
+	err := c.nodeRequest(ctx, OperationPlatformMetadata, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
 		var getErr error
 
-		resources, getErr = c.talos.COSI.Get(nodeCtx, resource.NewMetadata(runtime.NamespaceName, runtime.PlatformMetadataType, runtime.PlatformMetadataID, resource.VersionUndefined))
-		if getErr != nil {
-			err := c.refreshTalosClient(ctx) //nolint:errcheck
-			if err != nil {
-				return retry.ExpectedError(err)
-			}
+		resources, getErr = client.COSI.Get(nodeCtx, resource.NewMetadata(runtime.NamespaceName, runtime.PlatformMetadataType, runtime.PlatformMetadataID, resource.VersionUndefined))
 
-			return getErr
-		}
-
-		return nil
+		return getErr
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error get resources: %w", err)
@@ -155,51 +362,473 @@ func (c *Client) GetNodeMetadata(ctx context.Context, nodeIP string) (*runtime.P
 //
 //nolint:dupl
 func (c *Client) GetNodeSystemInfo(ctx context.Context, nodeIP string) (*hardware.SystemInformationSpec, error) {
-	nodeCtx := talos.WithNode(ctx, nodeIP)
+	var resources resource.Resource
+
+	err := c.nodeRequest(ctx, OperationSystemInfo, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		var getErr error
+
+		resources, getErr = client.COSI.Get(nodeCtx, resource.NewMetadata(hardware.NamespaceName, hardware.SystemInformationType, hardware.SystemInformationID, resource.VersionUndefined))
+
+		return getErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error get resources: %w", err)
+	}
+
+	meta := resources.Spec().(*hardware.SystemInformationSpec).DeepCopy() //nolint:errcheck
 
+	return &meta, nil
+}
+
+// GetNodeMachineStatus returns the machine status of the node.
+//
+//nolint:dupl
+func (c *Client) GetNodeMachineStatus(ctx context.Context, nodeIP string) (*runtime.MachineStatusSpec, error) {
 	var resources resource.Resource
 
-	err := retry.Constant(10*time.Second, retry.WithUnits(100*time.Millisecond)).Retry(func() error {
+	err := c.nodeRequest(ctx, OperationMachineStatus, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
 		var getErr error
 
-		resources, getErr = c.talos.COSI.Get(nodeCtx, resource.NewMetadata(hardware.NamespaceName, hardware.SystemInformationType, hardware.SystemInformationID, resource.VersionUndefined))
-		if getErr != nil {
-			err := c.refreshTalosClient(ctx) //nolint:errcheck
-			if err != nil {
-				return retry.ExpectedError(err)
-			}
+		resources, getErr = client.COSI.Get(nodeCtx, resource.NewMetadata(runtime.NamespaceName, runtime.MachineStatusType, runtime.MachineStatusID, resource.VersionUndefined))
+
+		return getErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error get resources: %w", err)
+	}
+
+	status := resources.Spec().(*runtime.MachineStatusSpec).DeepCopy() //nolint:errcheck
+
+	return &status, nil
+}
+
+// GetNodeProcessors returns the processors of the node.
+func (c *Client) GetNodeProcessors(ctx context.Context, nodeIP string) ([]hardware.ProcessorSpec, error) {
+	return listNodeResources[hardware.ProcessorSpec](ctx, c, OperationHardware, nodeIP, hardware.NamespaceName, hardware.ProcessorType)
+}
+
+// GetNodeMemoryModules returns the memory modules of the node.
+func (c *Client) GetNodeMemoryModules(ctx context.Context, nodeIP string) ([]hardware.MemoryModuleSpec, error) {
+	return listNodeResources[hardware.MemoryModuleSpec](ctx, c, OperationHardware, nodeIP, hardware.NamespaceName, hardware.MemoryModuleType)
+}
+
+// GetNodePCIDevices returns the PCI devices of the node.
+func (c *Client) GetNodePCIDevices(ctx context.Context, nodeIP string) ([]hardware.PCIDeviceSpec, error) {
+	return listNodeResources[hardware.PCIDeviceSpec](ctx, c, OperationHardware, nodeIP, hardware.NamespaceName, hardware.PCIDeviceType)
+}
+
+// GetNodeDisks returns the block devices of the node.
+func (c *Client) GetNodeDisks(ctx context.Context, nodeIP string) ([]block.DiskSpec, error) {
+	return listNodeResources[block.DiskSpec](ctx, c, OperationHardware, nodeIP, block.NamespaceName, block.DiskType)
+}
+
+// GetNodeVersion returns the Talos version of the node.
+//
+//nolint:dupl
+func (c *Client) GetNodeVersion(ctx context.Context, nodeIP string) (*runtime.VersionSpec, error) {
+	var resources resource.Resource
+
+	err := c.nodeRequest(ctx, OperationVersion, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		var getErr error
+
+		resources, getErr = client.COSI.Get(nodeCtx, resource.NewMetadata(runtime.NamespaceName, runtime.VersionType, versionID, resource.VersionUndefined))
+
+		return getErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error get resources: %w", err)
+	}
+
+	version := resources.Spec().(*runtime.VersionSpec).DeepCopy() //nolint:errcheck
+
+	return &version, nil
+}
+
+// GetNodeExtensions returns the installed system extensions of the node.
+func (c *Client) GetNodeExtensions(ctx context.Context, nodeIP string) ([]runtime.ExtensionStatusSpec, error) {
+	return listNodeResources[runtime.ExtensionStatusSpec](ctx, c, OperationVersion, nodeIP, runtime.NamespaceName, runtime.ExtensionStatusType)
+}
+
+// GetNodeKernelVersion returns the kernel release of the node.
+func (c *Client) GetNodeKernelVersion(ctx context.Context, nodeIP string) (string, error) {
+	var resources resource.Resource
+
+	err := c.nodeRequest(ctx, OperationVersion, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		var getErr error
+
+		resources, getErr = client.COSI.Get(nodeCtx, resource.NewMetadata(runtime.NamespaceName, runtime.KernelParamStatusType, kernelReleaseParam, resource.VersionUndefined))
+
+		return getErr
+	})
+	if err != nil {
+		return "", fmt.Errorf("error get resources: %w", err)
+	}
+
+	return strings.TrimSpace(resources.Spec().(*runtime.KernelParamStatusSpec).Current), nil //nolint:errcheck
+}
+
+// GetNodeMachineType returns the machine type of the node: controlplane or worker.
+func (c *Client) GetNodeMachineType(ctx context.Context, nodeIP string) (string, error) {
+	var resources resource.Resource
+
+	err := c.nodeRequest(ctx, OperationVersion, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		var getErr error
+
+		resources, getErr = client.COSI.Get(nodeCtx, resource.NewMetadata(configres.NamespaceName, configres.MachineTypeType, configres.MachineTypeID, resource.VersionUndefined))
+
+		return getErr
+	})
+	if err != nil {
+		return "", fmt.Errorf("error get resources: %w", err)
+	}
+
+	machineType, ok := resources.(*configres.MachineType)
+	if !ok {
+		return "", fmt.Errorf("unexpected resource type %T", resources)
+	}
+
+	return machineType.MachineType().String(), nil
+}
 
-			return getErr
+// GetNodeArch returns the CPU architecture of the node, for example amd64.
+func (c *Client) GetNodeArch(ctx context.Context, nodeIP string) (string, error) {
+	var resp *machineapi.VersionResponse
+
+	err := c.nodeRequest(ctx, OperationVersion, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		var versionErr error
+
+		resp, versionErr = client.Version(nodeCtx)
+
+		return versionErr
+	})
+	if err != nil {
+		return "", fmt.Errorf("error get version: %w", err)
+	}
+
+	for _, msg := range resp.GetMessages() {
+		if arch := msg.GetVersion().GetArch(); arch != "" {
+			return arch, nil
 		}
+	}
+
+	return "", fmt.Errorf("node %s did not report the architecture", nodeIP)
+}
 
-		return nil
+// GetNodeSecurityState returns the security state of the node.
+//
+//nolint:dupl
+func (c *Client) GetNodeSecurityState(ctx context.Context, nodeIP string) (*runtime.SecurityStateSpec, error) {
+	var resources resource.Resource
+
+	err := c.nodeRequest(ctx, OperationVersion, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		var getErr error
+
+		resources, getErr = client.COSI.Get(nodeCtx, resource.NewMetadata(runtime.NamespaceName, runtime.SecurityStateType, runtime.SecurityStateID, resource.VersionUndefined))
+
+		return getErr
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error get resources: %w", err)
 	}
 
-	meta := resources.Spec().(*hardware.SystemInformationSpec).DeepCopy() //nolint:errcheck
+	state := resources.Spec().(*runtime.SecurityStateSpec).DeepCopy() //nolint:errcheck
 
-	return &meta, nil
+	return &state, nil
+}
+
+// listNodeResources returns the specs of the resources of the node.
+func listNodeResources[T any](ctx context.Context, c *Client, op, nodeIP string, ns resource.Namespace, typ resource.Type) ([]T, error) {
+	var resources resource.List
+
+	err := c.nodeRequest(ctx, op, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		var listErr error
+
+		resources, listErr = client.COSI.List(nodeCtx, resource.NewMetadata(ns, typ, "", resource.VersionUndefined))
+
+		return listErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error get resources: %w", err)
+	}
+
+	specs := make([]T, 0, len(resources.Items))
+
+	for _, res := range resources.Items {
+		if spec, ok := res.Spec().(*T); ok {
+			specs = append(specs, *spec)
+		}
+	}
+
+	return specs, nil
+}
+
+// GetNodeRoutes returns the kernel routes of the node.
+func (c *Client) GetNodeRoutes(ctx context.Context, nodeIP string) ([]network.RouteStatusSpec, error) {
+	var resources resource.List
+
+	err := c.nodeRequest(ctx, OperationRoutes, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		var listErr error
+
+		resources, listErr = client.COSI.List(nodeCtx, resource.NewMetadata(network.NamespaceName, network.RouteStatusType, "", resource.VersionUndefined))
+
+		return listErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error get resources: %w", err)
+	}
+
+	routes := []network.RouteStatusSpec{}
+
+	for _, res := range resources.Items {
+		if routeStatus, ok := res.(*network.RouteStatus); ok {
+			routes = append(routes, routeStatus.TypedSpec().DeepCopy())
+		}
+	}
+
+	return routes, nil
+}
+
+// GetNodeConfigDocuments returns a copy of the active machine config documents of the node.
+func (c *Client) GetNodeConfigDocuments(ctx context.Context, nodeIP string) ([]config.Document, error) {
+	var resources resource.Resource
+
+	err := c.nodeRequest(ctx, OperationMachineConfig, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		var getErr error
+
+		resources, getErr = client.COSI.Get(nodeCtx, resource.NewMetadata(configres.NamespaceName, configres.MachineConfigType, configres.ActiveID, resource.VersionUndefined))
+
+		return getErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error get resources: %w", err)
+	}
+
+	machineConfig, ok := resources.(*configres.MachineConfig)
+	if !ok {
+		return nil, fmt.Errorf("unexpected resource type %T", resources)
+	}
+
+	docs := machineConfig.Container().Documents()
+	res := make([]config.Document, 0, len(docs))
+
+	for _, doc := range docs {
+		res = append(res, doc.Clone())
+	}
+
+	return res, nil
+}
+
+// ApplyNodeConfigDocuments applies the machine config documents to the node without reboot.
+func (c *Client) ApplyNodeConfigDocuments(ctx context.Context, nodeIP string, docs []config.Document) error {
+	cfg, err := container.New(docs...)
+	if err != nil {
+		return fmt.Errorf("error creating machine config: %w", err)
+	}
+
+	data, err := cfg.EncodeBytes(encoder.WithComments(encoder.CommentsDisabled))
+	if err != nil {
+		return fmt.Errorf("error encoding machine config: %w", err)
+	}
+
+	err = c.nodeRequest(ctx, OperationApplyConfig, nodeIP, func(nodeCtx context.Context, client *talos.Client) error {
+		_, applyErr := client.ApplyConfiguration(nodeCtx, &machineapi.ApplyConfigurationRequest{
+			Data: data,
+			Mode: machineapi.ApplyConfigurationRequest_NO_REBOOT,
+		})
+
+		return applyErr
+	})
+	if err != nil {
+		return fmt.Errorf("error applying machine config: %w", err)
+	}
+
+	return nil
 }
 
 // GetClusterName returns cluster name.
 func (c *Client) GetClusterName() string {
-	return c.talos.GetClusterName()
+	if c.opts.ClusterName != "" {
+		return c.opts.ClusterName
+	}
+
+	return c.client().GetClusterName()
+}
+
+// GetNodeClusterName returns the name of the cluster the node belongs to, all nodes belong to the same cluster.
+func (c *Client) GetNodeClusterName(_ context.Context, _ string) (string, error) {
+	return c.GetClusterName(), nil
 }
 
-func (c *Client) refreshTalosClient(ctx context.Context) error {
-	if _, err := c.talos.Version(ctx); err != nil {
-		talos, err := New(ctx)
+// Close closes the connections to the Talos API.
+func (c *Client) Close() error {
+	c.cancel()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.talos.Close()
+}
+
+func (c *Client) client() *talos.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.talos
+}
+
+// nodeRequest runs the request to the node, it fails fast if the node is known to be unreachable.
+// Each attempt has the timeout of the operation. The transient errors are retried up to the attempts of the operation,
+// with the recreated client, if the Talos API endpoint itself is not reachable.
+// The node failure is recorded by the circuit breaker once all attempts are failed.
+func (c *Client) nodeRequest(ctx context.Context, op, nodeIP string, request func(nodeCtx context.Context, client *talos.Client) error) error {
+	if err := c.health.allow(nodeIP); err != nil {
+		return err
+	}
+
+	opts := c.opts.request(op)
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		client := c.client()
+
+		err := withTimeout(ctx, opts.Timeout, func(ctx context.Context) error {
+			return request(talos.WithNode(ctx, nodeIP), client)
+		})
+		if ctx.Err() != nil {
+			c.health.release(nodeIP)
+
+			return err
+		}
+
+		if !isUnavailable(err) {
+			// The node responded.
+			c.health.record(nodeIP, start, true)
+
+			return err
+		}
+
+		var refreshed bool
+
+		refreshErr := withTimeout(ctx, opts.Timeout, func(ctx context.Context) (err error) {
+			refreshed, err = c.refreshTalosClient(ctx, client)
+
+			return err
+		})
+		if !refreshed {
+			if attempt >= opts.Attempts {
+				// The endpoint is reachable, but the node is not.
+				c.health.record(nodeIP, start, false)
+
+				return err
+			}
+
+			continue
+		}
+
+		if refreshErr != nil || attempt >= opts.Attempts {
+			// The node reachability is unknown, the endpoint is not reachable.
+			c.health.release(nodeIP)
+
+			return errors.Join(err, refreshErr)
+		}
+	}
+}
+
+// withTimeout runs the request attempt with the timeout.
+func withTimeout(ctx context.Context, timeout time.Duration, f func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return f(ctx)
+}
+
+// refreshTalosClient recreates the failed client if the Talos API endpoint is not reachable,
+// it returns false if the endpoint is reachable and the client was not recreated.
+// The concurrent requests share the client, so it is recreated only once.
+func (c *Client) refreshTalosClient(ctx context.Context, failed *talos.Client) (bool, error) {
+	if _, err := failed.Version(ctx); err == nil {
+		return false, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.talos != failed {
+		return true, nil
+	}
+
+	talos, err := newTalosClient(ctx, c.opts)
+	if err != nil {
+		return true, fmt.Errorf("failed to reinitialized talos client: %v", err)
+	}
+
+	c.talos.Close() //nolint:errcheck
+	c.talos = talos
+
+	return true, nil
+}
+
+// isUnavailable returns true if the error is caused by the unreachable Talos API endpoint or node.
+func isUnavailable(err error) bool {
+	switch status.Code(err) { //nolint:exhaustive
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// reload recreates the client when the credential files change, for example when the mounted secret is rotated.
+func (c *Client) reload(ctx context.Context, files []string) {
+	ticker := time.NewTicker(c.opts.ReloadInterval)
+	defer ticker.Stop()
+
+	checksum := filesChecksum(files)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := filesChecksum(files)
+		if current == checksum {
+			continue
+		}
+
+		talos, err := newTalosClient(ctx, c.opts)
 		if err != nil {
-			return fmt.Errorf("failed to reinitialized talos client: %v", err)
+			klog.ErrorS(err, "failed to reload talos client, the files are changed", "files", files)
+
+			continue
 		}
 
+		checksum = current
+
+		c.mu.Lock()
 		c.talos.Close() //nolint:errcheck
-		c.talos = talos.talos
+		c.talos = talos
+		c.mu.Unlock()
+
+		klog.InfoS("talos client was reloaded, the files are changed", "files", files)
 	}
+}
 
-	return nil
+// filesChecksum returns the checksum of the files content, missing files are skipped.
+func filesChecksum(files []string) string {
+	h := sha256.New()
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		h.Write(data)
+	}
+
+	return string(h.Sum(nil))
 }
 
 // NodeIPDiscovery returns the public IPs of the node excluding the given IPs.
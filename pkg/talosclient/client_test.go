@@ -0,0 +1,472 @@
+package talosclient_test
+
+import (
+	"encoding/base64"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+	"github.com/siderolabs/talos/pkg/machinery/config/machine"
+	"github.com/siderolabs/talos/pkg/machinery/resources/block"
+	configres "github.com/siderolabs/talos/pkg/machinery/resources/config"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/k8s"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+)
+
+func newFakeServer(t *testing.T) *fake.Server {
+	t.Helper()
+
+	srv, err := fake.NewServer("192.168.0.1")
+	require.NoError(t, err)
+
+	t.Cleanup(srv.Stop)
+
+	talosconfig := filepath.Join(t.TempDir(), "talosconfig")
+	require.NoError(t, srv.Talosconfig("test-cluster").Save(talosconfig))
+
+	t.Setenv("TALOSCONFIG", talosconfig)
+	t.Setenv("TALOS_ENDPOINTS", srv.Endpoint())
+
+	return srv
+}
+
+func createNode(t *testing.T, srv *fake.Server, nodeIP, platform string) {
+	t.Helper()
+
+	st := srv.State(nodeIP)
+
+	meta := runtime.NewPlatformMetadataSpec(runtime.NamespaceName, runtime.PlatformMetadataID)
+	meta.TypedSpec().Platform = platform
+	meta.TypedSpec().Hostname = "node-" + nodeIP
+	require.NoError(t, st.Create(t.Context(), meta))
+
+	sysInfo := hardware.NewSystemInformation(hardware.SystemInformationID)
+	sysInfo.TypedSpec().UUID = "uuid-" + nodeIP
+	require.NoError(t, st.Create(t.Context(), sysInfo))
+
+	addr := network.NewAddressStatus(network.NamespaceName, "eth0/"+nodeIP+"/24")
+	addr.TypedSpec().LinkName = "eth0"
+	addr.TypedSpec().Address = netip.MustParsePrefix(nodeIP + "/24")
+	require.NoError(t, st.Create(t.Context(), addr))
+}
+
+func TestClusterCIDRs(t *testing.T) {
+	srv := newFakeServer(t)
+
+	cfg := k8s.NewControllerManagerConfig()
+	cfg.TypedSpec().PodCIDRs = []string{"10.244.0.0/16"}
+	cfg.TypedSpec().ServiceCIDRs = []string{"10.96.0.0/12"}
+	require.NoError(t, srv.State("192.168.0.1").Create(t.Context(), cfg))
+
+	client, err := talosclient.New(t.Context())
+	require.NoError(t, err)
+
+	assert.Equal(t, "test-cluster", client.GetClusterName())
+
+	podCIDRs, err := client.GetPodCIDRs(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.244.0.0/16"}, podCIDRs)
+
+	serviceCIDRs, err := client.GetServiceCIDRs(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.96.0.0/12"}, serviceCIDRs)
+}
+
+func TestGetNodeResources(t *testing.T) {
+	srv := newFakeServer(t)
+
+	createNode(t, srv, "192.168.0.1", "metal")
+	createNode(t, srv, "192.168.0.2", "nocloud")
+
+	client, err := talosclient.New(t.Context())
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		nodeIP           string
+		expectedPlatform string
+		expectedError    string
+	}{
+		{nodeIP: "192.168.0.1", expectedPlatform: "metal"},
+		{nodeIP: "192.168.0.2", expectedPlatform: "nocloud"},
+		{nodeIP: "192.168.0.3", expectedError: "node 192.168.0.3 is not reachable"},
+	} {
+		t.Run(tt.nodeIP, func(t *testing.T) {
+			meta, err := client.GetNodeMetadata(t.Context(), tt.nodeIP)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPlatform, meta.Platform)
+			assert.Equal(t, "node-"+tt.nodeIP, meta.Hostname)
+
+			sysInfo, err := client.GetNodeSystemInfo(t.Context(), tt.nodeIP)
+			assert.NoError(t, err)
+			assert.Equal(t, "uuid-"+tt.nodeIP, sysInfo.UUID)
+
+			ifaces, err := client.GetNodeIfaces(t.Context(), tt.nodeIP)
+			assert.NoError(t, err)
+			assert.Len(t, ifaces, 1)
+			assert.Equal(t, netip.MustParsePrefix(tt.nodeIP+"/24"), ifaces[0].Address)
+		})
+	}
+}
+
+func TestGetNodeLinks(t *testing.T) {
+	srv := newFakeServer(t)
+
+	link := network.NewLinkStatus(network.NamespaceName, "eth0")
+	link.TypedSpec().SpeedMegabits = 10000
+	link.TypedSpec().Driver = "ixgbe"
+	require.NoError(t, srv.State("192.168.0.1").Create(t.Context(), link))
+
+	client, err := talosclient.New(t.Context())
+	require.NoError(t, err)
+
+	t.Cleanup(func() { client.Close() }) //nolint:errcheck
+
+	links, err := client.GetNodeLinks(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]network.LinkStatusSpec{"eth0": {SpeedMegabits: 10000, Driver: "ixgbe"}}, links)
+}
+
+func TestGetNodeHardware(t *testing.T) {
+	srv := newFakeServer(t)
+
+	st := srv.State("192.168.0.1")
+
+	cpu := hardware.NewProcessorInfo("CPU-0")
+	cpu.TypedSpec().ProductName = "AMD EPYC 7502P"
+	cpu.TypedSpec().CoreCount = 32
+	require.NoError(t, st.Create(t.Context(), cpu))
+
+	mem := hardware.NewMemoryModuleInfo("DIMM-0")
+	mem.TypedSpec().Size = 32768
+	require.NoError(t, st.Create(t.Context(), mem))
+
+	gpu := hardware.NewPCIDeviceInfo("0000:01:00.0")
+	gpu.TypedSpec().VendorID = "0x10de"
+	require.NoError(t, st.Create(t.Context(), gpu))
+
+	disk := block.NewDisk(block.NamespaceName, "nvme0n1")
+	disk.TypedSpec().Transport = "nvme"
+	require.NoError(t, st.Create(t.Context(), disk))
+
+	client, err := talosclient.New(t.Context())
+	require.NoError(t, err)
+
+	t.Cleanup(func() { client.Close() }) //nolint:errcheck
+
+	processors, err := client.GetNodeProcessors(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []hardware.ProcessorSpec{{ProductName: "AMD EPYC 7502P", CoreCount: 32}}, processors)
+
+	modules, err := client.GetNodeMemoryModules(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []hardware.MemoryModuleSpec{{Size: 32768}}, modules)
+
+	devices, err := client.GetNodePCIDevices(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []hardware.PCIDeviceSpec{{VendorID: "0x10de"}}, devices)
+
+	disks, err := client.GetNodeDisks(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []block.DiskSpec{{Transport: "nvme"}}, disks)
+}
+
+func TestGetNodeVersion(t *testing.T) {
+	srv := newFakeServer(t)
+
+	st := srv.State("192.168.0.1")
+
+	version := runtime.NewVersion()
+	version.TypedSpec().Version = "v1.13.5"
+	require.NoError(t, st.Create(t.Context(), version))
+
+	ext := runtime.NewExtensionStatus(runtime.NamespaceName, "0")
+	ext.TypedSpec().Metadata.Name = "iscsi-tools"
+	ext.TypedSpec().Metadata.Version = "v0.2.0"
+	require.NoError(t, st.Create(t.Context(), ext))
+
+	kernel := runtime.NewKernelParamStatus(runtime.NamespaceName, "proc.sys.kernel.osrelease")
+	kernel.TypedSpec().Current = "6.18.44-talos\n"
+	require.NoError(t, st.Create(t.Context(), kernel))
+
+	machineType := configres.NewMachineType()
+	machineType.SetMachineType(machine.TypeWorker)
+	require.NoError(t, st.Create(t.Context(), machineType))
+
+	security := runtime.NewSecurityStateSpec(runtime.NamespaceName)
+	security.TypedSpec().SecureBoot = true
+	require.NoError(t, st.Create(t.Context(), security))
+
+	client, err := talosclient.New(t.Context())
+	require.NoError(t, err)
+
+	t.Cleanup(func() { client.Close() }) //nolint:errcheck
+
+	res, err := client.GetNodeVersion(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.13.5", res.Version)
+
+	exts, err := client.GetNodeExtensions(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Len(t, exts, 1)
+	assert.Equal(t, "iscsi-tools", exts[0].Metadata.Name)
+
+	kernelVersion, err := client.GetNodeKernelVersion(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "6.18.44-talos", kernelVersion)
+
+	typ, err := client.GetNodeMachineType(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "worker", typ)
+
+	arch, err := client.GetNodeArch(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "amd64", arch)
+
+	state, err := client.GetNodeSecurityState(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.True(t, state.SecureBoot)
+}
+
+func TestRefreshTalosClient(t *testing.T) {
+	srv := newFakeServer(t)
+
+	createNode(t, srv, "192.168.0.1", "metal")
+
+	client, err := talosclient.New(t.Context())
+	require.NoError(t, err)
+
+	srv.FailRequests(2)
+
+	_, err = client.GetNodeMetadata(t.Context(), "192.168.0.1")
+	assert.ErrorContains(t, err, "injected failure")
+	assert.Equal(t, 2, srv.VersionCalls())
+
+	meta, err := client.GetNodeMetadata(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "metal", meta.Platform)
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	srv := newFakeServer(t)
+
+	createNode(t, srv, "192.168.0.1", "metal")
+
+	client, err := talosclient.New(t.Context())
+	require.NoError(t, err)
+
+	t.Cleanup(func() { client.Close() }) //nolint:errcheck
+
+	srv.FailRequests(1)
+
+	// The endpoint is reachable, the node request is retried with the same client.
+	meta, err := client.GetNodeMetadata(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "metal", meta.Platform)
+	assert.Equal(t, 1, srv.VersionCalls())
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	srv := newFakeServer(t)
+
+	createNode(t, srv, "192.168.0.1", "metal")
+
+	client, err := talosclient.New(t.Context())
+	require.NoError(t, err)
+
+	t.Cleanup(func() { client.Close() }) //nolint:errcheck
+
+	srv.FailRequests(5)
+
+	var wg sync.WaitGroup
+
+	for range 20 {
+		wg.Go(func() {
+			client.GetNodeMetadata(t.Context(), "192.168.0.1") //nolint:errcheck
+		})
+	}
+
+	wg.Wait()
+
+	meta, err := client.GetNodeMetadata(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "metal", meta.Platform)
+}
+
+func TestNodeBreaker(t *testing.T) {
+	srv := newFakeServer(t)
+
+	createNode(t, srv, "192.168.0.1", "metal")
+
+	client, err := talosclient.NewWithOptions(t.Context(), talosclient.Options{
+		Breaker: talosclient.BreakerOptions{FailureThreshold: 2, Backoff: 100 * time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { client.Close() }) //nolint:errcheck
+
+	for range 2 {
+		_, err = client.GetNodeMetadata(t.Context(), "192.168.0.2")
+		assert.ErrorContains(t, err, "node 192.168.0.2 is not reachable")
+	}
+
+	// The endpoint is reachable, the client is not recreated, each request is attempted twice.
+	assert.Equal(t, 4, srv.VersionCalls())
+
+	_, err = client.GetNodeMetadata(t.Context(), "192.168.0.2")
+	assert.ErrorIs(t, err, talosclient.ErrNodeUnreachable)
+	assert.Equal(t, 4, srv.VersionCalls())
+
+	// The other nodes are not affected.
+	meta, err := client.GetNodeMetadata(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "metal", meta.Platform)
+
+	createNode(t, srv, "192.168.0.2", "nocloud")
+
+	// The node is probed after the backoff.
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		meta, err := client.GetNodeMetadata(t.Context(), "192.168.0.2")
+		if assert.NoError(c, err) {
+			assert.Equal(c, "nocloud", meta.Platform)
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	srv := newFakeServer(t)
+
+	createNode(t, srv, "192.168.0.1", "metal")
+
+	client, err := talosclient.NewWithOptions(t.Context(), talosclient.Options{
+		Operations: map[string]talosclient.RequestOptions{
+			talosclient.OperationPlatformMetadata: {Timeout: 50 * time.Millisecond},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { client.Close() }) //nolint:errcheck
+
+	srv.SetLatency(500 * time.Millisecond)
+
+	_, err = client.GetNodeMetadata(t.Context(), "192.168.0.1")
+	assert.Error(t, err)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, metrics.ErrorReasonTimeout, metrics.ErrorReason(err))
+
+	// The other operations use the default timeout.
+	sysInfo, err := client.GetNodeSystemInfo(t.Context(), "192.168.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "uuid-192.168.0.1", sysInfo.UUID)
+}
+
+func TestClientOptions(t *testing.T) {
+	srv, err := fake.NewServer("192.168.0.1")
+	require.NoError(t, err)
+
+	t.Cleanup(srv.Stop)
+
+	createNode(t, srv, "192.168.0.1", "metal")
+
+	dir := t.TempDir()
+	t.Setenv("TALOSCONFIG", filepath.Join(dir, "default"))
+	t.Setenv("TALOS_ENDPOINTS", "")
+
+	talosconfig := filepath.Join(dir, "talosconfig")
+	cfg := srv.Talosconfig("test-cluster")
+	require.NoError(t, cfg.Save(talosconfig))
+
+	tlsFile := func(name, data string) string {
+		pem, err := base64.StdEncoding.DecodeString(data)
+		require.NoError(t, err)
+
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, pem, 0o600))
+
+		return path
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts talosclient.Options
+	}{
+		{
+			name: "talosconfig",
+			opts: talosclient.Options{ConfigPath: talosconfig, Context: "test-cluster"},
+		},
+		{
+			name: "tls files",
+			opts: talosclient.Options{
+				Endpoints: []string{srv.Endpoint()},
+				CAFile:    tlsFile("ca.crt", cfg.Contexts["test-cluster"].CA),
+				CertFile:  tlsFile("tls.crt", cfg.Contexts["test-cluster"].Crt),
+				KeyFile:   tlsFile("tls.key", cfg.Contexts["test-cluster"].Key),
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := talosclient.NewWithOptions(t.Context(), tt.opts)
+			require.NoError(t, err)
+
+			t.Cleanup(func() { client.Close() }) //nolint:errcheck
+
+			meta, err := client.GetNodeMetadata(t.Context(), "192.168.0.1")
+			assert.NoError(t, err)
+			assert.Equal(t, "metal", meta.Platform)
+		})
+	}
+}
+
+func TestClientReload(t *testing.T) {
+	srv := newFakeServer(t)
+
+	createNode(t, srv, "192.168.0.1", "metal")
+
+	talosconfig := filepath.Join(t.TempDir(), "talosconfig")
+	require.NoError(t, srv.Talosconfig("test-cluster").Save(talosconfig))
+
+	t.Setenv("TALOS_ENDPOINTS", "")
+
+	client, err := talosclient.NewWithOptions(t.Context(), talosclient.Options{ConfigPath: talosconfig, ReloadInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { client.Close() }) //nolint:errcheck
+
+	meta, err := client.GetNodeMetadata(t.Context(), "192.168.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "metal", meta.Platform)
+
+	// The credentials are rotated to the new Talos API server.
+	rotated, err := fake.NewServer("192.168.0.1")
+	require.NoError(t, err)
+
+	t.Cleanup(rotated.Stop)
+
+	createNode(t, rotated, "192.168.0.1", "nocloud")
+	require.NoError(t, rotated.Talosconfig("test-cluster").Save(talosconfig))
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		meta, err := client.GetNodeMetadata(t.Context(), "192.168.0.1")
+		if assert.NoError(c, err) {
+			assert.Equal(c, "nocloud", meta.Platform)
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,17 @@
+package talosclient
+
+// Cached returns true if the platform metadata of the node is served from the cache.
+func (c *CachedClient) Cached(nodeIP string) bool {
+	c.mu.Lock()
+	n, ok := c.nodes[nodeIP]
+	c.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.meta != nil && n.fresh(c.opts.MaxStaleness)
+}
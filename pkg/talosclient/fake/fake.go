@@ -0,0 +1,422 @@
+// Package fake implements an in-memory Talos client for testing.
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient"
+	"github.com/siderolabs/talos/pkg/machinery/config/config"
+	"github.com/siderolabs/talos/pkg/machinery/resources/block"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+)
+
+var (
+	// ErrNodeNotFound occurs when the node IP is not known to the fake client.
+	// It has the gRPC Unavailable code, as the error of the unreachable node.
+	ErrNodeNotFound = status.Error(codes.Unavailable, "node not found")
+	// ErrResourceNotFound occurs when the node does not have the requested resource.
+	ErrResourceNotFound = errors.New("resource not found")
+)
+
+// Node is the state of the Talos node, a nil resource is reported as not found.
+type Node struct {
+	// ClusterName overrides the cluster name of the client for the node.
+	ClusterName     string
+	Metadata        *runtime.PlatformMetadataSpec
+	SystemInfo      *hardware.SystemInformationSpec
+	MachineStatus   *runtime.MachineStatusSpec
+	Processors      []hardware.ProcessorSpec
+	MemoryModules   []hardware.MemoryModuleSpec
+	PCIDevices      []hardware.PCIDeviceSpec
+	Disks           []block.DiskSpec
+	Version         *runtime.VersionSpec
+	Extensions      []runtime.ExtensionStatusSpec
+	KernelVersion   string
+	MachineType     string
+	Arch            string
+	SecurityState   *runtime.SecurityStateSpec
+	Ifaces          []network.AddressStatusSpec
+	Links           map[string]network.LinkStatusSpec
+	Routes          []network.RouteStatusSpec
+	ConfigDocuments []config.Document
+}
+
+// Client is an in-memory implementation of talosclient.Interface.
+type Client struct {
+	mu sync.Mutex
+
+	clusterName  string
+	podCIDRs     []string
+	serviceCIDRs []string
+	nodes        map[string]*Node
+}
+
+var _ talosclient.Interface = &Client{}
+
+// NewClient creates a new fake client of the cluster with the nodes keyed by the node IP.
+func NewClient(clusterName string, podCIDRs, serviceCIDRs []string, nodes map[string]*Node) *Client {
+	if nodes == nil {
+		nodes = map[string]*Node{}
+	}
+
+	return &Client{
+		clusterName:  clusterName,
+		podCIDRs:     podCIDRs,
+		serviceCIDRs: serviceCIDRs,
+		nodes:        nodes,
+	}
+}
+
+// SetNode adds or replaces the node state.
+func (c *Client) SetNode(nodeIP string, node *Node) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nodes[nodeIP] = node
+}
+
+// DeleteNode removes the node, all subsequent requests to the node fail.
+func (c *Client) DeleteNode(nodeIP string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.nodes, nodeIP)
+}
+
+// GetPodCIDRs returns the pod CIDRs of the cluster.
+func (c *Client) GetPodCIDRs(_ context.Context) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.podCIDRs...), nil
+}
+
+// GetServiceCIDRs returns the service CIDRs of the cluster.
+func (c *Client) GetServiceCIDRs(_ context.Context) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.serviceCIDRs...), nil
+}
+
+// GetNodeIfaces returns the network interfaces of the node.
+func (c *Client) GetNodeIfaces(_ context.Context, nodeIP string) ([]network.AddressStatusSpec, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	ifaces := make([]network.AddressStatusSpec, 0, len(node.Ifaces))
+	for _, iface := range node.Ifaces {
+		ifaces = append(ifaces, iface.DeepCopy())
+	}
+
+	return ifaces, nil
+}
+
+// GetNodeLinks returns the network link statuses of the node by the link name.
+func (c *Client) GetNodeLinks(_ context.Context, nodeIP string) (map[string]network.LinkStatusSpec, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make(map[string]network.LinkStatusSpec, len(node.Links))
+	for name, link := range node.Links {
+		links[name] = link.DeepCopy()
+	}
+
+	return links, nil
+}
+
+// GetNodeMetadata returns the metadata of the node.
+func (c *Client) GetNodeMetadata(_ context.Context, nodeIP string) (*runtime.PlatformMetadataSpec, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.Metadata == nil {
+		return nil, fmt.Errorf("node %s platform metadata: %w", nodeIP, ErrResourceNotFound)
+	}
+
+	meta := node.Metadata.DeepCopy()
+
+	return &meta, nil
+}
+
+// GetNodeSystemInfo returns the system information of the node.
+func (c *Client) GetNodeSystemInfo(_ context.Context, nodeIP string) (*hardware.SystemInformationSpec, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.SystemInfo == nil {
+		return nil, fmt.Errorf("node %s system information: %w", nodeIP, ErrResourceNotFound)
+	}
+
+	sysInfo := node.SystemInfo.DeepCopy()
+
+	return &sysInfo, nil
+}
+
+// GetNodeMachineStatus returns the machine status of the node.
+func (c *Client) GetNodeMachineStatus(_ context.Context, nodeIP string) (*runtime.MachineStatusSpec, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.MachineStatus == nil {
+		return nil, fmt.Errorf("node %s machine status: %w", nodeIP, ErrResourceNotFound)
+	}
+
+	status := node.MachineStatus.DeepCopy()
+
+	return &status, nil
+}
+
+// GetNodeProcessors returns the processors of the node.
+func (c *Client) GetNodeProcessors(_ context.Context, nodeIP string) ([]hardware.ProcessorSpec, error) {
+	return nodeResources(c, nodeIP, func(node *Node) []hardware.ProcessorSpec { return node.Processors })
+}
+
+// GetNodeMemoryModules returns the memory modules of the node.
+func (c *Client) GetNodeMemoryModules(_ context.Context, nodeIP string) ([]hardware.MemoryModuleSpec, error) {
+	return nodeResources(c, nodeIP, func(node *Node) []hardware.MemoryModuleSpec { return node.MemoryModules })
+}
+
+// GetNodePCIDevices returns the PCI devices of the node.
+func (c *Client) GetNodePCIDevices(_ context.Context, nodeIP string) ([]hardware.PCIDeviceSpec, error) {
+	return nodeResources(c, nodeIP, func(node *Node) []hardware.PCIDeviceSpec { return node.PCIDevices })
+}
+
+// GetNodeDisks returns the block devices of the node.
+func (c *Client) GetNodeDisks(_ context.Context, nodeIP string) ([]block.DiskSpec, error) {
+	return nodeResources(c, nodeIP, func(node *Node) []block.DiskSpec { return node.Disks })
+}
+
+// GetNodeVersion returns the Talos version of the node.
+func (c *Client) GetNodeVersion(_ context.Context, nodeIP string) (*runtime.VersionSpec, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.Version == nil {
+		return nil, fmt.Errorf("node %s version: %w", nodeIP, ErrResourceNotFound)
+	}
+
+	version := node.Version.DeepCopy()
+
+	return &version, nil
+}
+
+// GetNodeExtensions returns the installed system extensions of the node.
+func (c *Client) GetNodeExtensions(_ context.Context, nodeIP string) ([]runtime.ExtensionStatusSpec, error) {
+	return nodeResources(c, nodeIP, func(node *Node) []runtime.ExtensionStatusSpec { return node.Extensions })
+}
+
+// GetNodeKernelVersion returns the kernel release of the node.
+func (c *Client) GetNodeKernelVersion(_ context.Context, nodeIP string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return "", err
+	}
+
+	if node.KernelVersion == "" {
+		return "", fmt.Errorf("node %s kernel version: %w", nodeIP, ErrResourceNotFound)
+	}
+
+	return node.KernelVersion, nil
+}
+
+// GetNodeMachineType returns the machine type of the node.
+func (c *Client) GetNodeMachineType(_ context.Context, nodeIP string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return "", err
+	}
+
+	if node.MachineType == "" {
+		return "", fmt.Errorf("node %s machine type: %w", nodeIP, ErrResourceNotFound)
+	}
+
+	return node.MachineType, nil
+}
+
+// GetNodeArch returns the CPU architecture of the node.
+func (c *Client) GetNodeArch(_ context.Context, nodeIP string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return "", err
+	}
+
+	if node.Arch == "" {
+		return "", fmt.Errorf("node %s architecture: %w", nodeIP, ErrResourceNotFound)
+	}
+
+	return node.Arch, nil
+}
+
+// GetNodeSecurityState returns the security state of the node.
+func (c *Client) GetNodeSecurityState(_ context.Context, nodeIP string) (*runtime.SecurityStateSpec, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.SecurityState == nil {
+		return nil, fmt.Errorf("node %s security state: %w", nodeIP, ErrResourceNotFound)
+	}
+
+	state := node.SecurityState.DeepCopy()
+
+	return &state, nil
+}
+
+// GetNodeRoutes returns the kernel routes of the node.
+func (c *Client) GetNodeRoutes(_ context.Context, nodeIP string) ([]network.RouteStatusSpec, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]network.RouteStatusSpec, 0, len(node.Routes))
+	for _, route := range node.Routes {
+		routes = append(routes, route.DeepCopy())
+	}
+
+	return routes, nil
+}
+
+// GetNodeConfigDocuments returns a copy of the machine config documents of the node.
+func (c *Client) GetNodeConfigDocuments(_ context.Context, nodeIP string) ([]config.Document, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	return cloneDocuments(node.ConfigDocuments), nil
+}
+
+// ApplyNodeConfigDocuments replaces the machine config documents of the node.
+func (c *Client) ApplyNodeConfigDocuments(_ context.Context, nodeIP string, docs []config.Document) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return err
+	}
+
+	node.ConfigDocuments = cloneDocuments(docs)
+
+	return nil
+}
+
+// GetClusterName returns cluster name.
+func (c *Client) GetClusterName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.clusterName
+}
+
+// GetNodeClusterName returns the name of the cluster the node belongs to.
+func (c *Client) GetNodeClusterName(_ context.Context, nodeIP string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return "", err
+	}
+
+	if node.ClusterName != "" {
+		return node.ClusterName, nil
+	}
+
+	return c.clusterName, nil
+}
+
+// Close does nothing, the fake client has no connections.
+func (c *Client) Close() error {
+	return nil
+}
+
+func (c *Client) node(nodeIP string) (*Node, error) {
+	node, ok := c.nodes[nodeIP]
+	if !ok {
+		return nil, fmt.Errorf("node %s: %w", nodeIP, ErrNodeNotFound)
+	}
+
+	return node, nil
+}
+
+func nodeResources[T any](c *Client, nodeIP string, resources func(*Node) []T) ([]T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, err := c.node(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Clone(resources(node)), nil
+}
+
+func cloneDocuments(docs []config.Document) []config.Document {
+	res := make([]config.Document, 0, len(docs))
+	for _, doc := range docs {
+		res = append(res, doc.Clone())
+	}
+
+	return res
+}
@@ -0,0 +1,301 @@
+package fake
+
+import (
+	"context"
+	"crypto/tls"
+	stdx509 "crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosi-project/runtime/api/v1alpha1"
+	"github.com/cosi-project/runtime/pkg/state"
+	"github.com/cosi-project/runtime/pkg/state/impl/inmem"
+	"github.com/cosi-project/runtime/pkg/state/impl/namespaced"
+	"github.com/cosi-project/runtime/pkg/state/protobuf/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/siderolabs/crypto/x509"
+	machineapi "github.com/siderolabs/talos/pkg/machinery/api/machine"
+	clientconfig "github.com/siderolabs/talos/pkg/machinery/client/config"
+	"github.com/siderolabs/talos/pkg/machinery/role"
+)
+
+// Server is an in-process fake of the Talos machine API.
+// It serves the COSI state of the nodes, the node is selected by the `node` metadata of the request
+// (talos.WithNode), the requests without it are served by the endpoint node.
+type Server struct {
+	listener net.Listener
+	server   *grpc.Server
+
+	ca     *x509.CertificateAuthority
+	client *x509.KeyPair
+
+	mu           sync.Mutex
+	endpointNode string
+	nodes        map[string]state.State
+	failures     int
+	latency      time.Duration
+	versionCalls int
+}
+
+// NewServer starts the fake Talos API server on the loopback interface.
+// The endpointNode is the node IP of the endpoint itself.
+func NewServer(endpointNode string) (*Server, error) {
+	ca, err := x509.NewSelfSignedCertificateAuthority(x509.ECDSA(true))
+	if err != nil {
+		return nil, fmt.Errorf("error creating CA: %w", err)
+	}
+
+	serverCert, err := x509.NewKeyPair(ca,
+		x509.IPAddresses([]net.IP{net.ParseIP("127.0.0.1")}),
+		x509.ExtKeyUsage([]stdx509.ExtKeyUsage{stdx509.ExtKeyUsageServerAuth}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating server certificate: %w", err)
+	}
+
+	clientCert, err := x509.NewKeyPair(ca,
+		x509.CommonName("talos-cloud-controller-manager"),
+		x509.Organization(string(role.Admin)),
+		x509.ExtKeyUsage([]stdx509.ExtKeyUsage{stdx509.ExtKeyUsageClientAuth}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client certificate: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener:     listener,
+		ca:           ca,
+		client:       clientCert,
+		endpointNode: endpointNode,
+		nodes:        map[string]state.State{},
+	}
+
+	s.server = grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(serverTLSConfig(ca, serverCert))),
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	)
+
+	v1alpha1.RegisterStateServer(s.server, &nodeStateServer{s: s})
+	machineapi.RegisterMachineServiceServer(s.server, &machineServer{s: s})
+
+	go s.server.Serve(listener) //nolint:errcheck
+
+	return s, nil
+}
+
+// Endpoint returns the address of the server.
+func (s *Server) Endpoint() string {
+	return s.listener.Addr().String()
+}
+
+// Stop stops the server.
+func (s *Server) Stop() {
+	s.server.Stop()
+}
+
+// Talosconfig returns the talosconfig with the credentials to access the server.
+func (s *Server) Talosconfig(clusterName string) *clientconfig.Config {
+	return &clientconfig.Config{
+		Context: clusterName,
+		Contexts: map[string]*clientconfig.Context{
+			clusterName: {
+				Endpoints: []string{s.Endpoint()},
+				Cluster:   clusterName,
+				CA:        base64.StdEncoding.EncodeToString(s.ca.CrtPEM),
+				Crt:       base64.StdEncoding.EncodeToString(s.client.CrtPEM),
+				Key:       base64.StdEncoding.EncodeToString(s.client.KeyPEM),
+			},
+		},
+	}
+}
+
+// State returns the COSI state of the node, the state is created on the first call.
+func (s *Server) State(nodeIP string) state.State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.nodes[nodeIP]
+	if !ok {
+		st = state.WrapCore(namespaced.NewState(inmem.Build))
+		s.nodes[nodeIP] = st
+	}
+
+	return st
+}
+
+// DeleteNode removes the node, all subsequent requests to the node fail as unreachable.
+func (s *Server) DeleteNode(nodeIP string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.nodes, nodeIP)
+}
+
+// FailRequests makes the next n COSI requests fail as unavailable.
+func (s *Server) FailRequests(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = n
+}
+
+// SetLatency delays the COSI requests, the request fails if the deadline of the request is exceeded.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = latency
+}
+
+// VersionCalls returns the number of the machine Version requests, the client uses it as a health check.
+func (s *Server) VersionCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.versionCalls
+}
+
+func (s *Server) nodeState(ctx context.Context) (*server.State, error) {
+	nodeIP := s.endpointNode
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if nodes := md.Get("node"); len(nodes) > 0 {
+			nodeIP = nodes[0]
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.nodes[nodeIP]
+	if !ok {
+		return nil, status.Errorf(codes.Unavailable, "node %s is not reachable", nodeIP)
+	}
+
+	return server.NewState(st), nil
+}
+
+func (s *Server) injectFailure(ctx context.Context, method string) error {
+	if !strings.HasPrefix(method, "/"+v1alpha1.State_ServiceDesc.ServiceName+"/") {
+		return nil
+	}
+
+	s.mu.Lock()
+	latency := s.latency
+
+	if s.failures > 0 {
+		s.failures--
+		s.mu.Unlock()
+
+		return status.Error(codes.Unavailable, "injected failure")
+	}
+
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-time.After(latency):
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.injectFailure(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (s *Server) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.injectFailure(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+// nodeStateServer routes the COSI requests to the state of the node.
+type nodeStateServer struct {
+	v1alpha1.UnimplementedStateServer
+
+	s *Server
+}
+
+func (n *nodeStateServer) Get(ctx context.Context, req *v1alpha1.GetRequest) (*v1alpha1.GetResponse, error) {
+	st, err := n.s.nodeState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return st.Get(ctx, req)
+}
+
+func (n *nodeStateServer) List(req *v1alpha1.ListRequest, srv v1alpha1.State_ListServer) error {
+	st, err := n.s.nodeState(srv.Context())
+	if err != nil {
+		return err
+	}
+
+	return st.List(req, srv)
+}
+
+func (n *nodeStateServer) Watch(req *v1alpha1.WatchRequest, srv v1alpha1.State_WatchServer) error {
+	st, err := n.s.nodeState(srv.Context())
+	if err != nil {
+		return err
+	}
+
+	return st.Watch(req, srv)
+}
+
+// machineServer implements the machine API used by the client.
+type machineServer struct {
+	machineapi.UnimplementedMachineServiceServer
+
+	s *Server
+}
+
+func (m *machineServer) Version(_ context.Context, _ *emptypb.Empty) (*machineapi.VersionResponse, error) {
+	m.s.mu.Lock()
+	m.s.versionCalls++
+	m.s.mu.Unlock()
+
+	return &machineapi.VersionResponse{
+		Messages: []*machineapi.Version{
+			{Version: &machineapi.VersionInfo{Tag: "v1.13.5", Arch: "amd64"}},
+		},
+	}, nil
+}
+
+func serverTLSConfig(ca *x509.CertificateAuthority, cert *x509.KeyPair) *tls.Config {
+	clientCAs := stdx509.NewCertPool()
+	clientCAs.AddCert(ca.Crt)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{*cert.Certificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS13,
+	}
+}
@@ -0,0 +1,242 @@
+package talosclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/metrics"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultBreakerFailureThreshold = 3
+	defaultBreakerBackoff          = 5 * time.Second
+	defaultBreakerMaxBackoff       = 5 * time.Minute
+
+	// healthIdleTTL is the time the state of the node is kept without the requests to the node,
+	// for example after the node was removed or got a new IP.
+	healthIdleTTL = 30 * time.Minute
+)
+
+// ErrNodeUnreachable is returned without the Talos API request, when the node is known to be unreachable.
+// It has the gRPC Unavailable code.
+var ErrNodeUnreachable = status.Error(codes.Unavailable, "talos node is unreachable")
+
+// BreakerOptions is the options of the circuit breaker of the unreachable nodes.
+// The zero value uses the defaults.
+type BreakerOptions struct {
+	// FailureThreshold is the number of the consecutive failed requests, after which the node is considered unreachable.
+	// Negative value disables the circuit breaker.
+	FailureThreshold int
+	// Backoff is the initial time the requests to the unreachable node fail fast, then one request probes the node.
+	// The backoff doubles after each failed probe.
+	Backoff time.Duration
+	// MaxBackoff is the maximum backoff.
+	MaxBackoff time.Duration
+}
+
+func (o BreakerOptions) failureThreshold() int {
+	if o.FailureThreshold == 0 {
+		return defaultBreakerFailureThreshold
+	}
+
+	return o.FailureThreshold
+}
+
+func (o BreakerOptions) backoff() time.Duration {
+	if o.Backoff == 0 {
+		return defaultBreakerBackoff
+	}
+
+	return o.Backoff
+}
+
+func (o BreakerOptions) maxBackoff() time.Duration {
+	if o.MaxBackoff == 0 {
+		return max(defaultBreakerMaxBackoff, o.backoff())
+	}
+
+	return o.MaxBackoff
+}
+
+// nodeHealth is the reachability of the node.
+type nodeHealth struct {
+	// failures is the number of the consecutive failed requests.
+	failures int
+	// lastSuccess is the time of the last request the node responded to.
+	lastSuccess time.Time
+	// backoff is the current backoff, it is zero while the breaker is closed.
+	backoff time.Duration
+	// openUntil is the time the requests fail fast until.
+	openUntil time.Time
+	// probing is true while the single probe request of the half-open breaker is in flight.
+	probing bool
+	// seen is the time of the last request to the node.
+	seen time.Time
+}
+
+// healthTracker is the circuit breaker of the node requests.
+type healthTracker struct {
+	opts BreakerOptions
+
+	mu    sync.Mutex
+	nodes map[string]*nodeHealth
+	// pruned is the time the idle nodes were last removed.
+	pruned time.Time
+}
+
+func newHealthTracker(opts BreakerOptions) *healthTracker {
+	return &healthTracker{
+		opts:  opts,
+		nodes: map[string]*nodeHealth{},
+	}
+}
+
+// allow returns ErrNodeUnreachable if the request to the node has to fail fast.
+func (h *healthTracker) allow(nodeIP string) error {
+	if h.opts.failureThreshold() < 0 {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, ok := h.nodes[nodeIP]
+	if !ok {
+		return nil
+	}
+
+	n.seen = time.Now()
+
+	if n.backoff == 0 {
+		return nil
+	}
+
+	if wait := time.Until(n.openUntil); wait > 0 || n.probing {
+		metrics.BreakerRejectedRequest()
+
+		return fmt.Errorf("%w: %s, next probe in %s", ErrNodeUnreachable, nodeIP, max(wait, 0).Round(time.Second))
+	}
+
+	n.probing = true
+	h.observe()
+
+	return nil
+}
+
+// record records the result of the request to the node started at the start time.
+// The node is unreachable if reachable is false.
+func (h *healthTracker) record(nodeIP string, start time.Time, reachable bool) {
+	threshold := h.opts.failureThreshold()
+	if threshold < 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.prune(now)
+
+	n, ok := h.nodes[nodeIP]
+	if !ok {
+		n = &nodeHealth{}
+		h.nodes[nodeIP] = n
+	}
+
+	n.seen = now
+
+	if reachable {
+		open := n.backoff > 0
+		*n = nodeHealth{lastSuccess: now, seen: now}
+
+		if open {
+			klog.InfoS("talos node is reachable again", "node", nodeIP)
+
+			h.observe()
+		}
+
+		return
+	}
+
+	// The node responded to a newer request, while this one was in flight.
+	if start.Before(n.lastSuccess) {
+		return
+	}
+
+	n.failures++
+
+	switch {
+	case n.probing:
+		n.probing = false
+		n.backoff = min(n.backoff*2, h.opts.maxBackoff())
+	case n.backoff == 0 && n.failures >= threshold:
+		n.backoff = h.opts.backoff()
+
+		klog.InfoS("talos node is unreachable, the requests fail fast", "node", nodeIP, "failures", n.failures, "backoff", n.backoff)
+	default:
+		return
+	}
+
+	n.openUntil = time.Now().Add(n.backoff)
+	h.observe()
+}
+
+// release releases the probe of the half-open breaker, when the probe request has no result,
+// for example the request was canceled. The next request probes the node again.
+func (h *healthTracker) release(nodeIP string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n, ok := h.nodes[nodeIP]; ok && n.probing {
+		n.probing = false
+		h.observe()
+	}
+}
+
+// prune removes the nodes without the requests for the idle TTL, it must be called under the lock.
+// The breaker of the removed open node is closed, the next request probes the node.
+func (h *healthTracker) prune(now time.Time) {
+	ttl := max(healthIdleTTL, 2*h.opts.maxBackoff())
+	if now.Sub(h.pruned) < ttl/2 {
+		return
+	}
+
+	h.pruned = now
+
+	removed := false
+
+	for nodeIP, n := range h.nodes {
+		if !n.probing && now.Sub(n.seen) > ttl {
+			delete(h.nodes, nodeIP)
+
+			removed = removed || n.backoff > 0
+		}
+	}
+
+	if removed {
+		h.observe()
+	}
+}
+
+// observe records the number of the nodes by the breaker state, it must be called under the lock.
+func (h *healthTracker) observe() {
+	open, halfOpen := 0, 0
+
+	for _, n := range h.nodes {
+		switch {
+		case n.probing:
+			halfOpen++
+		case n.backoff > 0:
+			open++
+		}
+	}
+
+	metrics.BreakerNodes(metrics.BreakerStateOpen, open)
+	metrics.BreakerNodes(metrics.BreakerStateHalfOpen, halfOpen)
+}
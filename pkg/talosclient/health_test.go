@@ -0,0 +1,32 @@
+package talosclient
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthTrackerPrune(t *testing.T) {
+	h := newHealthTracker(BreakerOptions{FailureThreshold: 1})
+
+	h.record("192.168.0.1", time.Now(), true)
+	h.record("192.168.0.2", time.Now(), false)
+	h.record("192.168.0.3", time.Now(), false)
+	assert.Len(t, h.nodes, 3)
+
+	// The first node was removed, the second one has the open breaker and was not requested since.
+	h.nodes["192.168.0.1"].seen = time.Now().Add(-2 * healthIdleTTL)
+	h.nodes["192.168.0.2"].seen = time.Now().Add(-2 * healthIdleTTL)
+
+	h.prune(time.Now())
+	assert.Len(t, h.nodes, 3, "the nodes are pruned at most once per half of the idle TTL")
+
+	h.prune(time.Now().Add(healthIdleTTL / 2))
+	assert.Equal(t, []string{"192.168.0.3"}, slices.Collect(maps.Keys(h.nodes)))
+
+	assert.NoError(t, h.allow("192.168.0.2"))
+	assert.ErrorIs(t, h.allow("192.168.0.3"), ErrNodeUnreachable)
+}
@@ -0,0 +1,313 @@
+package talosclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+
+	"github.com/siderolabs/talos/pkg/machinery/config/config"
+	"github.com/siderolabs/talos/pkg/machinery/resources/block"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	"k8s.io/klog/v2"
+)
+
+// Cluster is the Talos cluster of the multi-cluster client.
+type Cluster struct {
+	// Name is the name of the cluster in the logs.
+	Name string
+	// Client is the Talos client of the cluster.
+	Client Interface
+	// Prefixes are the node IP ranges of the cluster, any node IP if empty.
+	Prefixes []netip.Prefix
+	// Selector matches the platform metadata of the cluster nodes, any node if nil.
+	Selector func(meta *runtime.PlatformMetadataSpec) (bool, error)
+}
+
+// MultiClient is the Talos client of several Talos clusters, it implements Interface.
+// The node requests are routed to the first cluster, which node IP ranges contain the node IP
+// and which selector matches the platform metadata of the node. The default cluster serves
+// the nodes, which do not match any cluster, and the cluster-wide requests.
+type MultiClient struct {
+	defaultCluster Cluster
+	clusters       []Cluster
+
+	mu    sync.RWMutex
+	nodes map[string]*Cluster
+}
+
+var (
+	_ Interface   = &MultiClient{}
+	_ NodeWatcher = &MultiClient{}
+)
+
+// NewMultiClient creates the multi-cluster client with the default cluster.
+func NewMultiClient(defaultCluster Cluster, clusters ...Cluster) (*MultiClient, error) {
+	if defaultCluster.Client == nil {
+		return nil, errors.New("default talos cluster client is nil")
+	}
+
+	for _, cluster := range clusters {
+		if cluster.Client == nil {
+			return nil, fmt.Errorf("talos cluster %s client is nil", cluster.Name)
+		}
+	}
+
+	return &MultiClient{
+		defaultCluster: defaultCluster,
+		clusters:       clusters,
+		nodes:          map[string]*Cluster{},
+	}, nil
+}
+
+// GetPodCIDRs returns the pod CIDRs of the default cluster.
+func (c *MultiClient) GetPodCIDRs(ctx context.Context) ([]string, error) {
+	return c.defaultCluster.Client.GetPodCIDRs(ctx)
+}
+
+// GetServiceCIDRs returns the service CIDRs of the default cluster.
+func (c *MultiClient) GetServiceCIDRs(ctx context.Context) ([]string, error) {
+	return c.defaultCluster.Client.GetServiceCIDRs(ctx)
+}
+
+// GetNodeIfaces returns the network interfaces of the node.
+func (c *MultiClient) GetNodeIfaces(ctx context.Context, nodeIP string) ([]network.AddressStatusSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) ([]network.AddressStatusSpec, error) {
+		return client.GetNodeIfaces(ctx, nodeIP)
+	})
+}
+
+// GetNodeLinks returns the network link statuses of the node by the link name.
+func (c *MultiClient) GetNodeLinks(ctx context.Context, nodeIP string) (map[string]network.LinkStatusSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) (map[string]network.LinkStatusSpec, error) {
+		return client.GetNodeLinks(ctx, nodeIP)
+	})
+}
+
+// GetNodeMetadata returns the metadata of the node.
+func (c *MultiClient) GetNodeMetadata(ctx context.Context, nodeIP string) (*runtime.PlatformMetadataSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) (*runtime.PlatformMetadataSpec, error) {
+		return client.GetNodeMetadata(ctx, nodeIP)
+	})
+}
+
+// GetNodeSystemInfo returns the system information of the node.
+func (c *MultiClient) GetNodeSystemInfo(ctx context.Context, nodeIP string) (*hardware.SystemInformationSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) (*hardware.SystemInformationSpec, error) {
+		return client.GetNodeSystemInfo(ctx, nodeIP)
+	})
+}
+
+// GetNodeMachineStatus returns the machine status of the node.
+func (c *MultiClient) GetNodeMachineStatus(ctx context.Context, nodeIP string) (*runtime.MachineStatusSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) (*runtime.MachineStatusSpec, error) {
+		return client.GetNodeMachineStatus(ctx, nodeIP)
+	})
+}
+
+// GetNodeProcessors returns the processors of the node.
+func (c *MultiClient) GetNodeProcessors(ctx context.Context, nodeIP string) ([]hardware.ProcessorSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) ([]hardware.ProcessorSpec, error) {
+		return client.GetNodeProcessors(ctx, nodeIP)
+	})
+}
+
+// GetNodeMemoryModules returns the memory modules of the node.
+func (c *MultiClient) GetNodeMemoryModules(ctx context.Context, nodeIP string) ([]hardware.MemoryModuleSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) ([]hardware.MemoryModuleSpec, error) {
+		return client.GetNodeMemoryModules(ctx, nodeIP)
+	})
+}
+
+// GetNodePCIDevices returns the PCI devices of the node.
+func (c *MultiClient) GetNodePCIDevices(ctx context.Context, nodeIP string) ([]hardware.PCIDeviceSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) ([]hardware.PCIDeviceSpec, error) {
+		return client.GetNodePCIDevices(ctx, nodeIP)
+	})
+}
+
+// GetNodeDisks returns the block devices of the node.
+func (c *MultiClient) GetNodeDisks(ctx context.Context, nodeIP string) ([]block.DiskSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) ([]block.DiskSpec, error) {
+		return client.GetNodeDisks(ctx, nodeIP)
+	})
+}
+
+// GetNodeVersion returns the Talos version of the node.
+func (c *MultiClient) GetNodeVersion(ctx context.Context, nodeIP string) (*runtime.VersionSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) (*runtime.VersionSpec, error) {
+		return client.GetNodeVersion(ctx, nodeIP)
+	})
+}
+
+// GetNodeExtensions returns the installed system extensions of the node.
+func (c *MultiClient) GetNodeExtensions(ctx context.Context, nodeIP string) ([]runtime.ExtensionStatusSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) ([]runtime.ExtensionStatusSpec, error) {
+		return client.GetNodeExtensions(ctx, nodeIP)
+	})
+}
+
+// GetNodeKernelVersion returns the kernel release of the node.
+func (c *MultiClient) GetNodeKernelVersion(ctx context.Context, nodeIP string) (string, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) (string, error) {
+		return client.GetNodeKernelVersion(ctx, nodeIP)
+	})
+}
+
+// GetNodeMachineType returns the machine type of the node: controlplane or worker.
+func (c *MultiClient) GetNodeMachineType(ctx context.Context, nodeIP string) (string, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) (string, error) {
+		return client.GetNodeMachineType(ctx, nodeIP)
+	})
+}
+
+// GetNodeArch returns the CPU architecture of the node, for example amd64.
+func (c *MultiClient) GetNodeArch(ctx context.Context, nodeIP string) (string, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) (string, error) {
+		return client.GetNodeArch(ctx, nodeIP)
+	})
+}
+
+// GetNodeSecurityState returns the security state of the node.
+func (c *MultiClient) GetNodeSecurityState(ctx context.Context, nodeIP string) (*runtime.SecurityStateSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) (*runtime.SecurityStateSpec, error) {
+		return client.GetNodeSecurityState(ctx, nodeIP)
+	})
+}
+
+// GetNodeRoutes returns the kernel routes of the node.
+func (c *MultiClient) GetNodeRoutes(ctx context.Context, nodeIP string) ([]network.RouteStatusSpec, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) ([]network.RouteStatusSpec, error) {
+		return client.GetNodeRoutes(ctx, nodeIP)
+	})
+}
+
+// GetNodeConfigDocuments returns a copy of the active machine config documents of the node.
+func (c *MultiClient) GetNodeConfigDocuments(ctx context.Context, nodeIP string) ([]config.Document, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) ([]config.Document, error) {
+		return client.GetNodeConfigDocuments(ctx, nodeIP)
+	})
+}
+
+// ApplyNodeConfigDocuments applies the machine config documents to the node without reboot.
+func (c *MultiClient) ApplyNodeConfigDocuments(ctx context.Context, nodeIP string, docs []config.Document) error {
+	_, err := nodeRequest(ctx, c, nodeIP, func(client Interface) (struct{}, error) {
+		return struct{}{}, client.ApplyNodeConfigDocuments(ctx, nodeIP, docs)
+	})
+
+	return err
+}
+
+// GetClusterName returns the name of the default cluster.
+func (c *MultiClient) GetClusterName() string {
+	return c.defaultCluster.Client.GetClusterName()
+}
+
+// GetNodeClusterName returns the name of the cluster the node belongs to.
+func (c *MultiClient) GetNodeClusterName(ctx context.Context, nodeIP string) (string, error) {
+	return nodeRequest(ctx, c, nodeIP, func(client Interface) (string, error) {
+		return client.GetNodeClusterName(ctx, nodeIP)
+	})
+}
+
+// OnNodeChange registers the handler of the node resource changes in all clusters.
+func (c *MultiClient) OnNodeChange(handler func(nodeIP string)) {
+	for _, cluster := range append([]Cluster{c.defaultCluster}, c.clusters...) {
+		if watcher, ok := cluster.Client.(NodeWatcher); ok {
+			watcher.OnNodeChange(handler)
+		}
+	}
+}
+
+// Close closes the connections to the Talos API of all clusters.
+func (c *MultiClient) Close() error {
+	var errs []error
+
+	for _, cluster := range append([]Cluster{c.defaultCluster}, c.clusters...) {
+		if err := cluster.Client.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("talos cluster %s: %w", cluster.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// nodeRequest runs the request in the cluster of the node, the cluster is resolved again after the node was unreachable.
+// The other errors, like the missing resource or the denied request, and the fail fast of the breaker keep the cluster.
+func nodeRequest[T any](ctx context.Context, c *MultiClient, nodeIP string, request func(Interface) (T, error)) (T, error) {
+	cluster := c.cluster(ctx, nodeIP)
+
+	res, err := request(cluster.Client)
+	if err != nil && isUnavailable(err) && !errors.Is(err, ErrNodeUnreachable) {
+		c.mu.Lock()
+		delete(c.nodes, nodeIP)
+		c.mu.Unlock()
+	}
+
+	return res, err
+}
+
+// cluster returns the cluster of the node.
+func (c *MultiClient) cluster(ctx context.Context, nodeIP string) *Cluster {
+	c.mu.RLock()
+	cluster, ok := c.nodes[nodeIP]
+	c.mu.RUnlock()
+
+	if ok {
+		return cluster
+	}
+
+	cluster = c.resolveCluster(ctx, nodeIP)
+
+	c.mu.Lock()
+	c.nodes[nodeIP] = cluster
+	c.mu.Unlock()
+
+	return cluster
+}
+
+func (c *MultiClient) resolveCluster(ctx context.Context, nodeIP string) *Cluster {
+	addr, err := netip.ParseAddr(nodeIP)
+	if err != nil {
+		return &c.defaultCluster
+	}
+
+	for idx := range c.clusters {
+		cluster := &c.clusters[idx]
+
+		if len(cluster.Prefixes) > 0 && !slices.ContainsFunc(cluster.Prefixes, func(prefix netip.Prefix) bool {
+			return prefix.Contains(addr)
+		}) {
+			continue
+		}
+
+		if cluster.Selector == nil {
+			return cluster
+		}
+
+		meta, err := cluster.Client.GetNodeMetadata(ctx, nodeIP)
+		if err != nil {
+			klog.V(4).InfoS("node is not reachable in the talos cluster", "node", nodeIP, "cluster", cluster.Name, "err", err)
+
+			continue
+		}
+
+		match, err := cluster.Selector(meta)
+		if err != nil {
+			klog.ErrorS(err, "failed to match the node in the talos cluster", "node", nodeIP, "cluster", cluster.Name)
+
+			continue
+		}
+
+		if match {
+			return cluster
+		}
+	}
+
+	return &c.defaultCluster
+}
@@ -0,0 +1,170 @@
+package talosclient_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient"
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/talosclient/fake"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+)
+
+func TestMultiClient(t *testing.T) {
+	node := func(platform string) *fake.Node {
+		return &fake.Node{Metadata: &runtime.PlatformMetadataSpec{Platform: platform}}
+	}
+
+	defaultCluster := fake.NewClient("default", []string{"10.0.0.0/16"}, nil, map[string]*fake.Node{
+		"192.168.0.1": node("metal"),
+		"10.5.0.1":    node("metal"),
+	})
+	edge := fake.NewClient("edge", nil, nil, map[string]*fake.Node{
+		"10.5.0.1": node("metal"),
+		"10.5.0.2": node("metal"),
+	})
+	cloud := fake.NewClient("cloud", nil, nil, map[string]*fake.Node{
+		"172.16.0.1": node("hcloud"),
+		"172.16.0.2": node("metal"),
+	})
+
+	client, err := talosclient.NewMultiClient(
+		talosclient.Cluster{Name: "default", Client: defaultCluster},
+		talosclient.Cluster{
+			Name:     "edge",
+			Client:   edge,
+			Prefixes: []netip.Prefix{netip.MustParsePrefix("10.5.0.0/24")},
+		},
+		talosclient.Cluster{
+			Name:   "cloud",
+			Client: cloud,
+			Selector: func(meta *runtime.PlatformMetadataSpec) (bool, error) {
+				return meta.Platform == "hcloud", nil
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "default", client.GetClusterName())
+
+	podCIDRs, err := client.GetPodCIDRs(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/16"}, podCIDRs)
+
+	for _, tt := range []struct {
+		name            string
+		nodeIP          string
+		expectedCluster string
+		expectedErr     bool
+	}{
+		{
+			name:            "node in the cluster IP range",
+			nodeIP:          "10.5.0.2",
+			expectedCluster: "edge",
+		},
+		{
+			name:            "node in the IP range of the first cluster",
+			nodeIP:          "10.5.0.1",
+			expectedCluster: "edge",
+		},
+		{
+			name:            "node matches the cluster selector",
+			nodeIP:          "172.16.0.1",
+			expectedCluster: "cloud",
+		},
+		{
+			name:        "node does not match the cluster selector",
+			nodeIP:      "172.16.0.2",
+			expectedErr: true,
+		},
+		{
+			name:            "node of the default cluster",
+			nodeIP:          "192.168.0.1",
+			expectedCluster: "default",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterName, err := client.GetNodeClusterName(t.Context(), tt.nodeIP)
+			if tt.expectedErr {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCluster, clusterName)
+
+			meta, err := client.GetNodeMetadata(t.Context(), tt.nodeIP)
+			require.NoError(t, err)
+			assert.NotNil(t, meta)
+		})
+	}
+}
+
+func TestMultiClientReroute(t *testing.T) {
+	defaultCluster := fake.NewClient("default", nil, nil, map[string]*fake.Node{})
+	cloud := fake.NewClient("cloud", nil, nil, map[string]*fake.Node{})
+
+	client, err := talosclient.NewMultiClient(
+		talosclient.Cluster{Name: "default", Client: defaultCluster},
+		talosclient.Cluster{
+			Name:   "cloud",
+			Client: cloud,
+			Selector: func(*runtime.PlatformMetadataSpec) (bool, error) {
+				return true, nil
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	// The node is not reachable yet, it is routed to the default cluster.
+	_, err = client.GetNodeMetadata(t.Context(), "172.16.0.1")
+	assert.Error(t, err)
+
+	cloud.SetNode("172.16.0.1", &fake.Node{Metadata: &runtime.PlatformMetadataSpec{Platform: "hcloud"}})
+
+	// The cluster of the node is resolved again after the failed request.
+	clusterName, err := client.GetNodeClusterName(t.Context(), "172.16.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "cloud", clusterName)
+}
+
+func TestMultiClientKeepCluster(t *testing.T) {
+	defaultCluster := fake.NewClient("default", nil, nil, map[string]*fake.Node{})
+	cloud := fake.NewClient("cloud", nil, nil, map[string]*fake.Node{
+		"172.16.0.1": {Metadata: &runtime.PlatformMetadataSpec{Platform: "hcloud"}},
+	})
+
+	client, err := talosclient.NewMultiClient(
+		talosclient.Cluster{Name: "default", Client: defaultCluster},
+		talosclient.Cluster{
+			Name:   "cloud",
+			Client: cloud,
+			Selector: func(*runtime.PlatformMetadataSpec) (bool, error) {
+				return true, nil
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	_, err = client.GetNodeMetadata(t.Context(), "172.16.0.1")
+	require.NoError(t, err)
+
+	// The resource is not found, the cluster of the node is kept.
+	_, err = client.GetNodeSystemInfo(t.Context(), "172.16.0.1")
+	assert.ErrorIs(t, err, fake.ErrResourceNotFound)
+
+	cloud.DeleteNode("172.16.0.1")
+	defaultCluster.SetNode("172.16.0.1", &fake.Node{Metadata: &runtime.PlatformMetadataSpec{Platform: "metal"}})
+
+	// The node is unreachable in the kept cluster.
+	_, err = client.GetNodeMetadata(t.Context(), "172.16.0.1")
+	assert.ErrorIs(t, err, fake.ErrNodeNotFound)
+
+	// The cluster of the node is resolved again after the node was unreachable.
+	meta, err := client.GetNodeMetadata(t.Context(), "172.16.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "metal", meta.Platform)
+}
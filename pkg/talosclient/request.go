@@ -0,0 +1,82 @@
+package talosclient
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// The operations of the Talos client, they are used to configure the request options.
+const (
+	OperationClusterCIDRs     = "clustercidrs"
+	OperationAddresses        = "addresses"
+	OperationPlatformMetadata = "platformmetadata"
+	OperationSystemInfo       = "systeminformation"
+	OperationMachineStatus    = "machinestatus"
+	OperationHardware         = "hardware"
+	OperationVersion          = "version"
+	OperationRoutes           = "routes"
+	OperationMachineConfig    = "machineconfig"
+	OperationApplyConfig      = "applyconfig"
+)
+
+const (
+	defaultRequestTimeout  = 10 * time.Second
+	defaultRequestAttempts = 2
+)
+
+// Operations returns the operations of the Talos client.
+func Operations() []string {
+	return []string{
+		OperationClusterCIDRs,
+		OperationAddresses,
+		OperationPlatformMetadata,
+		OperationSystemInfo,
+		OperationMachineStatus,
+		OperationHardware,
+		OperationVersion,
+		OperationRoutes,
+		OperationMachineConfig,
+		OperationApplyConfig,
+	}
+}
+
+// RequestOptions is the options of the Talos API requests, the zero fields use the defaults.
+type RequestOptions struct {
+	// Timeout is the deadline of one request attempt, it is propagated as the gRPC deadline.
+	Timeout time.Duration
+	// Attempts is the number of the request attempts, when the node or the Talos API endpoint is not reachable.
+	// The client is recreated before the next attempt.
+	Attempts int
+}
+
+// ValidateOperations returns an error if the operation is unknown.
+func ValidateOperations(operations []string) error {
+	for _, op := range operations {
+		if !slices.Contains(Operations(), op) {
+			return fmt.Errorf("unknown talos operation %q, known operations %v", op, Operations())
+		}
+	}
+
+	return nil
+}
+
+// request returns the request options of the operation, the options of the operation override the default options.
+func (o Options) request(op string) RequestOptions {
+	res := RequestOptions{
+		Timeout:  defaultRequestTimeout,
+		Attempts: defaultRequestAttempts,
+	}
+
+	for _, opts := range []RequestOptions{o.Request, o.Operations[op]} {
+		if opts.Timeout > 0 {
+			res.Timeout = opts.Timeout
+		}
+
+		if opts.Attempts > 0 {
+			res.Attempts = opts.Attempts
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,215 @@
+package transformer
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/siderolabs/talos/pkg/machinery/resources/block"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+)
+
+// The disk types of the block devices.
+const (
+	DiskTypeNVMe = "nvme"
+	DiskTypeSSD  = "ssd"
+	DiskTypeHDD  = "hdd"
+)
+
+// NodeHardware provides the hardware resources of the node.
+// The resources are requested only when a transformation rule references them.
+type NodeHardware interface {
+	Processors() ([]hardware.ProcessorSpec, error)
+	MemoryModules() ([]hardware.MemoryModuleSpec, error)
+	PCIDevices() ([]hardware.PCIDeviceSpec, error)
+	Disks() ([]block.DiskSpec, error)
+}
+
+// lazyHardware requests the hardware resources once per transformation.
+type lazyHardware struct {
+	source NodeHardware
+
+	processors    *[]hardware.ProcessorSpec
+	memoryModules *[]hardware.MemoryModuleSpec
+	pciDevices    *[]hardware.PCIDeviceSpec
+	disks         *[]block.DiskSpec
+}
+
+func lazy[T any](cache **T, get func() (T, error)) (T, error) {
+	if *cache != nil {
+		return **cache, nil
+	}
+
+	res, err := get()
+	if err != nil {
+		var zero T
+
+		return zero, err
+	}
+
+	*cache = &res
+
+	return res, nil
+}
+
+// Processors returns the processors of the node.
+func (v *nodeTransformationValues) Processors() ([]hardware.ProcessorSpec, error) {
+	if v.hw.source == nil {
+		return nil, nil
+	}
+
+	return lazy(&v.hw.processors, v.hw.source.Processors)
+}
+
+// MemoryModules returns the memory modules of the node.
+func (v *nodeTransformationValues) MemoryModules() ([]hardware.MemoryModuleSpec, error) {
+	if v.hw.source == nil {
+		return nil, nil
+	}
+
+	return lazy(&v.hw.memoryModules, v.hw.source.MemoryModules)
+}
+
+// PCIDevices returns the PCI devices of the node.
+func (v *nodeTransformationValues) PCIDevices() ([]hardware.PCIDeviceSpec, error) {
+	if v.hw.source == nil {
+		return nil, nil
+	}
+
+	return lazy(&v.hw.pciDevices, v.hw.source.PCIDevices)
+}
+
+// Disks returns the block devices of the node.
+func (v *nodeTransformationValues) Disks() ([]block.DiskSpec, error) {
+	if v.hw.source == nil {
+		return nil, nil
+	}
+
+	return lazy(&v.hw.disks, v.hw.source.Disks)
+}
+
+// CPUModel returns the product name of the first processor.
+func (v *nodeTransformationValues) CPUModel() (string, error) {
+	processors, err := v.Processors()
+	if err != nil || len(processors) == 0 {
+		return "", err
+	}
+
+	return strings.TrimSpace(processors[0].ProductName), nil
+}
+
+// CPUCores returns the number of the cores of all processors.
+func (v *nodeTransformationValues) CPUCores() (uint32, error) {
+	processors, err := v.Processors()
+	if err != nil {
+		return 0, err
+	}
+
+	var cores uint32
+	for _, p := range processors {
+		cores += p.CoreCount
+	}
+
+	return cores, nil
+}
+
+// CPUThreads returns the number of the threads of all processors.
+func (v *nodeTransformationValues) CPUThreads() (uint32, error) {
+	processors, err := v.Processors()
+	if err != nil {
+		return 0, err
+	}
+
+	var threads uint32
+	for _, p := range processors {
+		threads += p.ThreadCount
+	}
+
+	return threads, nil
+}
+
+// MemoryTotalMiB returns the total size of the memory modules in MiB.
+func (v *nodeTransformationValues) MemoryTotalMiB() (uint64, error) {
+	modules, err := v.MemoryModules()
+	if err != nil {
+		return 0, err
+	}
+
+	var size uint64
+	for _, m := range modules {
+		size += uint64(m.Size)
+	}
+
+	return size, nil
+}
+
+// MemoryTotalGiB returns the total size of the memory modules in GiB, rounded down.
+func (v *nodeTransformationValues) MemoryTotalGiB() (uint64, error) {
+	size, err := v.MemoryTotalMiB()
+
+	return size / 1024, err
+}
+
+// HasPCIVendor returns true if the node has a PCI device of the vendor, for example "10de" or "0x10de".
+func (v *nodeTransformationValues) HasPCIVendor(vendorID string) (bool, error) {
+	devices, err := v.PCIDevices()
+	if err != nil {
+		return false, err
+	}
+
+	vendorID = normalizePCIID(vendorID)
+
+	return slices.ContainsFunc(devices, func(d hardware.PCIDeviceSpec) bool {
+		return normalizePCIID(d.VendorID) == vendorID
+	}), nil
+}
+
+// DiskTypes returns the sorted types of the disks: nvme, ssd or hdd.
+// The read-only disks and CD-ROMs are skipped.
+func (v *nodeTransformationValues) DiskTypes() ([]string, error) {
+	disks, err := v.Disks()
+	if err != nil {
+		return nil, err
+	}
+
+	types := []string{}
+
+	for _, d := range disks {
+		if d.Readonly || d.CDROM {
+			continue
+		}
+
+		t := diskType(d)
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+
+	slices.Sort(types)
+
+	return types, nil
+}
+
+// HasDiskType returns true if the node has a disk of the type: nvme, ssd or hdd.
+func (v *nodeTransformationValues) HasDiskType(t string) (bool, error) {
+	types, err := v.DiskTypes()
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(types, strings.ToLower(t)), nil
+}
+
+func diskType(d block.DiskSpec) string {
+	switch {
+	case d.Transport == "nvme":
+		return DiskTypeNVMe
+	case d.Rotational:
+		return DiskTypeHDD
+	default:
+		return DiskTypeSSD
+	}
+}
+
+func normalizePCIID(id string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(id)), "0x")
+}
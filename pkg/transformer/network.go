@@ -0,0 +1,151 @@
+package transformer
+
+import (
+	"slices"
+
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
+)
+
+// The kinds of the virtual links.
+const (
+	linkKindBond   = "bond"
+	linkKindBridge = "bridge"
+	linkKindVLAN   = "vlan"
+)
+
+// Link is the network link status of the node.
+type Link struct {
+	// Name is the name of the link, for example eth0.
+	Name string
+
+	network.LinkStatusSpec
+}
+
+// NetworkTopology is the network topology of the link with the node IP.
+type NetworkTopology struct {
+	// Link is the name of the link with the node IP.
+	Link string
+	// SpeedMbit is the speed of the physical link, the sum of the speeds of the bond or bridge ports.
+	SpeedMbit int
+	// Driver is the driver of the physical link, or of the first port of the bond or bridge.
+	Driver string
+	// Bond is the name of the bond.
+	Bond string
+	// BondMode is the mode of the bond, for example 802.3ad.
+	BondMode string
+	// Bridge is the name of the bridge.
+	Bridge string
+	// VLAN is the VLAN ID.
+	VLAN uint16
+}
+
+// NodeNetwork provides the network links of the node.
+// The links are requested only when a transformation rule references them.
+type NodeNetwork interface {
+	// Links returns the network links of the node.
+	Links() ([]Link, error)
+	// NodeLinkName returns the name of the link with the node IP.
+	NodeLinkName() (string, error)
+}
+
+// lazyNetwork requests the network links once per transformation.
+type lazyNetwork struct {
+	source NodeNetwork
+
+	links    *[]Link
+	nodeLink *string
+}
+
+// Links returns the network links of the node.
+func (v *nodeTransformationValues) Links() ([]Link, error) {
+	if v.net.source == nil {
+		return nil, nil
+	}
+
+	return lazy(&v.net.links, v.net.source.Links)
+}
+
+// Link returns the network link by name, the zero link if it does not exist.
+func (v *nodeTransformationValues) Link(name string) (Link, error) {
+	links, err := v.Links()
+	if err != nil {
+		return Link{}, err
+	}
+
+	link, _ := findLink(links, func(l Link) bool { return l.Name == name })
+
+	return link, nil
+}
+
+// NetworkTopology returns the network topology of the link with the node IP.
+func (v *nodeTransformationValues) NetworkTopology() (NetworkTopology, error) {
+	if v.net.source == nil {
+		return NetworkTopology{}, nil
+	}
+
+	links, err := v.Links()
+	if err != nil {
+		return NetworkTopology{}, err
+	}
+
+	name, err := lazy(&v.net.nodeLink, v.net.source.NodeLinkName)
+	if err != nil {
+		return NetworkTopology{}, err
+	}
+
+	return LinkTopology(links, name), nil
+}
+
+// LinkTopology returns the network topology of the link.
+// The VLAN link is resolved to the parent link, the bond and bridge links are resolved to their ports.
+func LinkTopology(links []Link, name string) NetworkTopology {
+	topology := NetworkTopology{Link: name}
+
+	link, ok := findLink(links, func(l Link) bool { return l.Name == name })
+	if !ok {
+		return topology
+	}
+
+	if link.Kind == linkKindVLAN {
+		topology.VLAN = link.VLAN.VID
+
+		if parent, ok := findLink(links, func(l Link) bool { return l.Index == link.LinkIndex }); ok {
+			link = parent
+		}
+	}
+
+	switch link.Kind {
+	case linkKindBond:
+		topology.Bond = link.Name
+		topology.BondMode = link.BondMaster.Mode.String()
+	case linkKindBridge:
+		topology.Bridge = link.Name
+	default:
+		topology.SpeedMbit = max(link.SpeedMegabits, 0)
+		topology.Driver = link.Driver
+
+		return topology
+	}
+
+	for _, port := range links {
+		if port.MasterIndex != link.Index || !port.LinkState {
+			continue
+		}
+
+		topology.SpeedMbit += max(port.SpeedMegabits, 0)
+
+		if topology.Driver == "" {
+			topology.Driver = port.Driver
+		}
+	}
+
+	return topology
+}
+
+func findLink(links []Link, match func(Link) bool) (Link, bool) {
+	if idx := slices.IndexFunc(links, match); idx >= 0 {
+		return links[idx], true
+	}
+
+	return Link{}, false
+}
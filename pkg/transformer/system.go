@@ -0,0 +1,146 @@
+package transformer
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/nodeselector"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+)
+
+// The node selector keys of the operating system information.
+const (
+	FieldTalosVersion  = "talosversion"
+	FieldKernelVersion = "kernelversion"
+	FieldMachineType   = "machinetype"
+	// FieldExtensionPrefix is the prefix of the installed system extensions keys, the value is the extension version.
+	FieldExtensionPrefix = "extension."
+)
+
+// NodeSystem provides the operating system information of the node.
+// The information is requested only when a transformation rule references it.
+type NodeSystem interface {
+	TalosVersion() (string, error)
+	KernelVersion() (string, error)
+	MachineType() (string, error)
+	Extensions() ([]runtime.ExtensionStatusSpec, error)
+}
+
+// lazySystem requests the operating system information once per transformation.
+type lazySystem struct {
+	source NodeSystem
+
+	talosVersion  *string
+	kernelVersion *string
+	machineType   *string
+	extensions    *[]runtime.ExtensionStatusSpec
+}
+
+// TalosVersion returns the Talos version of the node, for example v1.13.5.
+func (v *nodeTransformationValues) TalosVersion() (string, error) {
+	if v.sys.source == nil {
+		return "", nil
+	}
+
+	return lazy(&v.sys.talosVersion, v.sys.source.TalosVersion)
+}
+
+// KernelVersion returns the kernel release of the node.
+func (v *nodeTransformationValues) KernelVersion() (string, error) {
+	if v.sys.source == nil {
+		return "", nil
+	}
+
+	return lazy(&v.sys.kernelVersion, v.sys.source.KernelVersion)
+}
+
+// MachineType returns the machine type of the node: controlplane or worker.
+func (v *nodeTransformationValues) MachineType() (string, error) {
+	if v.sys.source == nil {
+		return "", nil
+	}
+
+	return lazy(&v.sys.machineType, v.sys.source.MachineType)
+}
+
+// Extensions returns the installed system extensions of the node.
+func (v *nodeTransformationValues) Extensions() ([]runtime.ExtensionStatusSpec, error) {
+	if v.sys.source == nil {
+		return nil, nil
+	}
+
+	return lazy(&v.sys.extensions, v.sys.source.Extensions)
+}
+
+// HasExtension returns true if the system extension is installed on the node.
+func (v *nodeTransformationValues) HasExtension(name string) (bool, error) {
+	exts, err := v.Extensions()
+	if err != nil {
+		return false, err
+	}
+
+	return slices.ContainsFunc(exts, func(e runtime.ExtensionStatusSpec) bool {
+		return strings.EqualFold(e.Metadata.Name, name)
+	}), nil
+}
+
+// ExtensionVersion returns the version of the installed system extension, or empty string.
+func (v *nodeTransformationValues) ExtensionVersion(name string) (string, error) {
+	exts, err := v.Extensions()
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range exts {
+		if strings.EqualFold(e.Metadata.Name, name) {
+			return e.Metadata.Version, nil
+		}
+	}
+
+	return "", nil
+}
+
+// systemFields adds the operating system fields, which the node selector terms reference, to the fields.
+// The empty values are skipped, like the empty platform metadata fields.
+func (v *nodeTransformationValues) systemFields(terms []nodeselector.NodeSelectorTerm, fields map[string]string) error {
+	for _, term := range terms {
+		for _, expr := range term.MatchExpressions {
+			key := strings.ToLower(expr.Key)
+			if _, ok := fields[key]; ok {
+				continue
+			}
+
+			var (
+				value string
+				err   error
+			)
+
+			switch {
+			case key == FieldTalosVersion:
+				value, err = v.TalosVersion()
+			case key == FieldKernelVersion:
+				value, err = v.KernelVersion()
+			case key == FieldMachineType:
+				value, err = v.MachineType()
+			case strings.HasPrefix(key, FieldExtensionPrefix):
+				var exts []runtime.ExtensionStatusSpec
+
+				if exts, err = v.Extensions(); err == nil {
+					for _, e := range exts {
+						fields[FieldExtensionPrefix+strings.ToLower(e.Metadata.Name)] = e.Metadata.Version
+					}
+				}
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if value != "" {
+				fields[key] = value
+			}
+		}
+	}
+
+	return nil
+}
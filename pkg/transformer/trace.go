@@ -0,0 +1,121 @@
+package transformer
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Trace is the record of the transformation rules which matched the node.
+type Trace struct {
+	Rules []TraceRule `json:"rules,omitempty" yaml:"rules,omitempty"`
+
+	keys map[string]struct{}
+}
+
+// TraceRule is the record of the matched transformation rule and the keys it set.
+type TraceRule struct {
+	// Index is the index of the rule in the transformations list.
+	Index int    `json:"index" yaml:"index"`
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"`
+	// SelectorTerm is the index of the matched node selector term, nil if the rule has no node selector.
+	SelectorTerm *int `json:"selectorTerm,omitempty" yaml:"selectorTerm,omitempty"`
+
+	Annotations      []string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	Labels           []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Taints           []string `json:"taints,omitempty" yaml:"taints,omitempty"`
+	PlatformMetadata []string `json:"platformMetadata,omitempty" yaml:"platformMetadata,omitempty"`
+	// Overrides is the keys set by the previous rules and overridden by the rule, in the form of `labels/<key>`.
+	Overrides []string `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+}
+
+func (t *Trace) record(idx int, term NodeTerm, selectorTerm int) {
+	rule := TraceRule{
+		Index: idx,
+		Name:  term.Name,
+	}
+
+	if len(term.NodeSelector) > 0 {
+		rule.SelectorTerm = &selectorTerm
+	}
+
+	if t.keys == nil {
+		t.keys = make(map[string]struct{})
+	}
+
+	setKeys := func(kind string, values map[string]string) []string {
+		var res []string
+
+		for _, k := range slices.Sorted(maps.Keys(values)) {
+			if kind == "platformMetadata" && slices.Contains(prohibitedPlatformMetadataKeys, strings.ToLower(k)) {
+				continue
+			}
+
+			key := kind + "/" + k
+			if kind == "platformMetadata" {
+				key = kind + "/" + strings.ToLower(k)
+			}
+
+			if _, ok := t.keys[key]; ok {
+				rule.Overrides = append(rule.Overrides, kind+"/"+k)
+			}
+
+			t.keys[key] = struct{}{}
+			res = append(res, k)
+		}
+
+		return res
+	}
+
+	rule.Annotations = setKeys("annotations", term.Annotations)
+	rule.Labels = setKeys("labels", term.Labels)
+	rule.Taints = setKeys("taints", term.Taints)
+	rule.PlatformMetadata = setKeys("platformMetadata", term.PlatformMetadata)
+
+	t.Rules = append(t.Rules, rule)
+}
+
+// String returns the short description of the matched rules.
+func (t *Trace) String() string {
+	if len(t.Rules) == 0 {
+		return "no transformation rules matched"
+	}
+
+	rules := make([]string, 0, len(t.Rules))
+
+	for _, rule := range t.Rules {
+		var b strings.Builder
+
+		fmt.Fprintf(&b, "transformations[%d]", rule.Index)
+
+		if rule.Name != "" {
+			fmt.Fprintf(&b, " (%s)", rule.Name)
+		}
+
+		if rule.SelectorTerm != nil {
+			fmt.Fprintf(&b, " matched nodeSelector[%d]", *rule.SelectorTerm)
+		} else {
+			b.WriteString(" matched")
+		}
+
+		for _, keys := range []struct {
+			kind string
+			keys []string
+		}{
+			{"annotations", rule.Annotations},
+			{"labels", rule.Labels},
+			{"taints", rule.Taints},
+			{"platformMetadata", rule.PlatformMetadata},
+			{"overrides", rule.Overrides},
+		} {
+			if len(keys.keys) > 0 {
+				fmt.Fprintf(&b, " %s=[%s]", keys.kind, strings.Join(keys.keys, ","))
+			}
+		}
+
+		rules = append(rules, b.String())
+	}
+
+	return strings.Join(rules, "; ")
+}
@@ -40,6 +40,43 @@ type NodeSpec struct {
 type nodeTransformationValues struct {
 	runtime.PlatformMetadataSpec
 	hardware.SystemInformationSpec
+
+	hw  lazyHardware
+	sys lazySystem
+	net lazyNetwork
+
+	trace *Trace
+}
+
+// Option is the option of the node transformation.
+type Option func(*nodeTransformationValues)
+
+// WithHardware provides the hardware resources of the node to the transformation rules.
+func WithHardware(hw NodeHardware) Option {
+	return func(v *nodeTransformationValues) {
+		v.hw.source = hw
+	}
+}
+
+// WithSystem provides the operating system information of the node to the transformation rules.
+func WithSystem(sys NodeSystem) Option {
+	return func(v *nodeTransformationValues) {
+		v.sys.source = sys
+	}
+}
+
+// WithNetwork provides the network links of the node to the transformation rules.
+func WithNetwork(net NodeNetwork) Option {
+	return func(v *nodeTransformationValues) {
+		v.net.source = net
+	}
+}
+
+// WithTrace records the matched transformation rules and the keys they set to the trace.
+func WithTrace(trace *Trace) Option {
+	return func(v *nodeTransformationValues) {
+		v.trace = trace
+	}
 }
 
 // NodeFeaturesFlagSpec represents the node features flags.
@@ -53,7 +90,7 @@ var prohibitedPlatformMetadataKeys = []string{"hostname", "platform"}
 // TransformNode transforms the node metadata based on the node transformation rules.
 //
 //nolint:gocyclo,cyclop
-func TransformNode(terms []NodeTerm, platformMetadata *runtime.PlatformMetadataSpec, sysinfo *hardware.SystemInformationSpec) (*NodeSpec, error) {
+func TransformNode(terms []NodeTerm, platformMetadata *runtime.PlatformMetadataSpec, sysinfo *hardware.SystemInformationSpec, opts ...Option) (*NodeSpec, error) {
 	node := &NodeSpec{
 		Annotations: make(map[string]string),
 		Labels:      make(map[string]string),
@@ -64,20 +101,28 @@ func TransformNode(terms []NodeTerm, platformMetadata *runtime.PlatformMetadataS
 		return node, nil
 	}
 
-	values := nodeTransformationValues{PlatformMetadataSpec: *platformMetadata}
+	values := &nodeTransformationValues{PlatformMetadataSpec: *platformMetadata}
 	if sysinfo != nil {
 		values.SystemInformationSpec = *sysinfo
 	}
 
+	for _, opt := range opts {
+		opt(values)
+	}
+
 	metadata := mapFromStruct(platformMetadata)
 
-	for _, term := range terms {
-		match, err := nodeselector.Match(term.NodeSelector, metadata)
+	for idx, term := range terms {
+		if err := values.systemFields(term.NodeSelector, metadata); err != nil {
+			return nil, err
+		}
+
+		selectorTerm, err := nodeselector.MatchTerm(term.NodeSelector, metadata)
 		if err != nil {
 			return nil, err
 		}
 
-		if match {
+		if selectorTerm >= 0 || len(term.NodeSelector) == 0 {
 			if term.Annotations != nil {
 				for k, v := range term.Annotations {
 					t, err := executeTemplate(v, values)
@@ -161,16 +206,34 @@ func TransformNode(terms []NodeTerm, platformMetadata *runtime.PlatformMetadataS
 					}
 				}
 			}
+
+			if values.trace != nil {
+				values.trace.record(idx, term, selectorTerm)
+			}
 		}
 	}
 
 	return node, nil
 }
 
-func executeTemplate(tmpl string, data any) (string, error) {
+// MatchPlatformMetadata returns true if the platform metadata matches the node selector terms.
+func MatchPlatformMetadata(terms []nodeselector.NodeSelectorTerm, platformMetadata *runtime.PlatformMetadataSpec) (bool, error) {
+	return nodeselector.Match(terms, mapFromStruct(platformMetadata))
+}
+
+func parseTemplate(tmpl string) (*template.Template, error) {
 	t, err := template.New("transformer").Funcs(GenericFuncMap()).Parse(tmpl)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template %q: %w", tmpl, err)
+		return nil, fmt.Errorf("failed to parse template %q: %w", tmpl, err)
+	}
+
+	return t, nil
+}
+
+func executeTemplate(tmpl string, data any) (string, error) {
+	t, err := parseTemplate(tmpl)
+	if err != nil {
+		return "", err
 	}
 
 	var buf bytes.Buffer
@@ -2,11 +2,18 @@ package transformer_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/nodeselector"
 	"github.com/siderolabs/talos-cloud-controller-manager/pkg/transformer"
+	"github.com/siderolabs/talos/pkg/machinery/nethelpers"
+	"github.com/siderolabs/talos/pkg/machinery/resources/block"
+	"github.com/siderolabs/talos/pkg/machinery/resources/hardware"
+	"github.com/siderolabs/talos/pkg/machinery/resources/network"
 	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
 )
 
@@ -337,3 +344,474 @@ func TestMatch(t *testing.T) {
 		})
 	}
 }
+
+type fakeHardware struct {
+	calls int
+}
+
+func (h *fakeHardware) Processors() ([]hardware.ProcessorSpec, error) {
+	h.calls++
+
+	return []hardware.ProcessorSpec{
+		{ProductName: "AMD EPYC 7502P ", CoreCount: 32, ThreadCount: 64},
+		{ProductName: "AMD EPYC 7502P ", CoreCount: 32, ThreadCount: 64},
+	}, nil
+}
+
+func (h *fakeHardware) MemoryModules() ([]hardware.MemoryModuleSpec, error) {
+	h.calls++
+
+	return []hardware.MemoryModuleSpec{{Size: 32768}, {Size: 32768}}, nil
+}
+
+func (h *fakeHardware) PCIDevices() ([]hardware.PCIDeviceSpec, error) {
+	h.calls++
+
+	return []hardware.PCIDeviceSpec{{VendorID: "0x10de", ClassID: "0x03"}}, nil
+}
+
+func (h *fakeHardware) Disks() ([]block.DiskSpec, error) {
+	h.calls++
+
+	return []block.DiskSpec{
+		{Transport: "nvme"},
+		{Rotational: true},
+		{CDROM: true},
+	}, nil
+}
+
+func TestTransformNodeHardware(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		labels        map[string]string
+		expected      map[string]string
+		expectedCalls int
+	}{
+		{
+			name:   "no hardware references",
+			labels: map[string]string{"platform": "{{ .Platform }}"},
+			expected: map[string]string{
+				"platform": "metal",
+			},
+		},
+		{
+			name: "processors",
+			labels: map[string]string{
+				"cpu-model":   "{{ .CPUModel | replace \" \" \"-\" }}",
+				"cpu-cores":   "{{ .CPUCores }}",
+				"cpu-threads": "{{ .CPUThreads }}",
+				"cpu-count":   "{{ len .Processors }}",
+			},
+			expected: map[string]string{
+				"cpu-model":   "AMD-EPYC-7502P",
+				"cpu-cores":   "64",
+				"cpu-threads": "128",
+				"cpu-count":   "2",
+			},
+			expectedCalls: 1,
+		},
+		{
+			name: "memory, gpu and disks",
+			labels: map[string]string{
+				"memory": "{{ .MemoryTotalGiB }}Gi",
+				"gpu":    "{{ .HasPCIVendor \"10de\" }}",
+				"nvme":   "{{ .HasDiskType \"nvme\" }}",
+				"ssd":    "{{ .HasDiskType \"ssd\" }}",
+				"disks":  "{{ range $i, $t := .DiskTypes }}{{ if $i }}.{{ end }}{{ $t }}{{ end }}",
+			},
+			expected: map[string]string{
+				"memory": "64Gi",
+				"gpu":    "true",
+				"nvme":   "true",
+				"ssd":    "false",
+				"disks":  "hdd.nvme",
+			},
+			expectedCalls: 3,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			hw := &fakeHardware{}
+
+			node, err := transformer.TransformNode(
+				[]transformer.NodeTerm{{Name: "hardware", Labels: tt.labels}},
+				&runtime.PlatformMetadataSpec{Platform: "metal"},
+				nil,
+				transformer.WithHardware(hw),
+			)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, node.Labels)
+			assert.Equal(t, tt.expectedCalls, hw.calls)
+		})
+	}
+}
+
+type fakeSystem struct {
+	calls int
+}
+
+func (s *fakeSystem) TalosVersion() (string, error) {
+	s.calls++
+
+	return "v1.13.5", nil
+}
+
+func (s *fakeSystem) KernelVersion() (string, error) {
+	s.calls++
+
+	return "6.18.44-talos", nil
+}
+
+func (s *fakeSystem) MachineType() (string, error) {
+	s.calls++
+
+	return "worker", nil
+}
+
+func (s *fakeSystem) Extensions() ([]runtime.ExtensionStatusSpec, error) {
+	s.calls++
+
+	ext := runtime.ExtensionStatusSpec{}
+	ext.Metadata.Name = "iscsi-tools"
+	ext.Metadata.Version = "v0.2.0"
+
+	return []runtime.ExtensionStatusSpec{ext}, nil
+}
+
+func TestTransformNodeSystem(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		terms          []transformer.NodeTerm
+		expectedLabels map[string]string
+		expectedTaints map[string]string
+		expectedCalls  int
+	}{
+		{
+			name: "no system references",
+			terms: []transformer.NodeTerm{
+				{Labels: map[string]string{"platform": "{{ .Platform }}"}},
+			},
+			expectedLabels: map[string]string{"platform": "metal"},
+			expectedTaints: map[string]string{},
+		},
+		{
+			name: "templates",
+			terms: []transformer.NodeTerm{
+				{
+					Labels: map[string]string{
+						"talos.dev/version": "{{ .TalosVersion }}",
+						"kernel":            "{{ .KernelVersion }}",
+						"type":              "{{ .MachineType }}",
+						"iscsi":             "{{ .ExtensionVersion \"iscsi-tools\" }}",
+						"nvidia":            "{{ .HasExtension \"nvidia-container-toolkit\" }}",
+					},
+				},
+			},
+			expectedLabels: map[string]string{
+				"talos.dev/version": "v1.13.5",
+				"kernel":            "6.18.44-talos",
+				"type":              "worker",
+				"iscsi":             "v0.2.0",
+				"nvidia":            "false",
+			},
+			expectedTaints: map[string]string{},
+			expectedCalls:  4,
+		},
+		{
+			name: "node selector",
+			terms: []transformer.NodeTerm{
+				{
+					NodeSelector: []nodeselector.NodeSelectorTerm{
+						{
+							MatchExpressions: []nodeselector.NodeSelectorRequirement{
+								{Key: "talosVersion", Operator: "Regexp", Values: []string{`^v1\.1[3-9]\.`}},
+								{Key: "machineType", Operator: "In", Values: []string{"worker"}},
+							},
+						},
+					},
+					Labels: map[string]string{"os": "supported"},
+				},
+				{
+					NodeSelector: []nodeselector.NodeSelectorTerm{
+						{
+							MatchExpressions: []nodeselector.NodeSelectorRequirement{
+								{Key: "extension.nvidia-container-toolkit", Operator: "DoesNotExist"},
+							},
+						},
+					},
+					Taints: map[string]string{"nvidia": "NoSchedule"},
+				},
+				{
+					NodeSelector: []nodeselector.NodeSelectorTerm{
+						{
+							MatchExpressions: []nodeselector.NodeSelectorRequirement{
+								{Key: "extension.iscsi-tools", Operator: "Exists"},
+							},
+						},
+					},
+					Labels: map[string]string{"iscsi": "true"},
+				},
+			},
+			expectedLabels: map[string]string{"os": "supported", "iscsi": "true"},
+			expectedTaints: map[string]string{"nvidia": "NoSchedule"},
+			expectedCalls:  3,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := &fakeSystem{}
+
+			node, err := transformer.TransformNode(tt.terms, &runtime.PlatformMetadataSpec{Platform: "metal"}, nil, transformer.WithSystem(sys))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedLabels, node.Labels)
+			assert.Equal(t, tt.expectedTaints, node.Taints)
+			assert.Equal(t, tt.expectedCalls, sys.calls)
+		})
+	}
+}
+
+type fakeNetwork struct {
+	nodeLink string
+	calls    int
+}
+
+func (n *fakeNetwork) Links() ([]transformer.Link, error) {
+	n.calls++
+
+	return []transformer.Link{
+		{Name: "bond0", LinkStatusSpec: network.LinkStatusSpec{Index: 4, Kind: "bond", BondMaster: network.BondMasterSpec{Mode: nethelpers.BondMode8023AD}}},
+		{Name: "bond0.100", LinkStatusSpec: network.LinkStatusSpec{Index: 5, Kind: "vlan", LinkIndex: 4, VLAN: network.VLANSpec{VID: 100}}},
+		{Name: "eth0", LinkStatusSpec: network.LinkStatusSpec{Index: 2, MasterIndex: 4, LinkState: true, SpeedMegabits: 25000, Driver: "mlx5_core"}},
+		{Name: "eth1", LinkStatusSpec: network.LinkStatusSpec{Index: 3, MasterIndex: 4, LinkState: true, SpeedMegabits: 25000, Driver: "mlx5_core"}},
+		{Name: "eth2", LinkStatusSpec: network.LinkStatusSpec{Index: 6, LinkState: true, SpeedMegabits: -1, Driver: "virtio_net"}},
+	}, nil
+}
+
+func (n *fakeNetwork) NodeLinkName() (string, error) {
+	n.calls++
+
+	return n.nodeLink, nil
+}
+
+func TestTransformNodeNetwork(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		nodeLink      string
+		labels        map[string]string
+		expected      map[string]string
+		expectedCalls int
+	}{
+		{
+			name:     "no network references",
+			nodeLink: "bond0.100",
+			labels:   map[string]string{"platform": "{{ .Platform }}"},
+			expected: map[string]string{"platform": "metal"},
+		},
+		{
+			name:     "vlan over bond",
+			nodeLink: "bond0.100",
+			labels: map[string]string{
+				"link":   "{{ .NetworkTopology.Link }}",
+				"speed":  "{{ .NetworkTopology.SpeedMbit }}",
+				"driver": "{{ .NetworkTopology.Driver }}",
+				"bond":   "{{ .NetworkTopology.Bond }}",
+				"mode":   "{{ .NetworkTopology.BondMode }}",
+				"vlan":   "{{ .NetworkTopology.VLAN }}",
+				"links":  "{{ len .Links }}",
+				"eth1":   "{{ (.Link \"eth1\").SpeedMegabits }}",
+			},
+			expected: map[string]string{
+				"link":   "bond0.100",
+				"speed":  "50000",
+				"driver": "mlx5_core",
+				"bond":   "bond0",
+				"mode":   "802.3ad",
+				"vlan":   "100",
+				"links":  "5",
+				"eth1":   "25000",
+			},
+			expectedCalls: 2,
+		},
+		{
+			name:     "physical link with unknown speed",
+			nodeLink: "eth2",
+			labels: map[string]string{
+				"speed":  "{{ .NetworkTopology.SpeedMbit }}",
+				"driver": "{{ .NetworkTopology.Driver }}",
+				"vlan":   "{{ .NetworkTopology.VLAN }}",
+			},
+			expected: map[string]string{
+				"speed":  "0",
+				"driver": "virtio_net",
+				"vlan":   "0",
+			},
+			expectedCalls: 2,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			net := &fakeNetwork{nodeLink: tt.nodeLink}
+
+			node, err := transformer.TransformNode(
+				[]transformer.NodeTerm{{Name: "network", Labels: tt.labels}},
+				&runtime.PlatformMetadataSpec{Platform: "metal"},
+				nil,
+				transformer.WithNetwork(net),
+			)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, node.Labels)
+			assert.Equal(t, tt.expectedCalls, net.calls)
+		})
+	}
+}
+
+func TestTransformNodeTrace(t *testing.T) {
+	terms := []transformer.NodeTerm{
+		{
+			Name:   "all",
+			Labels: map[string]string{"node.example.com/role": "worker", "node.example.com/zone": "{{ .Zone }}"},
+		},
+		{
+			Name: "web",
+			NodeSelector: []nodeselector.NodeSelectorTerm{
+				{
+					MatchExpressions: []nodeselector.NodeSelectorRequirement{
+						{Key: "hostname", Operator: "In", Values: []string{"db-1"}},
+					},
+				},
+				{
+					MatchExpressions: []nodeselector.NodeSelectorRequirement{
+						{Key: "hostname", Operator: "Regexp", Values: []string{"^web-.+$"}},
+					},
+				},
+			},
+			Labels:           map[string]string{"node.example.com/role": "web"},
+			Taints:           map[string]string{"example.com/web": "NoSchedule"},
+			PlatformMetadata: map[string]string{"Zone": "web-zone", "Hostname": "web"},
+		},
+		{
+			Name: "db",
+			NodeSelector: []nodeselector.NodeSelectorTerm{
+				{
+					MatchExpressions: []nodeselector.NodeSelectorRequirement{
+						{Key: "hostname", Operator: "In", Values: []string{"db-1"}},
+					},
+				},
+			},
+			Labels: map[string]string{"node.example.com/role": "db"},
+		},
+		{
+			Annotations:      map[string]string{"example.com/zone": "{{ .Zone }}"},
+			PlatformMetadata: map[string]string{"zone": "zone-1"},
+		},
+	}
+
+	trace := &transformer.Trace{}
+
+	node, err := transformer.TransformNode(terms, &runtime.PlatformMetadataSpec{Hostname: "web-1"}, nil, transformer.WithTrace(trace))
+	require.NoError(t, err)
+	assert.Equal(t, "web", node.Labels["node.example.com/role"])
+
+	term := 1
+
+	assert.Equal(t, []transformer.TraceRule{
+		{
+			Index:  0,
+			Name:   "all",
+			Labels: []string{"node.example.com/role", "node.example.com/zone"},
+		},
+		{
+			Index:            1,
+			Name:             "web",
+			SelectorTerm:     &term,
+			Labels:           []string{"node.example.com/role"},
+			Taints:           []string{"example.com/web"},
+			PlatformMetadata: []string{"Zone"},
+			Overrides:        []string{"labels/node.example.com/role"},
+		},
+		{
+			Index:            3,
+			Annotations:      []string{"example.com/zone"},
+			PlatformMetadata: []string{"zone"},
+			Overrides:        []string{"platformMetadata/zone"},
+		},
+	}, trace.Rules)
+
+	assert.Equal(t, "transformations[0] (all) matched labels=[node.example.com/role,node.example.com/zone]; "+
+		"transformations[1] (web) matched nodeSelector[1] labels=[node.example.com/role] taints=[example.com/web] platformMetadata=[Zone] "+
+		"overrides=[labels/node.example.com/role]; "+
+		"transformations[3] matched annotations=[example.com/zone] platformMetadata=[zone] overrides=[platformMetadata/zone]", trace.String())
+
+	empty := &transformer.Trace{}
+
+	_, err = transformer.TransformNode(terms[2:3], &runtime.PlatformMetadataSpec{Hostname: "web-1"}, nil, transformer.WithTrace(empty))
+	require.NoError(t, err)
+	assert.Empty(t, empty.Rules)
+	assert.Equal(t, "no transformation rules matched", empty.String())
+}
+
+func TestValidateNodeTerms(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		terms       []transformer.NodeTerm
+		expectedErr []string
+	}{
+		{
+			name: "valid terms",
+			terms: []transformer.NodeTerm{
+				{
+					Name: "web",
+					NodeSelector: []nodeselector.NodeSelectorTerm{
+						{
+							MatchExpressions: []nodeselector.NodeSelectorRequirement{
+								{Key: "hostname", Operator: nodeselector.NodeSelectorOpRegexp, Values: []string{"^web-.+$"}},
+							},
+						},
+					},
+					Annotations:      map[string]string{"example.com/instance-id": "{{ .InstanceID }}"},
+					Labels:           map[string]string{"node-role.kubernetes.io/web": ""},
+					Taints:           map[string]string{"example.com/web": "{{ .Zone }}:NoSchedule"},
+					PlatformMetadata: map[string]string{"Zone": "{{ .Region }}-a", "spot": "true"},
+				},
+			},
+		},
+		{
+			name: "invalid terms",
+			terms: []transformer.NodeTerm{
+				{
+					Name: "web",
+					NodeSelector: []nodeselector.NodeSelectorTerm{
+						{
+							MatchExpressions: []nodeselector.NodeSelectorRequirement{
+								{Key: "hostname", Operator: nodeselector.NodeSelectorOpRegexp, Values: []string{"^web-(.+$"}},
+							},
+						},
+					},
+					Annotations: map[string]string{"example.com/instance-id": "{{ .InstanceID"},
+					Labels:      map[string]string{"example.com/web role": "web node"},
+				},
+				{
+					Taints:           map[string]string{"example.com/web": "NoScheduled"},
+					PlatformMetadata: map[string]string{"hostname": "web", "region-name": "{{ .Zone }}"},
+				},
+			},
+			expectedErr: []string{
+				"transformations[0] (web): nodeSelector[0].matchExpressions[0]: invalid regexp \"^web-(.+$\": error parsing regexp: missing closing ): `^web-(.+$`",
+				"transformations[0] (web): annotations[\"example.com/instance-id\"]: failed to parse template \"{{ .InstanceID\": template: transformer:1: unclosed action",
+				"transformations[0] (web): labels[\"example.com/web role\"]: invalid label name: [name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')]",
+				"transformations[0] (web): labels[\"example.com/web role\"]: invalid value \"web node\": [a valid label must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or '12345', regex used for validation is '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')]",
+				"transformations[1]: taints[\"example.com/web\"]: invalid value \"NoScheduled\": [taint effect \"NoScheduled\" is not valid]",
+				"transformations[1]: platformMetadata[\"region-name\"]: unknown platform metadata field region-name",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := transformer.ValidateNodeTerms(tt.terms)
+
+			if len(tt.expectedErr) == 0 {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			assert.EqualError(t, err, strings.Join(tt.expectedErr, "\n"))
+		})
+	}
+}
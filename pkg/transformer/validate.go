@@ -0,0 +1,134 @@
+package transformer
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/siderolabs/talos-cloud-controller-manager/pkg/nodeselector"
+	"github.com/siderolabs/talos/pkg/machinery/resources/runtime"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// ValidateNodeTerms returns all errors of the node transformation rules.
+// The errors have the index and the name of the rule and the path of the field.
+// The values without the template actions are validated as the result values.
+// The platform metadata fields, which can not be changed, are ignored by the transformation with a warning.
+func ValidateNodeTerms(terms []NodeTerm) error {
+	var errs []error
+
+	for idx, term := range terms {
+		path := fmt.Sprintf("transformations[%d]", idx)
+		if term.Name != "" {
+			path = fmt.Sprintf("%s (%s)", path, term.Name)
+		}
+
+		for _, err := range term.validate() {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+
+		for _, k := range slices.Sorted(maps.Keys(term.PlatformMetadata)) {
+			if slices.Contains(prohibitedPlatformMetadataKeys, strings.ToLower(k)) {
+				klog.InfoS("platform metadata field can not be changed, it is ignored", "transformation", path, "field", k)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t NodeTerm) validate() []error {
+	errs := nodeselector.Validate(t.NodeSelector, "nodeSelector")
+
+	for _, k := range slices.Sorted(maps.Keys(t.Annotations)) {
+		path := fmt.Sprintf("annotations[%q]", k)
+
+		if e := validation.IsQualifiedName(k); len(e) != 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid annotation name: %v", path, e))
+		}
+
+		if err := validateTemplate(t.Annotations[k], nil); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(t.Labels)) {
+		path := fmt.Sprintf("labels[%q]", k)
+
+		if e := validation.IsQualifiedName(k); len(e) != 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid label name: %v", path, e))
+		}
+
+		if err := validateTemplate(t.Labels[k], validation.IsValidLabelValue); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(t.Taints)) {
+		path := fmt.Sprintf("taints[%q]", k)
+
+		if e := append(validation.IsQualifiedName(k), isQualifiedTaintName(k)...); len(e) != 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid taint name: %v", path, e))
+		}
+
+		if err := validateTemplate(t.Taints[k], isValidTaintValue); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(t.PlatformMetadata)) {
+		path := fmt.Sprintf("platformMetadata[%q]", k)
+
+		if err := validatePlatformMetadataKey(k); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+
+		if err := validateTemplate(t.PlatformMetadata[k], nil); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+
+	return errs
+}
+
+// validateTemplate parses the template, the value without the template actions is validated by the validate function.
+func validateTemplate(tmpl string, validate func(string) []string) error {
+	if _, err := parseTemplate(tmpl); err != nil {
+		return err
+	}
+
+	if validate != nil && !strings.Contains(tmpl, "{{") {
+		if e := validate(tmpl); len(e) != 0 {
+			return fmt.Errorf("invalid value %q: %v", tmpl, e)
+		}
+	}
+
+	return nil
+}
+
+// validatePlatformMetadataKey returns an error if the platform metadata field does not exist or is not supported.
+func validatePlatformMetadataKey(key string) error {
+	// The transformation ignores these fields, ValidateNodeTerms warns about them.
+	if slices.Contains(prohibitedPlatformMetadataKeys, strings.ToLower(key)) {
+		return nil
+	}
+
+	f, ok := reflect.TypeFor[runtime.PlatformMetadataSpec]().FieldByNameFunc(func(fieldName string) bool {
+		return strings.EqualFold(fieldName, key)
+	})
+	if !ok {
+		return fmt.Errorf("unknown platform metadata field %s", key)
+	}
+
+	switch f.Type.Kind() { //nolint:exhaustive
+	case reflect.Bool, reflect.String:
+		return nil
+	default:
+		return fmt.Errorf("unsupported platform metadata field %s", key)
+	}
+}